@@ -7,16 +7,19 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
 	"syscall"
 	"time"
 
 	"github.com/nvcnvn/adk-golang/pkg/logger"
 
 	"github.com/nvcnvn/adk-golang/pkg/api"
+	"github.com/nvcnvn/adk-golang/pkg/artifacts"
 	"github.com/nvcnvn/adk-golang/pkg/config"
 	"github.com/nvcnvn/adk-golang/pkg/flow"
 	"github.com/nvcnvn/adk-golang/pkg/memory"
 	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
 )
 
 var (
@@ -52,42 +55,54 @@ func main() {
 	// 创建管理器
 	manager := flow.NewManager()
 
-	// 创建加载器
-	loader, err := flow.NewLoader(cfg.PluginDir, manager)
-	if err != nil {
-		log.Fatalf("创建插件加载器失败: %v", err)
+	// 应用工作流版本权重（灰度发布），需在加载插件前设置
+	for name, weights := range cfg.FlowVersions {
+		if err := manager.SetWeights(name, weights); err != nil {
+			log.Printf("设置工作流 %s 版本权重失败: %v", name, err)
+		}
 	}
 
-	// 注册 Quad Memory 服务
-	log.Printf("验证 Quad Memory 服务...")
-	qms := memory.NewQuadMemoryService(memory.QuadMemoryConfig{
-		BaseURL:      "http://host.docker.internal:7200",
-		RepositoryID: "main",
-		MaxRetries:   3,
-		RetryBackoff: 1 * time.Second,
-	})
+	// 按配置构造命名记忆后端，流程与工具通过名称解析
+	log.Printf("初始化记忆后端...")
+	memRegistry, err := memory.NewRegistryFromConfig(cfg.Memory)
+	if err != nil {
+		log.Printf("部分记忆后端初始化失败: %v", err)
+	}
+	memory.SetDefaultRegistry(memRegistry)
 
-	// 执行健康检查
+	// 执行健康检查，失败时仅告警
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-
-	if err := qms.HealthCheck(ctx); err != nil {
-		log.Printf("Quad Memory 服务健康检查失败: %v", err)
-		log.Printf("警告: Quad Memory 服务不可用，系统将继续启动但相关功能可能受限")
-	} else {
-		log.Printf("Quad Memory 服务健康检查成功")
+	failed := memRegistry.HealthCheck(ctx)
+	for _, name := range memRegistry.Names() {
+		if err, ok := failed[name]; ok {
+			log.Printf("记忆后端 %s 健康检查失败: %v", name, err)
+			log.Printf("警告: 记忆后端 %s 不可用，系统将继续启动但相关功能可能受限", name)
+		} else {
+			log.Printf("记忆后端 %s 就绪", name)
+		}
 	}
 
-	// 注册 Custom RAG Memory 服务
-	log.Printf("验证 Custom RAG Memory 服务...")
-	cragms := memory.NewCustomRagMemoryServiceWithDefaults()
+	// 向插件注入宿主服务，插件通过 Host 复用上述实例而非自行创建
+	host := flow.NewHost(cfg)
+	for _, name := range memRegistry.Names() {
+		svc, _ := memRegistry.Get(name)
+		if ms, ok := svc.(memory.MemoryService); ok {
+			host.RegisterMemory(name, ms)
+		} else {
+			host.RegisterService(name, svc)
+		}
+	}
+	if _, err := memRegistry.Memory(""); err == nil {
+		host.SetDefault("memory", memRegistry.Default())
+	}
+	host.RegisterSession("in_memory", sessions.NewInMemorySessionService())
+	host.RegisterArtifact("in_memory", artifacts.NewInMemoryArtifactService())
 
-	// 执行 Custom RAG Memory 健康检查
-	if err := cragms.HealthCheck(ctx); err != nil {
-		log.Printf("Custom RAG Memory 服务健康检查失败: %v", err)
-		log.Printf("警告: Custom RAG Memory 服务不可用，系统将继续启动但相关功能可能受限")
-	} else {
-		log.Printf("Custom RAG Memory 服务健康检查成功")
+	// 创建加载器（会同步加载目录中已有插件，需在服务注册之后）
+	loader, err := flow.NewLoader(cfg.PluginDir, manager, flow.WithHost(host))
+	if err != nil {
+		log.Fatalf("创建插件加载器失败: %v", err)
 	}
 
 	// 启动加载器
@@ -98,6 +113,76 @@ func main() {
 
 	// 创建 HTTP 服务器
 	server := api.NewHttpServer(manager, *addr)
+	server.SetLoader(loader)
+	// 远程智能体协议：其他部署通过 RemoteAgent 以 Bearer 令牌调用本服务的工作流
+	server.SetRemoteTokens(cfg.Remote.Tokens)
+	if len(cfg.Remote.Tokens) == 0 {
+		log.Printf("未配置 remote.tokens，远程智能体协议不可用")
+	}
+
+	// 配置热重载：模型池、日志级别与版本权重无需重启即可生效
+	reloader := config.NewReloader(configPath, cfg)
+	reloader.OnReload("model_api_pools", func(oldCfg, newCfg *config.Config) ([]string, error) {
+		res, err := models.ReloadModelPools(oldCfg, newCfg)
+		var changes []string
+		for _, name := range res.Added {
+			changes = append(changes, "新增 pool:"+name)
+		}
+		for _, name := range res.Updated {
+			changes = append(changes, "更新 pool:"+name)
+		}
+		for _, name := range res.Removed {
+			changes = append(changes, "删除 pool:"+name)
+		}
+		return changes, err
+	})
+	reloader.OnReload("log_level", func(oldCfg, newCfg *config.Config) ([]string, error) {
+		var changes []string
+		if newCfg.LogLevel != logger.Level() {
+			if err := logger.SetLevel(newCfg.LogLevel); err != nil {
+				return nil, err
+			}
+			changes = append(changes, "日志级别调整为 "+newCfg.LogLevel)
+		}
+		if newCfg.LogDev != oldCfg.LogDev {
+			changes = append(changes, "log_dev 变更需重启后生效")
+		}
+		return changes, nil
+	})
+	reloader.OnReload("flow_versions", func(oldCfg, newCfg *config.Config) ([]string, error) {
+		var changes []string
+		for name, weights := range newCfg.FlowVersions {
+			if reflect.DeepEqual(oldCfg.FlowVersions[name], weights) {
+				continue
+			}
+			if err := manager.SetWeights(name, weights); err != nil {
+				return changes, err
+			}
+			changes = append(changes, "更新 "+name+" 版本权重")
+		}
+		for name := range oldCfg.FlowVersions {
+			if _, ok := newCfg.FlowVersions[name]; !ok {
+				manager.SetWeights(name, nil)
+				changes = append(changes, "清除 "+name+" 版本权重")
+			}
+		}
+		return changes, nil
+	})
+	reloader.OnReload("remote", func(oldCfg, newCfg *config.Config) ([]string, error) {
+		if reflect.DeepEqual(oldCfg.Remote.Tokens, newCfg.Remote.Tokens) {
+			return nil, nil
+		}
+		server.SetRemoteTokens(newCfg.Remote.Tokens)
+		return []string{"更新远程协议令牌"}, nil
+	})
+	reloader.OnReload("host", func(oldCfg, newCfg *config.Config) ([]string, error) {
+		host.SetConfig(newCfg)
+		return nil, nil
+	})
+	if err := reloader.Start(); err != nil {
+		log.Printf("监听配置文件失败，热重载不可用: %v", err)
+	}
+	server.SetConfigReloader(reloader)
 
 	// 处理优雅关闭
 	sigCh := make(chan os.Signal, 1)
@@ -114,6 +199,10 @@ func main() {
 
 	// 重新根据配置初始化结构化日志
 	_, _ = logger.Init(cfg.LogLevel, cfg.LogDev)
+	// Init 仅首次调用生效，插件加载时可能已按默认级别初始化，这里确保配置的级别生效
+	if err := logger.SetLevel(cfg.LogLevel); err != nil {
+		log.Printf("设置日志级别失败: %v", err)
+	}
 
 	logger.S().Infow("API 服务启动完成", "workflows", manager.ListNames())
 
@@ -128,6 +217,8 @@ func main() {
 	if err := server.Stop(shutdownCtx); err != nil {
 		log.Fatalf("服务器关闭失败: %v", err)
 	}
+	loader.Stop()
+	reloader.Stop()
 
 	log.Println("服务器已关闭")
 }
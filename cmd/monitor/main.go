@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -34,8 +35,7 @@ func main() {
 		log.Fatalf("创建插件加载器失败: %v", err)
 	}
 	loader.Start()
-	// 注意：存在内存泄漏风险，如果有 Stop 方法应该调用
-	// 在真实生产代码中，应该实现 Stop 方法来清理资源
+	defer loader.Stop()
 	
 	// 每秒输出当前已加载的插件列表
 	ticker := time.NewTicker(time.Second)
@@ -62,6 +62,15 @@ func main() {
 				fmt.Println("<无已加载插件>")
 			}
 			fmt.Println("==================")
+			fmt.Println("插件文件状态:")
+			for _, st := range loader.Status() {
+				line := fmt.Sprintf("  %-30s %-10s %s@%s", filepath.Base(st.Path), st.State, st.Flow, st.Version)
+				if st.Error != "" {
+					line += "  错误: " + st.Error
+				}
+				fmt.Println(line)
+			}
+			fmt.Println("==================")
 			fmt.Println("等待插件更新...")
 		case <-sigCh:
 			log.Println("收到退出信号，停止监控")
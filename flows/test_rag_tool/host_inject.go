@@ -0,0 +1,15 @@
+package main
+
+import (
+    "github.com/nvcnvn/adk-golang/pkg/flow"
+    "github.com/nvcnvn/adk-golang/pkg/tools/vector_rag_tool"
+)
+
+// Init 由 Loader 在 Build 之前调用，复用宿主配置的 RAG 记忆服务。
+func Init(host flow.Host) error {
+    plgLog = host.Logger()
+    if mem, err := host.Memory("custom_rag"); err == nil {
+        vector_rag_tool.WithMemory(mem)
+    }
+    return nil
+}
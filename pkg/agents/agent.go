@@ -18,15 +18,16 @@ package agents
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"strings"
 	"sync"
 
 	"github.com/nvcnvn/adk-golang/pkg/events"
 	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/schema"
 	"github.com/nvcnvn/adk-golang/pkg/telemetry"
 	"github.com/nvcnvn/adk-golang/pkg/tools"
+	"github.com/nvcnvn/adk-golang/pkg/types"
 )
 
 // BeforeAgentCallback is a function that's called before an agent processes a message
@@ -35,6 +36,9 @@ type BeforeAgentCallback func(ctx context.Context, message string) (string, bool
 // AfterAgentCallback is a function that's called after an agent processes a message
 type AfterAgentCallback func(ctx context.Context, response string) string
 
+// ProcessFunc produces an agent response in place of the model call
+type ProcessFunc func(ctx context.Context, message string) (string, error)
+
 // BaseAgent defines the interface for all agents
 type BaseAgent interface {
 	// Name returns the name of the agent
@@ -53,78 +57,6 @@ type BaseAgent interface {
 	FindAgent(name string) BaseAgent
 }
 
-// LlmAgent is a specialized agent that uses an LLM model
-type LlmAgent struct {
-	// name is the name of the agent
-	name string
-
-	// SystemInstructions contain system instructions for the LLM
-	SystemInstructions string
-
-	// CanonicalModel is the LLM model used by this agent
-	CanonicalModel models.BaseLlm
-
-	// CanonicalTools are the tools available to this agent
-	CanonicalTools []tools.Tool
-
-	// BeforeModelCallback is called before the model is invoked
-	BeforeModelCallback func(callbackContext *CallbackContext, llmRequest *models.LlmRequest) *models.LlmResponse
-
-	// AfterModelCallback is called after the model responds
-	AfterModelCallback func(callbackContext *CallbackContext, llmResponse *models.LlmResponse) *models.LlmResponse
-
-	// parentAgent is the parent of this agent
-	parentAgent BaseAgent
-}
-
-// NewLlmAgent creates a new LLM-based agent
-func NewLlmAgent(name string, model models.BaseLlm) *LlmAgent {
-	return &LlmAgent{
-		name:           name,
-		CanonicalModel: model,
-		CanonicalTools: make([]tools.Tool, 0),
-	}
-}
-
-// Name returns the name of the agent
-func (a *LlmAgent) Name() string {
-	return a.name
-}
-
-// Run executes the agent with the given invocation context
-func (a *LlmAgent) Run(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
-	// This would be implemented based on the flows
-	// For now, return an empty channel
-	eventCh := make(chan *events.Event)
-	close(eventCh)
-	return eventCh, nil
-}
-
-// RunLive executes the agent in live mode with the given invocation context
-func (a *LlmAgent) RunLive(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
-	// This would be implemented based on the flows
-	// For now, return an empty channel
-	eventCh := make(chan *events.Event)
-	close(eventCh)
-	return eventCh, nil
-}
-
-// RootAgent returns the root agent in the agent tree
-func (a *LlmAgent) RootAgent() BaseAgent {
-	if a.parentAgent == nil {
-		return a
-	}
-	return a.parentAgent.RootAgent()
-}
-
-// FindAgent finds an agent by name in the agent tree
-func (a *LlmAgent) FindAgent(name string) BaseAgent {
-	if a.name == name {
-		return a
-	}
-	return nil
-}
-
 // Agent represents an AI agent that can process user inputs and generate responses.
 type Agent struct {
 	name        string
@@ -139,6 +71,36 @@ type Agent struct {
 	beforeAgentCallback BeforeAgentCallback
 	afterAgentCallback  AfterAgentCallback
 
+	// processFunc replaces the model call when set
+	processFunc ProcessFunc
+
+	// kind is set by the composite agents, see Kind
+	kind string
+
+	// toolMode selects native function calling or the text protocol
+	toolMode ToolMode
+
+	// fallbackModels are tried in order when the model fails
+	fallbackModels []string
+	// retryPolicy controls retries of failed model calls
+	retryPolicy RetryPolicy
+
+	// outputSchema is the schema the output must match, nil for free text
+	outputSchema *schema.Schema
+	// outputRepairs is the number of repair prompts for invalid output
+	outputRepairs int
+
+	// withHistory sends the history carried by the context before the message
+	withHistory bool
+	// historyFilters select the history entries
+	historyFilters []HistoryFilter
+
+	// instructionTemplate renders the instruction, nil when it has no
+	// placeholders or failed to parse
+	instructionTemplate *InstructionTemplate
+	// instructionErr is the parse error of the instruction
+	instructionErr error
+
 	// Additional fields that may be needed
 	registry *agentRegistry
 }
@@ -155,6 +117,27 @@ type Config struct {
 	// Callbacks
 	BeforeAgentCallback BeforeAgentCallback
 	AfterAgentCallback  AfterAgentCallback
+
+	// ProcessFunc replaces the model call, see WithProcessFunc.
+	ProcessFunc ProcessFunc
+
+	// ToolMode selects how tools are offered to the model, see WithToolMode.
+	ToolMode ToolMode
+
+	// FallbackModels are tried in order when Model fails, see WithFallbackModels.
+	FallbackModels []string
+	// RetryPolicy controls retries of failed model calls, see WithRetryPolicy.
+	RetryPolicy RetryPolicy
+
+	// OutputSchema is the JSON Schema of the output, see WithOutputSchema.
+	OutputSchema *schema.Schema
+	// OutputRepairs bounds the repair prompts, see WithOutputRepairs.
+	OutputRepairs int
+
+	// History sends the conversation history on the context, see WithHistory.
+	History bool
+	// HistoryFilters select the history entries, see WithHistory.
+	HistoryFilters []HistoryFilter
 }
 
 // Option defines a function type for configuring an agent.
@@ -174,7 +157,9 @@ func WithModel(model string) Option {
 	}
 }
 
-// WithInstruction sets the instruction for the agent.
+// WithInstruction sets the instruction for the agent. It may contain
+// placeholders resolved at call time, see InstructionTemplate; they are
+// validated by NewAgent.
 func WithInstruction(instruction string) Option {
 	return func(c *Config) {
 		c.Instruction = instruction
@@ -216,10 +201,75 @@ func WithAfterAgentCallback(callback AfterAgentCallback) Option {
 	}
 }
 
+// WithProcessFunc sets a function that produces the response instead of the
+// model call. Before/after callbacks still run around it and its error is
+// returned from Process, which makes it suitable for agents backed by external
+// services or processes.
+func WithProcessFunc(fn ProcessFunc) Option {
+	return func(c *Config) {
+		c.ProcessFunc = fn
+	}
+}
+
+// WithToolMode sets how tools are offered to the model. The default,
+// ToolModeAuto, uses native function calling when the model supports it.
+func WithToolMode(mode ToolMode) Option {
+	return func(c *Config) {
+		c.ToolMode = mode
+	}
+}
+
+// WithFallbackModels sets the models tried in order when the model cannot be
+// resolved or still fails after the retries of the retry policy.
+func WithFallbackModels(modelNames ...string) Option {
+	return func(c *Config) {
+		c.FallbackModels = modelNames
+	}
+}
+
+// WithRetryPolicy sets how failed model calls are retried before falling back
+// to the next model.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Config) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithOutputSchema makes the agent answer with JSON matching s. The schema is
+// added to the system prompt and every response is validated; an invalid
+// response is sent back to the model with the validation errors, see
+// WithOutputRepairs, and ProcessJSON decodes the valid one.
+func WithOutputSchema(s *schema.Schema) Option {
+	return func(c *Config) {
+		c.OutputSchema = s
+	}
+}
+
+// WithOutputRepairs sets how many times an output that does not match the
+// output schema is sent back to the model, DefaultOutputRepairs by default.
+// Use 0 to disable repairs.
+func WithOutputRepairs(n int) Option {
+	return func(c *Config) {
+		c.OutputRepairs = n
+	}
+}
+
+// WithHistory makes the agent send the conversation history carried by the
+// context, see WithSession and WithHistoryMessages, between the system message
+// and the message. filters select the entries, e.g. OnlyOwnTurns() or
+// LastTurns(5); turns of other agents are passed as context in user messages.
+func WithHistory(filters ...HistoryFilter) Option {
+	return func(c *Config) {
+		c.History = true
+		c.HistoryFilters = filters
+	}
+}
+
 // NewAgent creates a new agent with the provided options.
 func NewAgent(options ...Option) *Agent {
 	config := &Config{
-		Model: "gemini-1.5-pro", // Default model
+		Model:         "gemini-1.5-pro", // Default model
+		OutputRepairs: DefaultOutputRepairs,
 	}
 
 	for _, option := range options {
@@ -241,22 +291,95 @@ func NewAgent(options ...Option) *Agent {
 		subAgents:           config.SubAgents,
 		beforeAgentCallback: config.BeforeAgentCallback,
 		afterAgentCallback:  config.AfterAgentCallback,
+		processFunc:         config.ProcessFunc,
+		toolMode:            config.ToolMode,
+		fallbackModels:      config.FallbackModels,
+		retryPolicy:         config.RetryPolicy,
+		outputSchema:        config.OutputSchema,
+		outputRepairs:       config.OutputRepairs,
+		withHistory:         config.History,
+		historyFilters:      config.HistoryFilters,
+	}
+
+	// Validate the instruction template once, a malformed one fails every call
+	if tmpl, err := ParseInstruction(config.Instruction); err != nil {
+		telemetry.Logger.Printf("Warning: agent %s: %v", config.Name, err)
+		agent.instructionErr = err
+	} else if !tmpl.IsStatic() {
+		agent.instructionTemplate = tmpl
 	}
 
 	// Set parent agent for sub-agents
-	for _, subAgent := range agent.subAgents {
+	agent.adoptSubAgents()
+
+	return agent
+}
+
+// adoptSubAgents sets this agent as the parent of its sub-agents.
+func (a *Agent) adoptSubAgents() {
+	for _, subAgent := range a.subAgents {
 		// Validate sub-agent hierarchy
-		if err := ValidateAgentHierarchy(subAgent, agent); err != nil {
+		if err := ValidateAgentHierarchy(subAgent, a); err != nil {
 			telemetry.Logger.Printf("Warning: %v", err)
 		}
-		subAgent.parentAgent = agent
+		subAgent.parentAgent = a
 	}
-
-	return agent
 }
 
-// Process handles a user message and generates a response.
+// Process handles a user message and generates a response. It runs the same
+// path as Run and returns the text of the final event; without an invocation
+// context on ctx a new one without a MaxLlmCalls limit is created for the
+// call. Inside a run, e.g. for the sub-agents of a composite agent, the events
+// are recorded on the invocation context of the run.
 func (a *Agent) Process(ctx context.Context, message string) (string, error) {
+	if InvocationContextFromContext(ctx) != nil {
+		resp, err := a.Respond(ctx, message)
+		if err != nil {
+			return "", err
+		}
+		return resp.Text, nil
+	}
+
+	ic := NewInvocationContext(events.GenerateID(), a, &types.RunConfig{StreamingMode: types.StreamingModeNone})
+	final, err := a.invoke(ctx, ic, message)
+	if err != nil {
+		return "", err
+	}
+	return final.Content.GetText(), nil
+}
+
+// Respond handles a user message like Process and also reports which model
+// produced the response. With an output schema the response is validated and
+// repaired, see WithOutputSchema. The AgentStarted and AgentFinished events of
+// the agent are recorded around the response.
+func (a *Agent) Respond(ctx context.Context, message string) (*Response, error) {
+	ctx = a.enter(ctx)
+	emitEvent(ctx, events.AgentStarted, func(event *events.Event) {
+		event.Content = textContent(message, "user")
+	})
+
+	var (
+		resp *Response
+		err  error
+	)
+	if a.outputSchema != nil {
+		resp, err = a.respondStructured(ctx, message)
+	} else {
+		resp, err = a.respond(ctx, message)
+	}
+
+	emitEvent(ctx, events.AgentFinished, func(event *events.Event) {
+		if err != nil {
+			event.ErrorMessage = err.Error()
+			return
+		}
+		event.Content = textContent(resp.Text, "model")
+	})
+	return resp, err
+}
+
+// respond produces a single response to message.
+func (a *Agent) respond(ctx context.Context, message string) (*Response, error) {
 	// Create a span for tracking this processing
 	ctx, span := telemetry.StartSpan(ctx, "Agent.Process")
 	defer span.End()
@@ -268,92 +391,90 @@ func (a *Agent) Process(ctx context.Context, message string) (string, error) {
 	// Run before agent callback if present
 	if a.beforeAgentCallback != nil {
 		if result, skipProcessing := a.beforeAgentCallback(ctx, message); skipProcessing {
-			return result, nil
+			return &Response{Text: result}, nil
 		}
 	}
 
-	// Get the model from the basic registry first
-	modelRegistry := models.GetRegistry()
-	model, ok := modelRegistry.Get(a.model)
-
-	// Fallback: try enhanced registry (regex based)
-	if !ok {
-		enhancedReg := models.GetEnhancedRegistry()
-		if m, err := enhancedReg.GetModel(a.model); err == nil {
-			model = m
-			ok = true
-			// Cache into the basic registry for quicker future lookup
-			modelRegistry.Register(model)
+	// Custom process function takes the place of the model call
+	if a.processFunc != nil {
+		response, err := a.processFunc(ctx, message)
+		if err != nil {
+			span.SetAttribute("error", err.Error())
+			return nil, err
 		}
+		if a.afterAgentCallback != nil {
+			response = a.afterAgentCallback(ctx, response)
+		}
+		return &Response{Text: response}, nil
+	}
+
+	// Call the model, falling back to the next model of the chain on failure
+	resp, err := a.generate(ctx, message)
+	if err != nil {
+		span.SetAttribute("error", err.Error())
+		return nil, err
 	}
 
-	if !ok {
-		// Fall back to mock implementation if model is not available
-		span.SetAttribute("model.fallback", "true")
-		return "I'm sorry, but the requested model is not available. (This is a placeholder response.)", nil
+	span.SetAttribute("model.answered", resp.Model)
+	span.SetAttribute("output.length", fmt.Sprintf("%d", len(resp.Text)))
+
+	// Run after agent callback if present
+	if a.afterAgentCallback != nil {
+		resp.Text = a.afterAgentCallback(ctx, resp.Text)
 	}
 
-	// Prepare messages
+	return resp, nil
+}
+
+// renderInstruction resolves the placeholders of the instruction.
+func (a *Agent) renderInstruction(ctx context.Context) (string, error) {
+	err := a.instructionErr
+	instruction := a.instruction
+	if err == nil && a.instructionTemplate != nil {
+		instruction, err = a.instructionTemplate.Render(ctx)
+	}
+	if err != nil {
+		var tmplErr *TemplateError
+		if errors.As(err, &tmplErr) {
+			withAgent := *tmplErr
+			withAgent.Agent = a.name
+			return "", &withAgent
+		}
+		return "", err
+	}
+	return instruction, nil
+}
+
+// buildMessages prepares the system message, the history and the user
+// message for model.
+func (a *Agent) buildMessages(ctx context.Context, model models.Model, instruction, message string) []models.Message {
 	msgs := []models.Message{
 		{
 			Role:    "system",
-			Content: a.instruction,
-		},
-		{
-			Role:    "user",
-			Content: message,
+			Content: instruction + a.outputInstruction(),
 		},
 	}
+	msgs = append(msgs, a.history(ctx, message)...)
+	msgs = append(msgs, models.Message{
+		Role:    "user",
+		Content: message,
+	})
 
-	// If there are tools, add them to the system message
-	if len(a.tools) > 0 {
+	// If there are tools and the model cannot take them natively, add them and
+	// the calling protocol to the system message
+	if _, native := a.toolCallingModel(model); len(a.tools) > 0 && !native {
 		toolsJSON, err := json.Marshal(a.getToolDefinitions())
 		if err == nil {
 			msgs = append([]models.Message{
 				{
 					Role: "system",
-					Content: fmt.Sprintf("%s\n\nYou have access to the following tools: %s",
-						a.instruction, string(toolsJSON)),
+					Content: fmt.Sprintf("%s\n\nYou have access to the following tools: %s\n\n%s",
+						instruction+a.outputInstruction(), string(toolsJSON), toolProtocol),
 				},
 			}, msgs[1:]...)
 		}
 	}
-
-	// 检查上下文是否已取消，避免不必要的模型调用
-	select {
-	case <-ctx.Done():
-		log.Printf("[Agent] 模型调用被上下文取消，agent: %s, 原因: %v", a.name, ctx.Err())
-		span.SetAttribute("context_canceled", "true")
-		return "", ctx.Err()
-	default:
-		// 继续执行
-	}
-
-	// Generate response
-	log.Printf("[Agent] 开始模型调用，agent: %s, 模型: %s", a.name, a.model)
-	response, err := model.Generate(ctx, msgs)
-	log.Printf("[Agent] 模型调用完成，agent: %s, 成功: %v", a.name, err == nil)
-	if err != nil {
-		span.SetAttribute("error", err.Error())
-		return "", err
-	}
-
-	// Parse and execute function calls if present
-	response, err = a.processFunctionCalls(ctx, response)
-	if err != nil {
-		span.SetAttribute("function_call_error", err.Error())
-		log.Printf("[Agent] 工具调用失败，agent: %s, 错误: %v", a.name, err)
-		// Continue with original response instead of failing
-	}
-
-	span.SetAttribute("output.length", fmt.Sprintf("%d", len(response)))
-
-	// Run after agent callback if present
-	if a.afterAgentCallback != nil {
-		response = a.afterAgentCallback(ctx, response)
-	}
-
-	return response, nil
+	return msgs
 }
 
 // RootAgent returns the root agent in the hierarchy
@@ -404,70 +525,6 @@ func (a *Agent) getToolDefinitions() []map[string]interface{} {
 	return defs
 }
 
-// processFunctionCalls parses model response for function calls and executes them
-func (a *Agent) processFunctionCalls(ctx context.Context, response string) (string, error) {
-	lines := strings.Split(strings.TrimSpace(response), "\n")
-	var result strings.Builder
-	var foundFunctionCalls bool
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Try to parse as tool call JSON
-		var toolCall struct {
-			ToolName   string                 `json:"tool_name"`
-			Parameters map[string]interface{} `json:"parameters"`
-		}
-
-		if err := json.Unmarshal([]byte(line), &toolCall); err == nil && toolCall.ToolName != "" {
-			foundFunctionCalls = true
-			log.Printf("[Agent] 检测到工具调用: %s", toolCall.ToolName)
-
-			// Find the corresponding tool
-			var targetTool tools.Tool
-			for _, tool := range a.tools {
-				if tool.Name() == toolCall.ToolName {
-					targetTool = tool
-					break
-				}
-			}
-
-			if targetTool == nil {
-				result.WriteString(fmt.Sprintf("错误：未找到工具 '%s'\n", toolCall.ToolName))
-				continue
-			}
-
-			// Execute the tool
-			toolResult, err := targetTool.Execute(ctx, toolCall.Parameters)
-			if err != nil {
-				result.WriteString(fmt.Sprintf("工具 '%s' 执行失败: %v\n", toolCall.ToolName, err))
-				continue
-			}
-
-			// Format the result
-			resultJSON, _ := json.MarshalIndent(toolResult, "", "  ")
-			result.WriteString(fmt.Sprintf("工具 '%s' 执行成功:\n%s\n\n", toolCall.ToolName, string(resultJSON)))
-		} else {
-			// Not a tool call, include as regular text
-			if result.Len() > 0 {
-				result.WriteString("\n")
-			}
-			result.WriteString(line)
-		}
-	}
-
-	if foundFunctionCalls {
-		log.Printf("[Agent] 工具调用处理完成，agent: %s", a.name)
-		return result.String(), nil
-	}
-
-	// No function calls found, return original response
-	return response, nil
-}
-
 // Name returns the name of the agent.
 func (a *Agent) Name() string {
 	return a.name
@@ -503,6 +560,11 @@ func (a *Agent) SetAfterAgentCallback(cb AfterAgentCallback) {
 	a.afterAgentCallback = cb
 }
 
+// SetProcessFunc allows setting the process function after creation.
+func (a *Agent) SetProcessFunc(fn ProcessFunc) {
+	a.processFunc = fn
+}
+
 // SubAgents returns the sub-agents of this agent.
 func (a *Agent) SubAgents() []*Agent {
 	return a.subAgents
@@ -513,7 +575,11 @@ func (a *Agent) ParentAgent() *Agent {
 	return a.parentAgent
 }
 
-// Run executes the agent with the given invocation context
+// Run executes the agent with the given invocation context. It is the primary
+// execution path: the channel receives the events of the whole agent tree as
+// they happen (agent start and finish, model calls, tool calls and results,
+// partial text, state updates) and ends with a FinalResponse or RunFailed
+// event, see events.EventType.
 func (a *Agent) Run(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	invocationContext.SetCancelFunc(cancel)
@@ -527,9 +593,19 @@ func (a *Agent) Run(ctx context.Context, invocationContext *InvocationContext) (
 	span.SetAttribute("agent.name", a.name)
 	span.SetAttribute("agent.model", a.model)
 
+	// Events recorded during the run are streamed as they happen
+	ctx = WithInvocationContext(ctx, invocationContext)
+	invocationContext.setEventSink(func(event *events.Event) {
+		select {
+		case eventCh <- event:
+		case <-ctx.Done():
+		}
+	})
+
 	// Start a goroutine to handle events
 	go func() {
 		defer close(eventCh)
+		defer invocationContext.setEventSink(nil)
 
 		if invocationContext.InvocationEvent != nil && invocationContext.InvocationEvent.Content != nil {
 			// Get user message from event if available
@@ -540,32 +616,9 @@ func (a *Agent) Run(ctx context.Context, invocationContext *InvocationContext) (
 				}
 			}
 
-			// Process the user message
-			response, err := a.Process(ctx, userMsg)
-			if err != nil {
-				// Send error event
-				eventCh <- &events.Event{
-					InvocationID: invocationContext.InvocationID,
-					Author:       a.name,
-					Content: &events.Content{
-						Parts: []*models.Part{
-							{Text: fmt.Sprintf("Error processing message: %v", err)},
-						},
-					},
-				}
-				return
-			}
-
-			// Send response event
-			eventCh <- &events.Event{
-				InvocationID: invocationContext.InvocationID,
-				Author:       a.name,
-				Content: &events.Content{
-					Parts: []*models.Part{
-						{Text: response},
-					},
-				},
-			}
+			// Every step is streamed through the event sink, ending with the
+			// final response or the error
+			_, _ = a.invoke(ctx, invocationContext, userMsg)
 		}
 	}()
 
@@ -0,0 +1,305 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+)
+
+// ApprovalRequested is the type of the event recorded when an ApprovalAgent
+// pauses the run. The approval ID is its only LongRunningToolIDs entry and
+// its content is a RequestApprovalFunction call with the PendingApproval as
+// arguments.
+const ApprovalRequested events.EventType = "approval_requested"
+
+// RequestApprovalFunction is the name of the long-running function call of an
+// ApprovalRequested event. The decision answers it as a function response
+// with the same ID.
+const RequestApprovalFunction = "request_approval"
+
+// ErrorCodeApprovalPending is the error code of the RunFailed event of a run
+// paused by an ApprovalAgent.
+const ErrorCodeApprovalPending = "approval_pending"
+
+// ApprovalKind selects what an ApprovalAgent asks the human for.
+type ApprovalKind string
+
+const (
+	// ApprovalKindApprove asks to approve, edit or reject the input of the
+	// step.
+	ApprovalKindApprove ApprovalKind = "approve"
+
+	// ApprovalKindInput asks for a text that becomes the output of the step,
+	// such as the answer to a question from the model.
+	ApprovalKindInput ApprovalKind = "input"
+)
+
+var (
+	// ErrApprovalPending is matched by the *ApprovalPendingError that pauses
+	// a run.
+	ErrApprovalPending = errors.New("waiting for human approval")
+
+	// ErrApprovalRejected is matched by the *ApprovalRejectedError of a
+	// rejected step.
+	ErrApprovalRejected = errors.New("approval rejected")
+)
+
+// PendingApproval describes a step waiting for a human.
+type PendingApproval struct {
+	// ID identifies the approval, it is the ID of the function call
+	ID string `json:"id"`
+	// Agent is the name of the ApprovalAgent
+	Agent string `json:"agent"`
+	// Branch is the branch of the ApprovalAgent in the run
+	Branch string       `json:"branch"`
+	Kind   ApprovalKind `json:"kind"`
+	// Prompt tells the human what is asked
+	Prompt string `json:"prompt,omitempty"`
+	// Content is the input of the step, e.g. the outline to approve
+	Content string `json:"content"`
+}
+
+// ApprovalDecision is the answer of the human to a PendingApproval.
+type ApprovalDecision struct {
+	// Approved accepts the content, ignored for ApprovalKindInput
+	Approved bool `json:"approved"`
+	// Content replaces the content of the step: the edited content of an
+	// approval or the text of an input step
+	Content string `json:"content,omitempty"`
+	// Comment is kept with the decision and reported on rejection
+	Comment string `json:"comment,omitempty"`
+}
+
+// ApprovalPendingError is returned by an ApprovalAgent that paused the run.
+type ApprovalPendingError struct {
+	Approval *PendingApproval
+}
+
+// Error implements error.
+func (e *ApprovalPendingError) Error() string {
+	return fmt.Sprintf("agent %s: %v (approval %s)", e.Approval.Agent, ErrApprovalPending, e.Approval.ID)
+}
+
+// Unwrap returns ErrApprovalPending.
+func (e *ApprovalPendingError) Unwrap() error {
+	return ErrApprovalPending
+}
+
+// ApprovalRejectedError is returned by an ApprovalAgent resumed with a
+// rejection.
+type ApprovalRejectedError struct {
+	Agent   string
+	Comment string
+}
+
+// Error implements error.
+func (e *ApprovalRejectedError) Error() string {
+	if e.Comment == "" {
+		return fmt.Sprintf("agent %s: %v", e.Agent, ErrApprovalRejected)
+	}
+	return fmt.Sprintf("agent %s: %v: %s", e.Agent, ErrApprovalRejected, e.Comment)
+}
+
+// Unwrap returns ErrApprovalRejected.
+func (e *ApprovalRejectedError) Unwrap() error {
+	return ErrApprovalRejected
+}
+
+// ApprovalAgent pauses the run until a human approves, edits or rejects its
+// input, or provides the text of an input step.
+type ApprovalAgent struct {
+	Agent
+	approvalKind ApprovalKind
+	prompt       string
+}
+
+// ApprovalAgentConfig holds configuration for creating an ApprovalAgent.
+type ApprovalAgentConfig struct {
+	Name        string
+	Description string
+
+	// Kind is ApprovalKindApprove by default.
+	Kind ApprovalKind
+	// Prompt tells the human what is asked, e.g. "Approve the outline".
+	Prompt string
+}
+
+// NewApprovalAgent creates a new agent that waits for a human decision.
+//
+// Without a decision the agent records an ApprovalRequested event and
+// returns an *ApprovalPendingError, which ends the run. The run is resumed by
+// running it again with WithApprovalResume: sequential agents on the way to
+// the approval skip the steps that already ran, and the approval step returns
+// the approved or edited content, or the input of the human, as its output.
+func NewApprovalAgent(config ApprovalAgentConfig) *ApprovalAgent {
+	kind := config.Kind
+	if kind == "" {
+		kind = ApprovalKindApprove
+	}
+	agent := &ApprovalAgent{
+		Agent: Agent{
+			name:        config.Name,
+			description: config.Description,
+			kind:        AgentKindApproval,
+		},
+		approvalKind: kind,
+		prompt:       config.Prompt,
+	}
+	agent.processFunc = agent.run
+	return agent
+}
+
+// run applies the decision of a resumed run or pauses the run.
+func (a *ApprovalAgent) run(ctx context.Context, message string) (string, error) {
+	branch := currentFrame(ctx).branch
+	if r := approvalResumeFromContext(ctx); r != nil && r.Approval.Branch == branch && r.used.CompareAndSwap(false, true) {
+		return a.decide(message, r.Decision)
+	}
+
+	approval := &PendingApproval{
+		ID:      uuid.New().String(),
+		Agent:   a.name,
+		Branch:  branch,
+		Kind:    a.approvalKind,
+		Prompt:  a.prompt,
+		Content: message,
+	}
+	args, _ := json.Marshal(approval)
+	emitEvent(ctx, ApprovalRequested, func(event *events.Event) {
+		event.LongRunningToolIDs = []string{approval.ID}
+		event.Content = &events.Content{Parts: []*models.Part{{
+			Role:         "model",
+			FunctionCall: &models.FunctionCall{Name: RequestApprovalFunction, Arguments: string(args), ID: approval.ID},
+		}}}
+	})
+	return "", &ApprovalPendingError{Approval: approval}
+}
+
+// decide returns the output of the step for decision.
+func (a *ApprovalAgent) decide(message string, decision ApprovalDecision) (string, error) {
+	if a.approvalKind == ApprovalKindInput {
+		if decision.Content == "" {
+			return "", fmt.Errorf("agent %s: input step resumed without content", a.name)
+		}
+		return decision.Content, nil
+	}
+	if !decision.Approved {
+		return "", &ApprovalRejectedError{Agent: a.name, Comment: decision.Comment}
+	}
+	if decision.Content != "" {
+		return decision.Content, nil
+	}
+	return message, nil
+}
+
+// ApprovalKind returns what the agent asks the human for.
+func (a *ApprovalAgent) ApprovalKind() ApprovalKind {
+	return a.approvalKind
+}
+
+// Prompt returns the prompt shown to the human.
+func (a *ApprovalAgent) Prompt() string {
+	return a.prompt
+}
+
+// ApprovalResume resumes a run paused by an ApprovalAgent, see
+// WithApprovalResume.
+type ApprovalResume struct {
+	// Approval is the approval the run is waiting for
+	Approval PendingApproval
+	// Decision is the answer of the human
+	Decision ApprovalDecision
+	// Inputs are the inputs of the agents of the paused run by branch, see
+	// RecordedInputs. Sequential agents pass them to the step they resume
+	// from.
+	Inputs map[string]string
+
+	// used is set once the approval has taken the decision
+	used atomic.Bool
+}
+
+// approvalResumeKey is the context key for the ApprovalResume.
+type approvalResumeKey struct{}
+
+// WithApprovalResume makes the run of ctx resume from the approval of r. The
+// run must be started again from the same agent with the same input.
+func WithApprovalResume(ctx context.Context, r *ApprovalResume) context.Context {
+	return context.WithValue(ctx, approvalResumeKey{}, r)
+}
+
+// approvalResumeFromContext returns the ApprovalResume attached to ctx, or nil.
+func approvalResumeFromContext(ctx context.Context) *ApprovalResume {
+	r, _ := ctx.Value(approvalResumeKey{}).(*ApprovalResume)
+	return r
+}
+
+// resumeStep returns the index of the sub-agent of the agent at branch that
+// leads to the approval, with its recorded input. It returns 0 and message
+// when the approval is not below branch or has already taken the decision.
+func (r *ApprovalResume) resumeStep(branch string, subAgents []*Agent, message string) (int, string) {
+	if r.used.Load() || !strings.HasPrefix(r.Approval.Branch, branch+".") {
+		return 0, message
+	}
+	next, _, _ := strings.Cut(strings.TrimPrefix(r.Approval.Branch, branch+"."), ".")
+	for i, subAgent := range subAgents {
+		if subAgent.Name() != next {
+			continue
+		}
+		if input, ok := r.Inputs[branch+"."+next]; ok {
+			message = input
+		}
+		return i, message
+	}
+	return 0, message
+}
+
+// RecordedInputs returns the input of every agent of a run by branch, read
+// from the AgentStarted events; the last input wins for agents that ran
+// several times.
+func RecordedInputs(evts []*events.Event) map[string]string {
+	inputs := make(map[string]string)
+	for _, event := range evts {
+		if event.Type == events.AgentStarted && event.Branch != "" {
+			inputs[event.Branch] = event.Content.GetText()
+		}
+	}
+	return inputs
+}
+
+// PendingApprovalFromEvents returns the approval requested by the last
+// ApprovalRequested event of evts, or nil.
+func PendingApprovalFromEvents(evts []*events.Event) *PendingApproval {
+	for i := len(evts) - 1; i >= 0; i-- {
+		if evts[i].Type != ApprovalRequested {
+			continue
+		}
+		for _, fc := range evts[i].GetFunctionCalls() {
+			var approval PendingApproval
+			if fc.Name == RequestApprovalFunction && json.Unmarshal([]byte(fc.Arguments), &approval) == nil {
+				return &approval
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApprovalPauseAndResume(t *testing.T) {
+	outlines := 0
+	outline := NewAgent(WithName("outline"), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		outlines++
+		return "outline of " + message, nil
+	}))
+	review := NewApprovalAgent(ApprovalAgentConfig{Name: "review", Prompt: "Approve the outline"})
+	writer := NewAgent(WithName("writer"), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		return strings.ToUpper(message), nil
+	}))
+	execution := NewSequentialAgent(SequentialAgentConfig{Name: "execution", Agents: []Runnable{review, writer}})
+	pipeline := NewSequentialAgent(SequentialAgentConfig{Name: "pipeline", Agents: []Runnable{outline, execution}})
+
+	// 首次运行在审批步骤暂停
+	ic := NewInvocationContext("inv", pipeline, nil)
+	_, err := pipeline.Process(WithInvocationContext(context.Background(), ic), "a dragon")
+	var pending *ApprovalPendingError
+	if !errors.As(err, &pending) || !errors.Is(err, ErrApprovalPending) {
+		t.Fatalf("Process() error = %v, want pending approval", err)
+	}
+	if pending.Approval.Branch != "pipeline.execution.review" || pending.Approval.Content != "outline of a dragon" {
+		t.Fatalf("approval = %+v", pending.Approval)
+	}
+	recorded := PendingApprovalFromEvents(ic.EventsSnapshot())
+	if recorded == nil || recorded.ID != pending.Approval.ID {
+		t.Fatalf("approval event = %+v", recorded)
+	}
+
+	resume := func(decision ApprovalDecision) (string, error) {
+		ctx := WithApprovalResume(context.Background(), &ApprovalResume{
+			Approval: *pending.Approval,
+			Decision: decision,
+			Inputs:   RecordedInputs(ic.EventsSnapshot()),
+		})
+		return pipeline.Process(ctx, "a dragon")
+	}
+
+	// 恢复时跳过已完成的步骤，修改后的内容作为审批步骤的输出
+	out, err := resume(ApprovalDecision{Approved: true, Content: "edited outline"})
+	if err != nil || out != "EDITED OUTLINE" || outlines != 1 {
+		t.Errorf("resume() = %q, %v (outlines %d)", out, err, outlines)
+	}
+	if out, err := resume(ApprovalDecision{Approved: true}); err != nil || out != "OUTLINE OF A DRAGON" {
+		t.Errorf("approve unchanged = %q, %v", out, err)
+	}
+	if _, err := resume(ApprovalDecision{Comment: "too short"}); !errors.Is(err, ErrApprovalRejected) || !strings.Contains(err.Error(), "too short") {
+		t.Errorf("reject error = %v", err)
+	}
+}
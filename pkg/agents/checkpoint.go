@@ -0,0 +1,219 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agents
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+)
+
+// StepRestored is the type of the event recorded when a step of a resumed
+// run is skipped and its checkpointed output is used instead. The content of
+// the event is the output.
+const StepRestored events.EventType = "step_restored"
+
+// Checkpoint is the output of a step of a composite agent that completed
+// successfully.
+type Checkpoint struct {
+	RunID string `json:"run_id"`
+	// Branch is the branch of the step, see events.Event.Branch
+	Branch    string    `json:"branch"`
+	Agent     string    `json:"agent"`
+	Input     string    `json:"input"`
+	Output    string    `json:"output"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CheckpointStore saves the checkpoints of runs.
+type CheckpointStore interface {
+	// SaveCheckpoint saves cp, replacing the checkpoint of the same run and
+	// branch.
+	SaveCheckpoint(ctx context.Context, cp *Checkpoint) error
+	// LoadCheckpoints returns the checkpoints of a run ordered by creation.
+	LoadCheckpoints(ctx context.Context, runID string) ([]*Checkpoint, error)
+	// DeleteCheckpoints removes the checkpoints of a run.
+	DeleteCheckpoints(ctx context.Context, runID string) error
+}
+
+// InMemoryCheckpointStore is a CheckpointStore that keeps the checkpoints in
+// memory.
+type InMemoryCheckpointStore struct {
+	mu   sync.RWMutex
+	runs map[string]map[string]*Checkpoint
+}
+
+// NewInMemoryCheckpointStore creates an empty in-memory checkpoint store.
+func NewInMemoryCheckpointStore() *InMemoryCheckpointStore {
+	return &InMemoryCheckpointStore{runs: make(map[string]map[string]*Checkpoint)}
+}
+
+// SaveCheckpoint implements CheckpointStore.
+func (s *InMemoryCheckpointStore) SaveCheckpoint(ctx context.Context, cp *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[cp.RunID]
+	if !ok {
+		run = make(map[string]*Checkpoint)
+		s.runs[cp.RunID] = run
+	}
+	saved := *cp
+	run[cp.Branch] = &saved
+	return nil
+}
+
+// LoadCheckpoints implements CheckpointStore.
+func (s *InMemoryCheckpointStore) LoadCheckpoints(ctx context.Context, runID string) ([]*Checkpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	checkpoints := make([]*Checkpoint, 0, len(s.runs[runID]))
+	for _, cp := range s.runs[runID] {
+		saved := *cp
+		checkpoints = append(checkpoints, &saved)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].CreatedAt.Before(checkpoints[j].CreatedAt)
+	})
+	return checkpoints, nil
+}
+
+// DeleteCheckpoints implements CheckpointStore.
+func (s *InMemoryCheckpointStore) DeleteCheckpoints(ctx context.Context, runID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, runID)
+	return nil
+}
+
+// Checkpointing saves the output of every step of the sequential, parallel and
+// graph agents of a run, see WithCheckpointing.
+type Checkpointing struct {
+	Store CheckpointStore
+	RunID string
+
+	// Resume skips the steps checkpointed by an earlier attempt of the run
+	// and uses their saved output. A step only counts as completed when it
+	// receives the same input as when it was checkpointed, so the steps after
+	// a step that produces a different output run again.
+	Resume bool
+	// Force lists the steps to run again when resuming, by agent name or
+	// branch. Forcing a composite step runs all of its steps again.
+	Force []string
+
+	loadOnce sync.Once
+	saved    map[string]*Checkpoint
+	loadErr  error
+}
+
+// checkpointingKey is the context key for the Checkpointing.
+type checkpointingKey struct{}
+
+// WithCheckpointing makes the sequential, parallel and graph agents of the run
+// of ctx checkpoint the output of their steps to c.Store. To resume a run, start it
+// again from the same agent with the same input and c.Resume set.
+func WithCheckpointing(ctx context.Context, c *Checkpointing) context.Context {
+	return context.WithValue(ctx, checkpointingKey{}, c)
+}
+
+// checkpointingFromContext returns the Checkpointing attached to ctx, or nil.
+func checkpointingFromContext(ctx context.Context) *Checkpointing {
+	c, _ := ctx.Value(checkpointingKey{}).(*Checkpointing)
+	return c
+}
+
+// restore returns the checkpoint of the step at branch if the run resumes,
+// the step is not forced and it completed with input before.
+func (c *Checkpointing) restore(ctx context.Context, branch, input string) (*Checkpoint, error) {
+	if !c.Resume || c.forced(branch) {
+		return nil, nil
+	}
+	c.loadOnce.Do(func() {
+		var checkpoints []*Checkpoint
+		checkpoints, c.loadErr = c.Store.LoadCheckpoints(ctx, c.RunID)
+		c.saved = make(map[string]*Checkpoint, len(checkpoints))
+		for _, cp := range checkpoints {
+			c.saved[cp.Branch] = cp
+		}
+	})
+	if c.loadErr != nil {
+		return nil, c.loadErr
+	}
+	if cp, ok := c.saved[branch]; ok && cp.Input == input {
+		return cp, nil
+	}
+	return nil, nil
+}
+
+// forced reports whether the step at branch, or a step it belongs to, is
+// listed in Force.
+func (c *Checkpointing) forced(branch string) bool {
+	segments := strings.Split(branch, ".")
+	for _, f := range c.Force {
+		if branch == f || strings.HasPrefix(branch, f+".") {
+			return true
+		}
+		for _, name := range segments {
+			if name == f {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runStep runs step with message on behalf of the composite agent running
+// ctx. With checkpointing the output of the step is saved, and a resumed run
+// reuses the saved output of a completed step instead of running it.
+func runStep(ctx context.Context, step *Agent, message string) (string, error) {
+	c := checkpointingFromContext(ctx)
+	if c == nil {
+		return step.Process(ctx, message)
+	}
+
+	branch := childBranch(currentFrame(ctx).branch, step.Name())
+	cp, err := c.restore(ctx, branch, message)
+	if err != nil {
+		return "", fmt.Errorf("load checkpoints of run %s: %w", c.RunID, err)
+	}
+	if cp != nil {
+		stepCtx := context.WithValue(ctx, frameKey{}, frame{author: step.Name(), branch: branch})
+		emitEvent(stepCtx, StepRestored, func(event *events.Event) {
+			event.Content = textContent(cp.Output, "model")
+		})
+		return cp.Output, nil
+	}
+
+	output, err := step.Process(ctx, message)
+	if err != nil {
+		return "", err
+	}
+	err = c.Store.SaveCheckpoint(ctx, &Checkpoint{
+		RunID:     c.RunID,
+		Branch:    branch,
+		Agent:     step.Name(),
+		Input:     message,
+		Output:    output,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("checkpoint step %s: %w", branch, err)
+	}
+	return output, nil
+}
@@ -0,0 +1,77 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	runs := map[string]int{}
+	failFormat := true
+	step := func(name string, process func(string) (string, error)) *Agent {
+		return NewAgent(WithName(name), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+			runs[name]++
+			return process(message)
+		}))
+	}
+	outline := step("outline", func(m string) (string, error) { return "outline of " + m, nil })
+	draft := step("draft", func(m string) (string, error) { return "draft from " + m, nil })
+	format := step("format", func(m string) (string, error) {
+		if failFormat {
+			return "", errors.New("formatter unavailable")
+		}
+		return strings.ToUpper(m), nil
+	})
+	writing := NewSequentialAgent(SequentialAgentConfig{Name: "writing", Agents: []Runnable{outline, draft}})
+	novel := NewSequentialAgent(SequentialAgentConfig{Name: "novel", Agents: []Runnable{writing, format}})
+
+	store := NewInMemoryCheckpointStore()
+	run := func(resume bool, force ...string) (string, error) {
+		ctx := WithCheckpointing(context.Background(), &Checkpointing{Store: store, RunID: "run-1", Resume: resume, Force: force})
+		return novel.Process(ctx, "a dragon")
+	}
+
+	// 格式化步骤失败，之前完成的步骤已保存检查点
+	if _, err := run(false); err == nil {
+		t.Fatal("Process() error = nil, want formatter error")
+	}
+	checkpoints, _ := store.LoadCheckpoints(context.Background(), "run-1")
+	var branches []string
+	for _, cp := range checkpoints {
+		branches = append(branches, cp.Branch)
+	}
+	if got := strings.Join(branches, ","); got != "novel.writing.outline,novel.writing.draft,novel.writing" {
+		t.Fatalf("checkpoints = %s", got)
+	}
+
+	// 恢复时从失败的步骤继续
+	failFormat = false
+	out, err := run(true)
+	if err != nil || out != "DRAFT FROM OUTLINE OF A DRAGON" {
+		t.Fatalf("resume = %q, %v", out, err)
+	}
+	if runs["outline"] != 1 || runs["draft"] != 1 || runs["format"] != 2 {
+		t.Errorf("runs after resume = %v", runs)
+	}
+
+	// 强制重跑的步骤及其所属组合步骤中的步骤重新执行
+	ic := NewInvocationContext("inv", novel, nil)
+	ctx := WithCheckpointing(WithInvocationContext(context.Background(), ic), &Checkpointing{Store: store, RunID: "run-1", Resume: true, Force: []string{"novel.writing"}})
+	if _, err := novel.Process(ctx, "a dragon"); err != nil {
+		t.Fatal(err)
+	}
+	if runs["outline"] != 2 || runs["draft"] != 2 || runs["format"] != 2 {
+		t.Errorf("runs after forced resume = %v", runs)
+	}
+	restored := 0
+	for _, event := range ic.EventsSnapshot() {
+		if event.Type == StepRestored && event.Branch == "novel.format" {
+			restored++
+		}
+	}
+	if restored != 1 {
+		t.Errorf("step_restored events for novel.format = %d, want 1", restored)
+	}
+}
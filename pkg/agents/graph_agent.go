@@ -0,0 +1,361 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GraphInputPlaceholder is the placeholder of a node input template that
+// stands for the input of the graph.
+const GraphInputPlaceholder = "input"
+
+// ErrGraphCycle is matched by the error of NewGraphAgent when the dependencies
+// of the nodes form a cycle.
+var ErrGraphCycle = errors.New("dependency cycle")
+
+// GraphNode is a node of a GraphAgent.
+type GraphNode struct {
+	// Agent runs the node; its name identifies the node
+	Agent Runnable
+	// DependsOn names the nodes whose outputs the node needs
+	DependsOn []string
+	// Input is the template of the input of the node: {name} is the output
+	// of the dependency name and {input} the input of the graph. When empty,
+	// a node without dependencies receives the input of the graph and any
+	// other node the outputs of its dependencies, one per line.
+	Input string
+}
+
+// GraphAgentConfig holds configuration for creating a GraphAgent.
+type GraphAgentConfig struct {
+	Name        string
+	Description string
+	Nodes       []GraphNode
+	Workers     int // 最大并发节点数，<=0 为不限制
+
+	// Output names the node whose output is the output of the graph. When
+	// empty, the outputs of the nodes no other node depends on are joined,
+	// one per line.
+	Output string
+}
+
+// GraphAgent runs its nodes in dependency order. A node starts as soon as all
+// of its dependencies have completed, so independent nodes run concurrently.
+type GraphAgent struct {
+	Agent
+	nodes   []*graphNode
+	byName  map[string]*graphNode
+	workers int
+	output  []*graphNode
+}
+
+// graphNode is a node of a GraphAgent with its parsed input template.
+type graphNode struct {
+	agent      *Agent
+	deps       []*graphNode
+	dependents []*graphNode
+	input      *graphInput
+}
+
+// NewGraphAgent creates a new agent that runs the nodes of a dependency graph.
+// Duplicate node names, unknown dependencies, template placeholders that are
+// not dependencies and dependency cycles are rejected.
+//
+// The orchestration is bound as the process function of the embedded Agent,
+// so Process, &agent.Agent and AsAgent(agent) all run the graph, with the
+// before/after callbacks applied around it.
+func NewGraphAgent(config GraphAgentConfig) (*GraphAgent, error) {
+	agent := &GraphAgent{
+		Agent: Agent{
+			name:        config.Name,
+			description: config.Description,
+			kind:        AgentKindGraph,
+		},
+		byName:  make(map[string]*graphNode, len(config.Nodes)),
+		workers: config.Workers,
+	}
+	for _, n := range config.Nodes {
+		a := AsAgent(n.Agent)
+		if a == nil {
+			return nil, fmt.Errorf("graph %s: node without agent", config.Name)
+		}
+		name := a.Name()
+		if name == GraphInputPlaceholder {
+			return nil, fmt.Errorf("graph %s: node name %q is reserved for the graph input", config.Name, name)
+		}
+		if _, dup := agent.byName[name]; dup {
+			return nil, fmt.Errorf("graph %s: duplicate node %s", config.Name, name)
+		}
+		node := &graphNode{agent: a}
+		agent.nodes = append(agent.nodes, node)
+		agent.byName[name] = node
+		agent.subAgents = append(agent.subAgents, a)
+	}
+
+	for i, n := range config.Nodes {
+		node := agent.nodes[i]
+		for _, dep := range n.DependsOn {
+			upstream, ok := agent.byName[dep]
+			if !ok {
+				return nil, fmt.Errorf("graph %s: node %s depends on unknown node %s", config.Name, node.agent.Name(), dep)
+			}
+			node.deps = append(node.deps, upstream)
+			upstream.dependents = append(upstream.dependents, node)
+		}
+		input, err := parseGraphInput(n.Input, n.DependsOn)
+		if err != nil {
+			return nil, fmt.Errorf("graph %s: node %s: %w", config.Name, node.agent.Name(), err)
+		}
+		node.input = input
+	}
+	if cycle := agent.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("graph %s: %w: %s", config.Name, ErrGraphCycle, strings.Join(cycle, " -> "))
+	}
+
+	if config.Output != "" {
+		node, ok := agent.byName[config.Output]
+		if !ok {
+			return nil, fmt.Errorf("graph %s: output node %s not found", config.Name, config.Output)
+		}
+		agent.output = []*graphNode{node}
+	} else {
+		for _, node := range agent.nodes {
+			if len(node.dependents) == 0 {
+				agent.output = append(agent.output, node)
+			}
+		}
+	}
+
+	agent.processFunc = agent.run
+	agent.adoptSubAgents()
+	return agent, nil
+}
+
+// findCycle returns the names along a dependency cycle, first name repeated
+// at the end, or nil.
+func (a *GraphAgent) findCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[*graphNode]int, len(a.nodes))
+	var path []*graphNode
+	var visit func(n *graphNode) []string
+	visit = func(n *graphNode) []string {
+		switch state[n] {
+		case visited:
+			return nil
+		case visiting:
+			var cycle []string
+			for i := len(path) - 1; i >= 0; i-- {
+				if path[i] == n {
+					for _, p := range path[i:] {
+						cycle = append(cycle, p.agent.Name())
+					}
+					break
+				}
+			}
+			return append(cycle, n.agent.Name())
+		}
+		state[n] = visiting
+		path = append(path, n)
+		for _, dep := range n.dependents {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = visited
+		return nil
+	}
+	for _, n := range a.nodes {
+		if cycle := visit(n); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// graphResult is the outcome of a node run.
+type graphResult struct {
+	node   *graphNode
+	output string
+	err    error
+}
+
+// run runs the nodes as their dependencies complete, at most workers at a
+// time. The first failure cancels the running nodes and no further node is
+// started.
+func (a *GraphAgent) run(ctx context.Context, message string) (string, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		pending = make(map[*graphNode]int, len(a.nodes))
+		outputs = make(map[string]string, len(a.nodes))
+		ready   []*graphNode
+		done    = make(chan graphResult)
+		running int
+		failed  error
+	)
+	for _, node := range a.nodes {
+		pending[node] = len(node.deps)
+		if len(node.deps) == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	for len(ready) > 0 || running > 0 {
+		for failed == nil && len(ready) > 0 && (a.workers <= 0 || running < a.workers) {
+			node := ready[0]
+			ready = ready[1:]
+			input := node.input.render(message, outputs)
+			running++
+			go func() {
+				output, err := runStep(runCtx, node.agent, input)
+				done <- graphResult{node: node, output: output, err: err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+
+		res := <-done
+		running--
+		if res.err != nil {
+			if failed == nil {
+				failed = &AgentError{Agent: res.node.agent.Name(), Err: res.err}
+				cancel()
+			}
+			continue
+		}
+		outputs[res.node.agent.Name()] = res.output
+		for _, next := range res.node.dependents {
+			if pending[next]--; pending[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if failed != nil {
+		return "", failed
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(a.output))
+	for _, node := range a.output {
+		parts = append(parts, outputs[node.agent.Name()])
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// Mermaid renders the graph as a Mermaid flowchart, one edge per dependency.
+func (a *GraphAgent) Mermaid() string {
+	ids := make(map[*graphNode]string, len(a.nodes))
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+	for i, node := range a.nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+		label := strings.ReplaceAll(node.agent.Name(), `"`, "#quot;")
+		fmt.Fprintf(&b, "    %s[\"%s\"]\n", ids[node], label)
+	}
+	for _, node := range a.nodes {
+		for _, dep := range node.deps {
+			fmt.Fprintf(&b, "    %s --> %s\n", ids[dep], ids[node])
+		}
+	}
+	return b.String()
+}
+
+// Dependencies returns the names of the nodes the node name depends on.
+func (a *GraphAgent) Dependencies(name string) []string {
+	node, ok := a.byName[name]
+	if !ok {
+		return nil
+	}
+	deps := make([]string, 0, len(node.deps))
+	for _, dep := range node.deps {
+		deps = append(deps, dep.agent.Name())
+	}
+	return deps
+}
+
+// SubAgents returns the nodes of the graph in declaration order.
+func (a *GraphAgent) SubAgents() []*Agent {
+	return a.subAgents
+}
+
+// graphInput is the parsed input template of a graph node.
+type graphInput struct {
+	text         string
+	placeholders []placeholder
+	deps         []string
+}
+
+// parseGraphInput parses the input template of a node with the given
+// dependencies. Placeholders must name a dependency or the graph input.
+func parseGraphInput(text string, deps []string) (*graphInput, error) {
+	in := &graphInput{text: text, deps: deps}
+	for _, loc := range placeholderRe.FindAllStringSubmatchIndex(text, -1) {
+		p := placeholder{start: loc[0], end: loc[1], raw: text[loc[2]:loc[3]]}
+		p.key = p.raw
+		known := p.key == GraphInputPlaceholder
+		for _, dep := range deps {
+			known = known || p.key == dep
+		}
+		if !known {
+			return nil, &TemplateError{Placeholder: p.raw, Reason: "not a dependency of the node"}
+		}
+		in.placeholders = append(in.placeholders, p)
+	}
+	return in, nil
+}
+
+// render returns the input of the node for the graph input and the outputs of
+// the completed nodes.
+func (in *graphInput) render(input string, outputs map[string]string) string {
+	if in.text == "" {
+		if len(in.deps) == 0 {
+			return input
+		}
+		parts := make([]string, 0, len(in.deps))
+		for _, dep := range in.deps {
+			parts = append(parts, outputs[dep])
+		}
+		return strings.Join(parts, "\n")
+	}
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, p := range in.placeholders {
+		b.WriteString(in.text[last:p.start])
+		last = p.end
+		if p.key == GraphInputPlaceholder {
+			b.WriteString(input)
+		} else {
+			b.WriteString(outputs[p.key])
+		}
+	}
+	b.WriteString(in.text[last:])
+	return b.String()
+}
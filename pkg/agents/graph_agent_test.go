@@ -0,0 +1,127 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGraphAgent(t *testing.T) {
+	// plot 与 character 互相等待，只有并发执行时才能完成
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	meet := func() error {
+		arrived.Done()
+		ch := make(chan struct{})
+		go func() { arrived.Wait(); close(ch) }()
+		select {
+		case <-ch:
+			return nil
+		case <-time.After(time.Second):
+			return errors.New("plot and character did not run concurrently")
+		}
+	}
+	node := func(name string, process func(string) (string, error)) *Agent {
+		return NewAgent(WithName(name), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+			return process(message)
+		}))
+	}
+
+	graph, err := NewGraphAgent(GraphAgentConfig{
+		Name: "novel",
+		Nodes: []GraphNode{
+			{Agent: node("worldview", func(m string) (string, error) { return "world(" + m + ")", nil })},
+			{Agent: node("plot", func(m string) (string, error) { return "plot(" + m + ")", meet() }), DependsOn: []string{"worldview"}},
+			{Agent: node("character", func(m string) (string, error) { return "character(" + m + ")", meet() }), DependsOn: []string{"worldview"}},
+			{
+				Agent:     node("dialogue", func(m string) (string, error) { return "dialogue[" + m + "]", nil }),
+				DependsOn: []string{"plot", "character"},
+				Input:     "{input}: {plot} / {character}",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := graph.Process(context.Background(), "dragon")
+	want := "dialogue[dragon: plot(world(dragon)) / character(world(dragon))]"
+	if err != nil || out != want {
+		t.Fatalf("Process() = %q, %v, want %q", out, err, want)
+	}
+	if deps := graph.Dependencies("dialogue"); strings.Join(deps, ",") != "plot,character" {
+		t.Errorf("Dependencies(dialogue) = %v", deps)
+	}
+
+	mermaid := graph.Mermaid()
+	for _, line := range []string{"flowchart TD", `n0["worldview"]`, "n0 --> n1", "n0 --> n2", "n1 --> n3", "n2 --> n3"} {
+		if !strings.Contains(mermaid, line) {
+			t.Errorf("Mermaid() missing %q:\n%s", line, mermaid)
+		}
+	}
+}
+
+func TestGraphAgentWorkersAndFailure(t *testing.T) {
+	var running, peak atomic.Int32
+	node := func(name string, err error) *Agent {
+		return NewAgent(WithName(name), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(10 * time.Millisecond)
+			return name, err
+		}))
+	}
+	failure := errors.New("boom")
+	graph, err := NewGraphAgent(GraphAgentConfig{
+		Name:    "g",
+		Workers: 1,
+		Nodes: []GraphNode{
+			{Agent: node("a", nil)},
+			{Agent: node("b", nil)},
+			{Agent: node("c", failure), DependsOn: []string{"a", "b"}},
+			{Agent: node("d", nil), DependsOn: []string{"c"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = graph.Process(context.Background(), "x")
+	var agentErr *AgentError
+	if !errors.As(err, &agentErr) || agentErr.Agent != "c" || !errors.Is(err, failure) {
+		t.Errorf("Process() error = %v, want failure of c", err)
+	}
+	if peak.Load() != 1 {
+		t.Errorf("peak concurrency = %d, want 1", peak.Load())
+	}
+}
+
+func TestGraphAgentRejectsInvalidGraphs(t *testing.T) {
+	leaf := func(name string) *Agent { return NewAgent(WithName(name)) }
+	tests := map[string]GraphAgentConfig{
+		"cycle": {Nodes: []GraphNode{
+			{Agent: leaf("a"), DependsOn: []string{"c"}},
+			{Agent: leaf("b"), DependsOn: []string{"a"}},
+			{Agent: leaf("c"), DependsOn: []string{"b"}},
+		}},
+		"unknown dependency": {Nodes: []GraphNode{{Agent: leaf("a"), DependsOn: []string{"missing"}}}},
+		"duplicate node":     {Nodes: []GraphNode{{Agent: leaf("a")}, {Agent: leaf("a")}}},
+		"placeholder":        {Nodes: []GraphNode{{Agent: leaf("a")}, {Agent: leaf("b"), Input: "{a}"}}},
+	}
+	for name, config := range tests {
+		config.Name = "g"
+		if _, err := NewGraphAgent(config); err == nil {
+			t.Errorf("%s: NewGraphAgent() error = nil", name)
+		}
+	}
+
+	_, err := NewGraphAgent(tests["cycle"])
+	if !errors.Is(err, ErrGraphCycle) || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("cycle error = %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+// UserAuthor is the author of the turns of the user.
+const UserAuthor = "user"
+
+// HistoryEntry is one turn of the conversation history of an agent.
+type HistoryEntry struct {
+	// Author is UserAuthor or the name of the agent that wrote the turn
+	Author string
+	// Branch is the branch of the event, empty for unbranched turns
+	Branch string
+	// Message is the turn as sent to the model, before other agents' turns
+	// are rewritten as context
+	Message models.Message
+}
+
+// HistoryFilter selects the history entries sent to the model. agent is the
+// name of the agent being called. Filters run in the order they are given.
+type HistoryFilter func(agent string, entries []HistoryEntry) []HistoryEntry
+
+// OnlyOwnTurns keeps the turns of the user and of the agent itself, dropping
+// those of other agents.
+func OnlyOwnTurns() HistoryFilter {
+	return func(agent string, entries []HistoryEntry) []HistoryEntry {
+		var kept []HistoryEntry
+		for _, e := range entries {
+			if e.Author == UserAuthor || e.Author == agent {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+}
+
+// LastTurns keeps the last n turns, a turn starting at a user entry and
+// including the responses that follow it.
+func LastTurns(n int) HistoryFilter {
+	return func(agent string, entries []HistoryEntry) []HistoryEntry {
+		if n <= 0 {
+			return nil
+		}
+		turns := 0
+		for i := len(entries) - 1; i >= 0; i-- {
+			if entries[i].Author != UserAuthor {
+				continue
+			}
+			if turns++; turns == n {
+				return entries[i:]
+			}
+		}
+		return entries
+	}
+}
+
+// OnlyBranch keeps the entries visible from branch: those of the branch itself,
+// of its ancestors ("a" is an ancestor of "a.b") and unbranched ones such as
+// the user input. Entries of sibling and descendant branches are dropped.
+func OnlyBranch(branch string) HistoryFilter {
+	return func(agent string, entries []HistoryEntry) []HistoryEntry {
+		var kept []HistoryEntry
+		for _, e := range entries {
+			if e.Branch == "" || e.Branch == branch || strings.HasPrefix(branch, e.Branch+".") {
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+}
+
+// sessionKey and historyKey are the context keys for the session and the
+// message list.
+type (
+	sessionKey struct{}
+	historyKey struct{}
+)
+
+// WithSession attaches session to ctx. Its events are the history of the agents
+// called with ctx that have WithHistory, and its state resolves the
+// placeholders of their instructions.
+func WithSession(ctx context.Context, session *sessions.Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// SessionFromContext returns the session attached to ctx, or nil.
+func SessionFromContext(ctx context.Context) *sessions.Session {
+	session, _ := ctx.Value(sessionKey{}).(*sessions.Session)
+	return session
+}
+
+// WithHistoryMessages makes messages the history of the agents called with ctx
+// that have WithHistory, in place of the events of the session. The author of
+// a message is read from its "author" attribute; without it user messages are
+// attributed to the user and the others to the agent being called.
+func WithHistoryMessages(ctx context.Context, messages []models.Message) context.Context {
+	return context.WithValue(ctx, historyKey{}, messages)
+}
+
+// history returns the filtered history to send before message, nil when the
+// agent does not take a history or ctx carries none.
+func (a *Agent) history(ctx context.Context, message string) []models.Message {
+	if !a.withHistory {
+		return nil
+	}
+	var entries []HistoryEntry
+	if messages, ok := ctx.Value(historyKey{}).([]models.Message); ok {
+		entries = messageEntries(a.name, messages)
+	} else if session := SessionFromContext(ctx); session != nil {
+		entries = eventEntries(a.name, session.Events)
+	} else {
+		return nil
+	}
+	// 会话中通常已记录了本次的用户输入，避免重复发送
+	if n := len(entries); n > 0 && entries[n-1].Author == UserAuthor &&
+		strings.TrimSpace(entries[n-1].Message.Content) == strings.TrimSpace(message) {
+		entries = entries[:n-1]
+	}
+	for _, filter := range a.historyFilters {
+		entries = filter(a.name, entries)
+	}
+
+	msgs := make([]models.Message, 0, len(entries))
+	for _, e := range entries {
+		msg := e.Message
+		// 其他智能体的回答以上下文的形式交给模型
+		if e.Author != UserAuthor && e.Author != a.name {
+			msg = models.Message{Role: "user", Content: fmt.Sprintf("For context: [%s] said: %s", e.Author, msg.Content)}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+// eventEntries converts the text of the final events to history entries.
+// Partial events, the tool steps of the tool loop, are skipped.
+func eventEntries(agent string, evs []*events.Event) []HistoryEntry {
+	var entries []HistoryEntry
+	for _, event := range evs {
+		if event == nil || event.Partial || event.Content == nil {
+			continue
+		}
+		var texts []string
+		for _, part := range event.Content.Parts {
+			if part != nil && part.Text != "" && !part.Thought {
+				texts = append(texts, part.Text)
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+
+		author, role := event.Author, "assistant"
+		if author == "" || author == UserAuthor {
+			author, role = UserAuthor, "user"
+		}
+		entries = append(entries, HistoryEntry{
+			Author:  author,
+			Branch:  event.Branch,
+			Message: models.Message{Role: role, Content: strings.Join(texts, "\n")},
+		})
+	}
+	return entries
+}
+
+// messageEntries converts a message list to history entries. System messages
+// are skipped, the agent has its own instruction.
+func messageEntries(agent string, messages []models.Message) []HistoryEntry {
+	var entries []HistoryEntry
+	for _, msg := range messages {
+		if msg.Role == "system" {
+			continue
+		}
+		author := msg.Attrs["author"]
+		if author == "" {
+			author = agent
+			if msg.Role == "user" {
+				author = UserAuthor
+			}
+		}
+		entries = append(entries, HistoryEntry{Author: author, Branch: msg.Attrs["branch"], Message: msg})
+	}
+	return entries
+}
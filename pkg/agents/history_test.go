@@ -0,0 +1,88 @@
+package agents
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+func textEvent(author, branch, text string) *events.Event {
+	event := events.NewEvent()
+	event.Author = author
+	event.Branch = branch
+	event.Content = &events.Content{Parts: []*models.Part{{Text: text}}}
+	return event
+}
+
+func TestHistory(t *testing.T) {
+	step := textEvent("writer", "story.writer", "calling a tool")
+	step.Partial = true
+	session := &sessions.Session{Events: []*events.Event{
+		textEvent("user", "", "first question"),
+		textEvent("writer", "story.writer", "first answer"),
+		textEvent("critic", "story.critic", "critique"),
+		textEvent("user", "", "second question"),
+		step,
+		textEvent("writer", "story.writer", "second answer"),
+		textEvent("user", "", "third question"),
+	}}
+
+	tests := []struct {
+		name    string
+		filters []HistoryFilter
+		want    []models.Message
+	}{
+		{
+			name: "all",
+			want: []models.Message{
+				{Role: "user", Content: "first question"},
+				{Role: "assistant", Content: "first answer"},
+				{Role: "user", Content: "For context: [critic] said: critique"},
+				{Role: "user", Content: "second question"},
+				{Role: "assistant", Content: "second answer"},
+			},
+		},
+		{
+			name:    "own turns, last turn",
+			filters: []HistoryFilter{OnlyOwnTurns(), LastTurns(1)},
+			want: []models.Message{
+				{Role: "user", Content: "second question"},
+				{Role: "assistant", Content: "second answer"},
+			},
+		},
+		{
+			name:    "critic branch",
+			filters: []HistoryFilter{OnlyBranch("story.critic")},
+			want: []models.Message{
+				{Role: "user", Content: "first question"},
+				{Role: "user", Content: "For context: [critic] said: critique"},
+				{Role: "user", Content: "second question"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := registerScripted(t, "history-model", "ok")
+			agent := NewAgent(WithName("writer"), WithModel("history-model"), WithHistory(tt.filters...))
+			if _, err := agent.Process(WithSession(context.Background(), session), "third question"); err != nil {
+				t.Fatal(err)
+			}
+			got := m.received[0]
+			if want := append(tt.want, models.Message{Role: "user", Content: "third question"}); !reflect.DeepEqual(got[1:], want) {
+				t.Errorf("messages = %+v, want %+v", got[1:], want)
+			}
+		})
+	}
+
+	// 未开启 WithHistory 的智能体忽略上下文中的历史
+	m := registerScripted(t, "history-model", "ok")
+	agent := NewAgent(WithName("writer"), WithModel("history-model"))
+	ctx := WithHistoryMessages(context.Background(), []models.Message{{Role: "user", Content: "earlier"}})
+	if _, err := agent.Process(ctx, "now"); err != nil || len(m.received[0]) != 2 {
+		t.Errorf("history sent without WithHistory: %+v, %v", m.received, err)
+	}
+}
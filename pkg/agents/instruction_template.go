@@ -0,0 +1,266 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nvcnvn/adk-golang/pkg/artifacts"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+// artifactPrefix marks placeholders resolved from artifact contents.
+const artifactPrefix = "artifact."
+
+var (
+	// placeholderRe matches {key}, {app:key}, {key?} and {artifact.name}. Only
+	// braces that start with a letter or underscore are placeholders, so JSON
+	// examples in instructions are left alone.
+	placeholderRe = regexp.MustCompile(`\{([A-Za-z_][^{}\s]*)\}`)
+	// stateKeyRe is the form of state and parameter keys.
+	stateKeyRe = regexp.MustCompile(`^((app|user|temp):)?[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// TemplateError reports a placeholder of an instruction that is malformed or
+// cannot be resolved.
+type TemplateError struct {
+	// Agent is the name of the agent, empty when the instruction was checked
+	// with ParseInstruction
+	Agent string
+	// Placeholder is the placeholder without braces, e.g. "user:name"
+	Placeholder string
+	// Reason says what is wrong
+	Reason string
+	// Err is the underlying error, e.g. of the artifact service
+	Err error
+}
+
+// Error implements error.
+func (e *TemplateError) Error() string {
+	msg := fmt.Sprintf("instruction placeholder {%s}: %s", e.Placeholder, e.Reason)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Agent != "" {
+		msg = "agent " + e.Agent + ": " + msg
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// InstructionTemplate is an instruction with placeholders resolved at call
+// time:
+//
+//   - {key} from the request parameters (WithTemplateParams), then the session
+//     state (WithSession)
+//   - {app:key}, {user:key}, {temp:key} from the session state
+//   - {artifact.name} from the latest version of an artifact of the session
+//     (WithArtifactService)
+//
+// A trailing "?", as in {key?}, makes the placeholder optional: it renders
+// empty when missing instead of failing.
+type InstructionTemplate struct {
+	text         string
+	placeholders []placeholder
+}
+
+// placeholder is a parsed placeholder of a template.
+type placeholder struct {
+	// start and end locate the placeholder, braces included
+	start, end int
+	raw        string
+	key        string
+	artifact   bool
+	optional   bool
+}
+
+// ParseInstruction parses and validates the placeholders of instruction. An
+// instruction without placeholders renders as is.
+func ParseInstruction(instruction string) (*InstructionTemplate, error) {
+	t := &InstructionTemplate{text: instruction}
+	for _, loc := range placeholderRe.FindAllStringSubmatchIndex(instruction, -1) {
+		// ${VAR} 为配置文件的环境变量语法，不作为占位符
+		if loc[0] > 0 && instruction[loc[0]-1] == '$' {
+			continue
+		}
+		p := placeholder{start: loc[0], end: loc[1], raw: instruction[loc[2]:loc[3]]}
+		p.key = p.raw
+		if strings.HasSuffix(p.key, "?") {
+			p.key, p.optional = strings.TrimSuffix(p.key, "?"), true
+		}
+
+		switch {
+		case strings.HasPrefix(p.key, artifactPrefix):
+			p.key, p.artifact = strings.TrimPrefix(p.key, artifactPrefix), true
+			if p.key == "" {
+				return nil, &TemplateError{Placeholder: p.raw, Reason: "missing artifact name"}
+			}
+		case !stateKeyRe.MatchString(p.key):
+			return nil, &TemplateError{Placeholder: p.raw, Reason: "invalid key, expected name, app:name, user:name, temp:name or artifact.name"}
+		}
+		t.placeholders = append(t.placeholders, p)
+	}
+	return t, nil
+}
+
+// ValidateInstruction reports whether the placeholders of instruction are well
+// formed, see InstructionTemplate.
+func ValidateInstruction(instruction string) error {
+	_, err := ParseInstruction(instruction)
+	return err
+}
+
+// IsStatic reports whether the template has no placeholders.
+func (t *InstructionTemplate) IsStatic() bool {
+	return len(t.placeholders) == 0
+}
+
+// Render resolves the placeholders from the parameters, session and artifact
+// service carried by ctx. A missing required value is a *TemplateError.
+func (t *InstructionTemplate) Render(ctx context.Context) (string, error) {
+	if t.IsStatic() {
+		return t.text, nil
+	}
+	var (
+		b       strings.Builder
+		last    int
+		params  = templateParams(ctx)
+		session = SessionFromContext(ctx)
+	)
+	for _, p := range t.placeholders {
+		b.WriteString(t.text[last:p.start])
+		last = p.end
+
+		value, err := p.resolve(ctx, params, session)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value)
+	}
+	b.WriteString(t.text[last:])
+	return b.String(), nil
+}
+
+// resolve returns the value of the placeholder.
+func (p placeholder) resolve(ctx context.Context, params map[string]interface{}, session *sessions.Session) (string, error) {
+	if p.artifact {
+		return p.loadArtifact(ctx, session)
+	}
+
+	if !strings.Contains(p.key, ":") {
+		if v, ok := params[p.key]; ok {
+			return formatTemplateValue(v), nil
+		}
+	}
+	if v, ok := sessionState(session, p.key); ok {
+		return formatTemplateValue(v), nil
+	}
+	if p.optional {
+		return "", nil
+	}
+	if strings.Contains(p.key, ":") {
+		return "", &TemplateError{Placeholder: p.raw, Reason: "key not found in session state"}
+	}
+	return "", &TemplateError{Placeholder: p.raw, Reason: "key not found in request parameters or session state"}
+}
+
+// loadArtifact returns the text of the latest version of the artifact.
+func (p placeholder) loadArtifact(ctx context.Context, session *sessions.Session) (string, error) {
+	svc := artifactServiceFromContext(ctx)
+	if svc == nil || session == nil {
+		if p.optional {
+			return "", nil
+		}
+		return "", &TemplateError{Placeholder: p.raw, Reason: "no artifact service or session on the context"}
+	}
+	part, err := svc.LoadArtifact(ctx, session.AppName, session.UserID, session.ID, p.key, nil)
+	if err != nil || part == nil {
+		if p.optional {
+			return "", nil
+		}
+		return "", &TemplateError{Placeholder: p.raw, Reason: "artifact not found", Err: err}
+	}
+	if part.Text != "" {
+		return part.Text, nil
+	}
+	return string(part.Data), nil
+}
+
+// sessionState looks key up in the state of session.
+func sessionState(session *sessions.Session, key string) (interface{}, bool) {
+	if session == nil {
+		return nil, false
+	}
+	if session.State != nil {
+		return session.State.Get(key)
+	}
+	v, ok := session.StateMap[key]
+	return v, ok
+}
+
+// formatTemplateValue renders strings as is and other values as JSON.
+func formatTemplateValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// templateParamsKey and artifactServiceKey are the context keys for the
+// request parameters and the artifact service.
+type (
+	templateParamsKey  struct{}
+	artifactServiceKey struct{}
+)
+
+// WithTemplateParams attaches request parameters for the {key} placeholders of
+// instructions. They take precedence over the session state.
+func WithTemplateParams(ctx context.Context, params map[string]interface{}) context.Context {
+	return context.WithValue(ctx, templateParamsKey{}, params)
+}
+
+// templateParams returns the request parameters attached to ctx.
+func templateParams(ctx context.Context) map[string]interface{} {
+	params, _ := ctx.Value(templateParamsKey{}).(map[string]interface{})
+	return params
+}
+
+// WithArtifactService attaches the service that resolves {artifact.name}
+// placeholders, for the session attached with WithSession.
+func WithArtifactService(ctx context.Context, svc artifacts.ArtifactService) context.Context {
+	return context.WithValue(ctx, artifactServiceKey{}, svc)
+}
+
+// artifactServiceFromContext returns the artifact service attached to ctx.
+func artifactServiceFromContext(ctx context.Context) artifacts.ArtifactService {
+	svc, _ := ctx.Value(artifactServiceKey{}).(artifacts.ArtifactService)
+	return svc
+}
@@ -0,0 +1,48 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/artifacts"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+func TestInstructionTemplate(t *testing.T) {
+	ctx := context.Background()
+	session := &sessions.Session{AppName: "novel", UserID: "u1", ID: "s1", State: sessions.NewState(map[string]interface{}{
+		"user:name":  "Lin",
+		"genre":      "mystery",
+		"app:limits": map[string]interface{}{"words": 800},
+	}, nil)}
+	svc := artifacts.NewInMemoryArtifactService()
+	if _, err := svc.SaveArtifact(ctx, "novel", "u1", "s1", "outline.md", artifacts.Part{Text: "Act 1"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx = WithArtifactService(WithTemplateParams(WithSession(ctx, session), map[string]interface{}{"genre": "romance"}), svc)
+
+	tmpl, err := ParseInstruction(`Write for {user:name} a {genre} story of {app:limits}, {style?}outline: {artifact.outline.md}. Keep {"json": true} and ${HOME}.`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Render(ctx)
+	want := `Write for Lin a romance story of {"words":800}, outline: Act 1. Keep {"json": true} and ${HOME}.`
+	if err != nil || got != want {
+		t.Errorf("Render() = %q, %v, want %q", got, err, want)
+	}
+
+	// 构建时校验占位符格式
+	if err := ValidateInstruction("hello {session:name}"); err == nil {
+		t.Error("unknown prefix accepted")
+	}
+
+	// 调用时缺失的键返回带智能体名称的 TemplateError
+	registerScripted(t, "template-model", "ok")
+	agent := NewAgent(WithName("templated"), WithModel("template-model"), WithInstruction("Hi {temp:missing}"))
+	_, err = agent.Process(ctx, "go")
+	var tmplErr *TemplateError
+	if !errors.As(err, &tmplErr) || tmplErr.Agent != "templated" || tmplErr.Placeholder != "temp:missing" {
+		t.Errorf("missing key: %v", err)
+	}
+}
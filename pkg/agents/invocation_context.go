@@ -17,6 +17,7 @@ package agents
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/nvcnvn/adk-golang/pkg/events"
 	"github.com/nvcnvn/adk-golang/pkg/types"
@@ -115,7 +116,59 @@ type InvocationContext struct {
 
 	// ActiveStreamingTools holds active streaming tools
 	ActiveStreamingTools map[string]*ActiveStreamingTool `json:"-"`
-	cancelFunc           context.CancelFunc
+
+	// cancelMu guards cancelFunc and cancelled, Cancel may be called from
+	// another goroutine while the run starts
+	cancelMu   sync.Mutex
+	cancelFunc context.CancelFunc
+	cancelled  bool
+
+	// eventsMu guards Events, agents in a parallel composite share the context
+	eventsMu sync.Mutex
+	// eventSink receives every added event, see Agent.Run
+	eventSink func(*events.Event)
+}
+
+// invocationContextKey is the context key for the current InvocationContext.
+type invocationContextKey struct{}
+
+// WithInvocationContext attaches ic to ctx. Agent.Run does this automatically;
+// callers of Process can do it to bound the run by ic.RunConfig.MaxLlmCalls and
+// collect the step events in ic.Events.
+func WithInvocationContext(ctx context.Context, ic *InvocationContext) context.Context {
+	return context.WithValue(ctx, invocationContextKey{}, ic)
+}
+
+// InvocationContextFromContext returns the InvocationContext attached to ctx, or nil.
+func InvocationContextFromContext(ctx context.Context) *InvocationContext {
+	ic, _ := ctx.Value(invocationContextKey{}).(*InvocationContext)
+	return ic
+}
+
+// AddEvent appends an event to the invocation and forwards it to the event
+// sink of the running Agent.Run, if any.
+func (ic *InvocationContext) AddEvent(event *events.Event) {
+	ic.eventsMu.Lock()
+	ic.Events = append(ic.Events, event)
+	sink := ic.eventSink
+	ic.eventsMu.Unlock()
+	if sink != nil {
+		sink(event)
+	}
+}
+
+// EventsSnapshot returns a copy of the events recorded so far.
+func (ic *InvocationContext) EventsSnapshot() []*events.Event {
+	ic.eventsMu.Lock()
+	defer ic.eventsMu.Unlock()
+	return append([]*events.Event(nil), ic.Events...)
+}
+
+// setEventSink sets the function that receives added events.
+func (ic *InvocationContext) setEventSink(sink func(*events.Event)) {
+	ic.eventsMu.Lock()
+	defer ic.eventsMu.Unlock()
+	ic.eventSink = sink
 }
 
 // NewInvocationContext creates a new InvocationContext
@@ -163,15 +216,27 @@ func (ic *InvocationContext) GetTranscriptionCache() interface{} {
 	return ic.TranscriptionCache
 }
 
-// SetCancelFunc stores the cancel function for the invocation context.
+// SetCancelFunc stores the cancel function for the invocation context. When
+// the invocation has already been cancelled, cf is called right away.
 func (ic *InvocationContext) SetCancelFunc(cf context.CancelFunc) {
+	ic.cancelMu.Lock()
 	ic.cancelFunc = cf
+	cancelled := ic.cancelled
+	ic.cancelMu.Unlock()
+	if cancelled && cf != nil {
+		cf()
+	}
 }
 
-// Cancel invokes the cancel function for the invocation context.
+// Cancel invokes the cancel function for the invocation context. A cancel
+// function stored later is called as soon as it is set.
 func (ic *InvocationContext) Cancel() {
-	if ic.cancelFunc != nil {
-		ic.cancelFunc()
+	ic.cancelMu.Lock()
+	ic.cancelled = true
+	cf := ic.cancelFunc
+	ic.cancelMu.Unlock()
+	if cf != nil {
+		cf()
 	}
 }
 
@@ -0,0 +1,400 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/telemetry"
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+)
+
+// LlmAgent is a specialized agent that talks to a models.LLM with the native
+// LlmRequest and LlmResponse types: the conversation is a list of content
+// parts, tools are declared in the request and the model answers with
+// function calls that are executed and sent back as function responses.
+type LlmAgent struct {
+	// name is the name of the agent
+	name string
+
+	// SystemInstructions contain system instructions for the LLM. They may
+	// contain placeholders, see InstructionTemplate.
+	SystemInstructions string
+
+	// CanonicalModel is the LLM model used by this agent
+	CanonicalModel models.LLM
+
+	// CanonicalTools are the tools available to this agent
+	CanonicalTools []tools.Tool
+
+	// BeforeModelCallback is called before the model is invoked. A non-nil
+	// response is used in place of the model call.
+	BeforeModelCallback func(callbackContext *CallbackContext, llmRequest *models.LlmRequest) *models.LlmResponse
+
+	// AfterModelCallback is called after the model responds. A non-nil
+	// response replaces the response of the model.
+	AfterModelCallback func(callbackContext *CallbackContext, llmResponse *models.LlmResponse) *models.LlmResponse
+
+	// parentAgent is the parent of this agent
+	parentAgent BaseAgent
+}
+
+// ModelResponseError reports an LlmResponse that carries an error code.
+type ModelResponseError struct {
+	Code    string
+	Message string
+}
+
+// Error implements error.
+func (e *ModelResponseError) Error() string {
+	return fmt.Sprintf("model error %s: %s", e.Code, e.Message)
+}
+
+// errNoUserContent is returned when the invocation has no content to send.
+var errNoUserContent = errors.New("invocation event has no content")
+
+// NewLlmAgent creates a new LLM-based agent
+func NewLlmAgent(name string, model models.LLM) *LlmAgent {
+	return &LlmAgent{
+		name:           name,
+		CanonicalModel: model,
+		CanonicalTools: make([]tools.Tool, 0),
+	}
+}
+
+// Name returns the name of the agent
+func (a *LlmAgent) Name() string {
+	return a.name
+}
+
+// Run executes the agent with the given invocation context. The parts of the
+// invocation event content are the conversation sent to the model; parts
+// without a role are user parts. Until the model answers without function
+// calls, the requested tools are executed and their results sent back as
+// function responses, within the MaxLlmCalls budget of the run.
+//
+// The channel receives the events of the run as they happen, as for
+// Agent.Run: agent start and finish, model calls, tool calls with the model
+// content (LongRunningToolIDs lists the calls of long-running tools), tool
+// results, state updates made by the callbacks, and last a FinalResponse with
+// the content of the model or a RunFailed event.
+func (a *LlmAgent) Run(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
+	if a.CanonicalModel == nil {
+		return nil, fmt.Errorf("agent %s: no model", a.name)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	invocationContext.SetCancelFunc(cancel)
+
+	eventCh := make(chan *events.Event)
+	ctx = WithInvocationContext(ctx, invocationContext)
+	invocationContext.setEventSink(func(event *events.Event) {
+		select {
+		case eventCh <- event:
+		case <-ctx.Done():
+		}
+	})
+
+	go func() {
+		defer close(eventCh)
+		defer invocationContext.setEventSink(nil)
+
+		ctx, span := telemetry.StartSpan(ctx, "LlmAgent.Run")
+		defer span.End()
+		span.SetAttribute("agent.name", a.name)
+		span.SetAttribute("agent.model", a.modelName())
+
+		a.invoke(ctx, invocationContext)
+	}()
+
+	return eventCh, nil
+}
+
+// RunLive executes the agent in live mode with the given invocation context
+func (a *LlmAgent) RunLive(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
+	// For now, implement live mode same as regular mode
+	return a.Run(ctx, invocationContext)
+}
+
+// RootAgent returns the root agent in the agent tree
+func (a *LlmAgent) RootAgent() BaseAgent {
+	if a.parentAgent == nil {
+		return a
+	}
+	return a.parentAgent.RootAgent()
+}
+
+// FindAgent finds an agent by name in the agent tree
+func (a *LlmAgent) FindAgent(name string) BaseAgent {
+	if a.name == name {
+		return a
+	}
+	return nil
+}
+
+// invoke runs the agent and records the AgentStarted and AgentFinished
+// events, followed by FinalResponse or RunFailed.
+func (a *LlmAgent) invoke(ctx context.Context, ic *InvocationContext) {
+	ctx = context.WithValue(ctx, frameKey{}, frame{author: a.name, branch: childBranch(currentFrame(ctx).branch, a.name)})
+
+	var contents []*models.Part
+	if ic.InvocationEvent != nil && ic.InvocationEvent.Content != nil {
+		for _, part := range ic.InvocationEvent.Content.Parts {
+			if part == nil {
+				continue
+			}
+			p := *part
+			if p.Role == "" {
+				p.Role = "user"
+			}
+			contents = append(contents, &p)
+		}
+	}
+	emitEvent(ctx, events.AgentStarted, func(event *events.Event) {
+		event.Content = &events.Content{Parts: contents}
+	})
+
+	var (
+		resp *models.LlmResponse
+		err  = errNoUserContent
+	)
+	if len(contents) > 0 {
+		resp, err = a.generate(ctx, ic, contents)
+	}
+
+	emitEvent(ctx, events.AgentFinished, func(event *events.Event) {
+		if err != nil {
+			event.ErrorMessage = err.Error()
+			return
+		}
+		event.Content = resp.Content
+	})
+	if err != nil {
+		emitEvent(ctx, events.RunFailed, func(event *events.Event) {
+			event.ErrorCode = ErrorCodeAgent
+			var respErr *ModelResponseError
+			if errors.As(err, &respErr) {
+				event.ErrorCode = respErr.Code
+			}
+			event.ErrorMessage = err.Error()
+			event.Content = textContent(fmt.Sprintf("Error processing message: %v", err), "model")
+		})
+		return
+	}
+	emitEvent(ctx, events.FinalResponse, func(event *events.Event) {
+		event.Content = resp.Content
+	})
+}
+
+// generate calls the model with contents and executes the function calls of
+// its responses until it answers without any.
+func (a *LlmAgent) generate(ctx context.Context, ic *InvocationContext, contents []*models.Part) (*models.LlmResponse, error) {
+	instruction, err := a.instruction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req := &models.LlmRequest{
+		Contents:           &models.Content{Parts: contents},
+		SystemInstructions: instruction,
+		ToolsDict:          make(map[string]*models.Tool, len(a.CanonicalTools)),
+	}
+	longRunning := make(map[string]bool)
+	for _, tool := range a.CanonicalTools {
+		decl := &models.Tool{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: jsonSchema(tool.Schema().Input),
+		}
+		if lr, ok := tool.(interface{ IsLongRunning() bool }); ok && lr.IsLongRunning() {
+			decl.IsLongRunning = true
+			longRunning[decl.Name] = true
+		}
+		req.Tools = append(req.Tools, decl)
+		req.ToolsDict[decl.Name] = decl
+	}
+
+	budget := &llmCallBudget{ic: ic}
+	for step := 1; ; step++ {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		if err := a.processRequest(ctx, ic, req); err != nil {
+			return nil, err
+		}
+
+		log.Printf("[LlmAgent] 开始模型调用，agent: %s, 模型: %s, 第 %d 轮", a.name, a.modelName(), step)
+		resp, err := a.callModel(ctx, ic, budget, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.ErrorCode != "" {
+			return nil, &ModelResponseError{Code: resp.ErrorCode, Message: resp.ErrorMessage}
+		}
+		if resp.Content == nil {
+			resp.Content = &models.Content{}
+		}
+
+		var (
+			fcs  []*models.FunctionCall
+			text []string
+		)
+		for _, part := range resp.Content.Parts {
+			if part.Role == "" {
+				part.Role = "model"
+			}
+			if part.FunctionCall != nil {
+				fcs = append(fcs, part.FunctionCall)
+			} else if part.Text != "" && !part.Thought {
+				text = append(text, part.Text)
+			}
+		}
+		if len(fcs) == 0 {
+			return resp, nil
+		}
+
+		// 模型请求调用工具：记录调用，执行后将结果作为函数响应发回模型
+		calls := nativeToolCalls(fcs)
+		if len(text) > 0 {
+			emitEvent(ctx, events.PartialText, func(event *events.Event) {
+				event.Content = textContent(strings.Join(text, "\n"), "model")
+			})
+		}
+		emitEvent(ctx, events.ToolCalled, func(event *events.Event) {
+			event.Content = resp.Content
+			for _, call := range calls {
+				if longRunning[call.ToolName] {
+					event.LongRunningToolIDs = append(event.LongRunningToolIDs, call.id)
+				}
+			}
+		})
+		req.Contents.Parts = append(req.Contents.Parts, resp.Content.Parts...)
+		for _, call := range calls {
+			result := executeTool(ctx, a.name, a.CanonicalTools, call)
+			recordToolResult(ctx, call, result)
+			req.Contents.Parts = append(req.Contents.Parts, &models.Part{
+				Role:             "user",
+				FunctionResponse: &models.FunctionResponse{Name: call.ToolName, Content: result, ID: call.id},
+			})
+		}
+		if ic.IsEndInvocation() {
+			return resp, nil
+		}
+	}
+}
+
+// callModel produces the response to req: the response of the
+// BeforeModelCallback if it returns one, otherwise the response of the model
+// passed through the AfterModelCallback. State changes made by the callbacks
+// are recorded as a StateUpdated event.
+func (a *LlmAgent) callModel(ctx context.Context, ic *InvocationContext, budget *llmCallBudget, req *models.LlmRequest) (*models.LlmResponse, error) {
+	cc := &CallbackContext{InvocationContext: ic, EventActions: events.NewEventActions()}
+	defer recordCallbackState(ctx, cc)
+
+	if a.BeforeModelCallback != nil {
+		if resp := a.BeforeModelCallback(cc, req); resp != nil {
+			return resp, nil
+		}
+	}
+
+	if err := budget.take(); err != nil {
+		return nil, err
+	}
+	callCtx, done := modelCall(ctx, a.modelName())
+	resp, err := a.CanonicalModel.GenerateContent(callCtx, req)
+	if err == nil && resp == nil {
+		err = errors.New("model returned no response")
+	}
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.AfterModelCallback != nil {
+		if replaced := a.AfterModelCallback(cc, resp); replaced != nil {
+			resp = replaced
+		}
+	}
+	return resp, nil
+}
+
+// processRequest lets the tools that implement ProcessLlmRequest, such as
+// tools.LlmToolAdaptor, adjust the request before each model call.
+func (a *LlmAgent) processRequest(ctx context.Context, ic *InvocationContext, req *models.LlmRequest) error {
+	for _, tool := range a.CanonicalTools {
+		p, ok := tool.(interface {
+			ProcessLlmRequest(ctx context.Context, toolContext *tools.ToolContext, llmRequest *models.LlmRequest) error
+		})
+		if !ok {
+			continue
+		}
+		toolContext := &tools.ToolContext{InvocationContext: ic, EventActions: events.NewEventActions()}
+		if err := p.ProcessLlmRequest(ctx, toolContext, req); err != nil {
+			return fmt.Errorf("tool %s: %w", tool.Name(), err)
+		}
+	}
+	return nil
+}
+
+// instruction renders the system instructions.
+func (a *LlmAgent) instruction(ctx context.Context) (string, error) {
+	t, err := ParseInstruction(a.SystemInstructions)
+	if err != nil {
+		return "", err
+	}
+	text, err := t.Render(ctx)
+	var tmplErr *TemplateError
+	if errors.As(err, &tmplErr) {
+		tmplErr.Agent = a.name
+	}
+	return text, err
+}
+
+// modelName returns the name of the model for the ModelCalled events.
+func (a *LlmAgent) modelName() string {
+	switch m := a.CanonicalModel.(type) {
+	case interface{ Name() string }:
+		return m.Name()
+	case *models.GeminiLLM:
+		return m.ModelName
+	case *models.BaseLlm:
+		return m.ModelName
+	}
+	return fmt.Sprintf("%T", a.CanonicalModel)
+}
+
+// recordCallbackState applies the state delta set by the model callbacks to
+// the session carried by ctx, if any, and records it as a StateUpdated event.
+func recordCallbackState(ctx context.Context, cc *CallbackContext) {
+	if len(cc.EventActions.StateDelta) == 0 {
+		return
+	}
+	if session := SessionFromContext(ctx); session != nil {
+		for key, value := range cc.EventActions.StateDelta {
+			session.SetState(key, value)
+		}
+	}
+	emitEvent(ctx, events.StateUpdated, func(event *events.Event) {
+		for key, value := range cc.EventActions.StateDelta {
+			event.Actions.StateDelta[key] = value
+		}
+	})
+}
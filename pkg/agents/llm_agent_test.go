@@ -0,0 +1,158 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+)
+
+// scriptedLLM answers with the next response of its script and keeps the
+// requests it received.
+type scriptedLLM struct {
+	*models.BaseLlm
+	script   []*models.LlmResponse
+	requests []*models.LlmRequest
+}
+
+func (m *scriptedLLM) GenerateContent(ctx context.Context, req *models.LlmRequest) (*models.LlmResponse, error) {
+	snapshot := *req
+	snapshot.Contents = &models.Content{Parts: append([]*models.Part(nil), req.Contents.Parts...)}
+	m.requests = append(m.requests, &snapshot)
+	resp := m.script[0]
+	m.script = m.script[1:]
+	return resp, nil
+}
+
+func collectEvents(t *testing.T, agent *LlmAgent, ic *InvocationContext) []*events.Event {
+	t.Helper()
+	ch, err := agent.Run(context.Background(), ic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var evts []*events.Event
+	for event := range ch {
+		evts = append(evts, event)
+	}
+	return evts
+}
+
+func userInvocation(agent BaseAgent, text string) *InvocationContext {
+	ic := NewInvocationContext("inv", agent, nil)
+	ic.InvocationEvent = &events.Event{Content: &events.Content{Parts: []*models.Part{{Text: text}}}}
+	return ic
+}
+
+func TestLlmAgentRunWithTools(t *testing.T) {
+	model := &scriptedLLM{
+		BaseLlm: models.NewBaseLlm("scripted"),
+		script: []*models.LlmResponse{
+			{Content: &models.Content{Parts: []*models.Part{
+				{Text: "let me check"},
+				{FunctionCall: &models.FunctionCall{Name: "lookup", Arguments: `{"city":"Paris"}`, ID: "call-1"}},
+				{FunctionCall: &models.FunctionCall{Name: "book", Arguments: `{}`}},
+			}}},
+			{Content: &models.Content{Parts: []*models.Part{{Text: "sunny, booking pending"}}}},
+		},
+	}
+	lookup := tools.NewTool("lookup", "Looks up the weather", tools.ToolSchema{},
+		func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"weather": "sunny in " + input["city"].(string)}, nil
+		})
+	book := tools.ConvertToLongRunning(tools.NewTool("book", "Books a trip", tools.ToolSchema{},
+		func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"status": "pending"}, nil
+		}))
+
+	agent := NewLlmAgent("travel", model)
+	agent.SystemInstructions = "You plan trips."
+	agent.CanonicalTools = []tools.Tool{lookup, book}
+	agent.AfterModelCallback = func(cc *CallbackContext, resp *models.LlmResponse) *models.LlmResponse {
+		cc.EventActions.StateDelta["temp:calls"] = len(model.requests)
+		return nil
+	}
+
+	evts := collectEvents(t, agent, userInvocation(agent, "weather in Paris?"))
+
+	var types []string
+	for _, event := range evts {
+		types = append(types, string(event.Type))
+	}
+	want := "agent_started,model_called,state_updated,partial_text,tool_called,tool_result_received,tool_result_received,model_called,state_updated,agent_finished,final_response"
+	if got := strings.Join(types, ","); got != want {
+		t.Fatalf("event types = %s\nwant %s", got, want)
+	}
+
+	final := evts[len(evts)-1]
+	if final.Content.GetText() != "sunny, booking pending" || final.Author != "travel" {
+		t.Errorf("final event = %+v", final)
+	}
+	toolCalled := evts[4]
+	if len(toolCalled.GetFunctionCalls()) != 2 || len(toolCalled.LongRunningToolIDs) != 1 || toolCalled.LongRunningToolIDs[0] == "" {
+		t.Errorf("tool_called event = %+v", toolCalled)
+	}
+
+	// 第二次请求带上模型的函数调用与工具的函数响应
+	if len(model.requests) != 2 {
+		t.Fatalf("model called %d times", len(model.requests))
+	}
+	first, second := model.requests[0], model.requests[1]
+	if first.SystemInstructions != "You plan trips." || len(first.Tools) != 2 || !first.ToolsDict["book"].IsLongRunning {
+		t.Errorf("first request = %+v", first)
+	}
+	parts := second.Contents.Parts
+	if len(parts) != 6 || parts[0].Role != "user" || parts[2].FunctionCall == nil {
+		t.Fatalf("second request parts = %d", len(parts))
+	}
+	resp := parts[4].FunctionResponse
+	if resp == nil || resp.ID != "call-1" || !strings.Contains(resp.Content, "sunny in Paris") {
+		t.Errorf("lookup response = %+v", resp)
+	}
+	if parts[5].FunctionResponse == nil || parts[5].FunctionResponse.ID != parts[3].FunctionCall.ID {
+		t.Errorf("book response does not match its call: %+v", parts[5].FunctionResponse)
+	}
+}
+
+func TestLlmAgentModelCallbacks(t *testing.T) {
+	model := &scriptedLLM{
+		BaseLlm: models.NewBaseLlm("scripted"),
+		script: []*models.LlmResponse{
+			{Content: &models.Content{Parts: []*models.Part{{Text: "draft"}}}},
+			{ErrorCode: "SAFETY", ErrorMessage: "blocked"},
+		},
+	}
+	agent := NewLlmAgent("writer", model)
+
+	// BeforeModelCallback 返回的响应代替模型调用
+	agent.BeforeModelCallback = func(cc *CallbackContext, req *models.LlmRequest) *models.LlmResponse {
+		if req.Contents.GetText() == "cached" {
+			return &models.LlmResponse{Content: &models.Content{Parts: []*models.Part{{Text: "from cache"}}}}
+		}
+		return nil
+	}
+	agent.AfterModelCallback = func(cc *CallbackContext, resp *models.LlmResponse) *models.LlmResponse {
+		if resp.Content.GetText() == "draft" {
+			return &models.LlmResponse{Content: &models.Content{Parts: []*models.Part{{Text: "polished draft"}}}}
+		}
+		return nil
+	}
+
+	evts := collectEvents(t, agent, userInvocation(agent, "cached"))
+	if final := evts[len(evts)-1]; final.Type != events.FinalResponse || final.Content.GetText() != "from cache" || len(model.requests) != 0 {
+		t.Errorf("before callback: final = %+v, model calls = %d", final, len(model.requests))
+	}
+
+	evts = collectEvents(t, agent, userInvocation(agent, "write"))
+	if final := evts[len(evts)-1]; final.Content.GetText() != "polished draft" {
+		t.Errorf("after callback: final = %q", final.Content.GetText())
+	}
+
+	// 带错误码的响应以该错误码结束运行
+	evts = collectEvents(t, agent, userInvocation(agent, "write"))
+	if final := evts[len(evts)-1]; final.Type != events.RunFailed || final.ErrorCode != "SAFETY" {
+		t.Errorf("error response: final = %+v", final)
+	}
+}
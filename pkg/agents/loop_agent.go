@@ -17,13 +17,31 @@ package agents
 
 import (
 	"context"
+	"log"
+	"sync/atomic"
+
+	"github.com/nvcnvn/adk-golang/pkg/tools"
 )
 
 // LoopAgent runs its sub-agents repeatedly until a condition is met or max iterations is reached.
 type LoopAgent struct {
 	Agent
-	subAgents     []*Agent
-	maxIterations int
+	subAgents      []*Agent
+	maxIterations  int
+	stopConditions []StopCondition
+	nextInput      func(it LoopIteration) string
+}
+
+// LoopResult is the outcome of a LoopAgent run.
+type LoopResult struct {
+	// Output is the response of the last sub-agent that ran
+	Output string
+	// Iterations is the number of iterations that ran, including a partial
+	// iteration ended by Escalate
+	Iterations int
+	// Reason is the name of the stop condition that ended the loop, or
+	// StopReasonMaxIterations
+	Reason string
 }
 
 // LoopAgentConfig holds configuration for creating a LoopAgent.
@@ -32,9 +50,22 @@ type LoopAgentConfig struct {
 	Description   string
 	SubAgents     []*Agent
 	MaxIterations int
+
+	// Agents are appended after SubAgents and may be any Runnable,
+	// including other composite agents.
+	Agents []Runnable
+
+	// StopConditions end the loop before MaxIterations, see StopCondition.
+	StopConditions []StopCondition
+
+	// NextInput derives the input of the next iteration from the one that
+	// just ran. By default the iteration output is used.
+	NextInput func(it LoopIteration) string
 }
 
 // NewLoopAgent creates a new agent that processes sub-agents in a loop.
+// As with NewSequentialAgent, the loop is bound as the process function of
+// the embedded Agent.
 func NewLoopAgent(config LoopAgentConfig) *LoopAgent {
 	// If max iterations not specified, default to 10 to prevent infinite loops
 	maxIter := config.MaxIterations
@@ -42,39 +73,96 @@ func NewLoopAgent(config LoopAgentConfig) *LoopAgent {
 		maxIter = 10
 	}
 
-	return &LoopAgent{
+	subAgents := collectSubAgents(config.SubAgents, config.Agents)
+	agent := &LoopAgent{
 		Agent: Agent{
 			name:        config.Name,
 			description: config.Description,
+			subAgents:   subAgents,
+			kind:        AgentKindLoop,
 		},
-		subAgents:     config.SubAgents,
-		maxIterations: maxIter,
+		subAgents:      subAgents,
+		maxIterations:  maxIter,
+		stopConditions: config.StopConditions,
+		nextInput:      config.NextInput,
 	}
+	agent.processFunc = agent.run
+	agent.adoptSubAgents()
+	return agent
 }
 
-// Process handles a message by processing it through all sub-agents repeatedly.
-func (a *LoopAgent) Process(ctx context.Context, message string) (string, error) {
-	currentMessage := message
-	var response string
-	var err error
+// run processes the message through all sub-agents repeatedly.
+func (a *LoopAgent) run(ctx context.Context, message string) (string, error) {
+	result, err := a.RunLoop(ctx, message)
+	if err != nil {
+		return "", err
+	}
+	return result.Output, nil
+}
+
+// RunLoop runs the sub-agents repeatedly, feeding the output of each iteration
+// (or NextInput of it) into the next, until a stop condition matches or
+// MaxIterations is reached. Unlike Process it reports why the loop ended and
+// how many iterations ran.
+func (a *LoopAgent) RunLoop(ctx context.Context, message string) (*LoopResult, error) {
+	escalated := &atomic.Bool{}
+	ctx = context.WithValue(ctx, escalationKey{}, escalated)
+	loopCtx := ctx
+	ctx = tools.WithExitLoopHandler(ctx, func() { Escalate(loopCtx) })
 
-	for i := 0; i < a.maxIterations; i++ {
+	currentMessage := message
+	previous := ""
+	for i := 1; i <= a.maxIterations; i++ {
 		select {
 		case <-ctx.Done():
-			return "", ctx.Err()
+			return nil, ctx.Err()
 		default:
 		}
 
+		input := currentMessage
 		for _, subAgent := range a.subAgents {
-			response, err = subAgent.Process(ctx, currentMessage)
+			response, err := subAgent.Process(ctx, currentMessage)
 			if err != nil {
-				return "", err
+				return nil, err
 			}
 			currentMessage = response
+
+			if escalated.Load() {
+				if cond, ok := a.escalateCondition(); ok {
+					return a.stop(currentMessage, i, cond.Name), nil
+				}
+			}
+		}
+
+		it := LoopIteration{Index: i, Input: input, Output: currentMessage, Previous: previous}
+		for _, cond := range a.stopConditions {
+			if cond.Stop != nil && cond.Stop(it) {
+				return a.stop(currentMessage, i, cond.Name), nil
+			}
+		}
+		previous = currentMessage
+		if a.nextInput != nil {
+			currentMessage = a.nextInput(it)
+		}
+	}
+
+	return a.stop(previous, a.maxIterations, StopReasonMaxIterations), nil
+}
+
+// escalateCondition returns the StopOnEscalate condition, if configured.
+func (a *LoopAgent) escalateCondition() (StopCondition, bool) {
+	for _, cond := range a.stopConditions {
+		if cond.escalate {
+			return cond, true
 		}
 	}
+	return StopCondition{}, false
+}
 
-	return currentMessage, nil
+// stop builds the loop result.
+func (a *LoopAgent) stop(output string, iterations int, reason string) *LoopResult {
+	log.Printf("[LoopAgent] %s 结束，迭代 %d 次，原因: %s", a.name, iterations, reason)
+	return &LoopResult{Output: output, Iterations: iterations, Reason: reason}
 }
 
 // SubAgents returns the sub-agents of this loop agent.
@@ -82,6 +170,11 @@ func (a *LoopAgent) SubAgents() []*Agent {
 	return a.subAgents
 }
 
+// StopConditions returns the stop conditions of this loop agent.
+func (a *LoopAgent) StopConditions() []StopCondition {
+	return a.stopConditions
+}
+
 // MaxIterations returns the maximum number of iterations for this loop agent.
 func (a *LoopAgent) MaxIterations() int {
 	return a.maxIterations
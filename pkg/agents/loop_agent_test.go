@@ -0,0 +1,100 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+)
+
+// counter 每次调用返回递增的计数，达到 escalateAt 时请求终止循环。
+func counter(name string, outputs func(n int) string, escalateAt int) *Agent {
+	n := 0
+	return NewAgent(WithName(name), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		n++
+		if n == escalateAt {
+			Escalate(ctx)
+		}
+		return outputs(n), nil
+	}))
+}
+
+func TestLoopStopConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		agents     []*Agent
+		conditions []StopCondition
+		wantOutput string
+		wantIter   int
+		wantReason string
+	}{
+		{
+			name:       "max iterations",
+			agents:     []*Agent{counter("a", func(n int) string { return fmt.Sprint(n) }, 0)},
+			wantOutput: "4", wantIter: 4, wantReason: StopReasonMaxIterations,
+		},
+		{
+			name:       "predicate",
+			agents:     []*Agent{counter("a", func(n int) string { return strings.Repeat("x", n) }, 0)},
+			conditions: []StopCondition{StopWhen("long_enough", func(out string) bool { return len(out) >= 2 })},
+			wantOutput: "xx", wantIter: 2, wantReason: "long_enough",
+		},
+		{
+			name: "json field",
+			agents: []*Agent{counter("a", func(n int) string {
+				return fmt.Sprintf("```json\n{\"finish\": %v, \"round\": %d}\n```", n == 3, n)
+			}, 0)},
+			conditions: []StopCondition{StopOnJSONField("finish", true)},
+			wantOutput: "```json\n{\"finish\": true, \"round\": 3}\n```", wantIter: 3, wantReason: StopReasonJSONField,
+		},
+		{
+			name: "escalate skips the rest of the iteration",
+			agents: []*Agent{
+				counter("a", func(n int) string { return fmt.Sprint("a", n) }, 2),
+				counter("b", func(n int) string { return fmt.Sprint("b", n) }, 0),
+			},
+			conditions: []StopCondition{StopOnEscalate()},
+			wantOutput: "a2", wantIter: 2, wantReason: StopReasonEscalate,
+		},
+		{
+			name:       "no change",
+			agents:     []*Agent{counter("a", func(n int) string { return fmt.Sprint(n / 2) }, 0)},
+			conditions: []StopCondition{StopOnNoChange()},
+			wantOutput: "1", wantIter: 3, wantReason: StopReasonNoChange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loop := NewLoopAgent(LoopAgentConfig{
+				Name:           "loop",
+				SubAgents:      tt.agents,
+				MaxIterations:  4,
+				StopConditions: tt.conditions,
+			})
+			res, err := loop.RunLoop(context.Background(), "start")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Output != tt.wantOutput || res.Iterations != tt.wantIter || res.Reason != tt.wantReason {
+				t.Errorf("got %+v, want output=%q iterations=%d reason=%s", res, tt.wantOutput, tt.wantIter, tt.wantReason)
+			}
+		})
+	}
+
+	// 模型 Agent 通过 exit_loop 工具结束循环
+	exit := NewAgent(WithName("exit"), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		res, _ := tools.ExitLoopTool.Execute(ctx, nil)
+		return fmt.Sprint(res["success"]), nil
+	}))
+	loop := NewLoopAgent(LoopAgentConfig{Name: "loop", SubAgents: []*Agent{exit}, StopConditions: []StopCondition{StopOnEscalate()}})
+	if res, err := loop.RunLoop(context.Background(), "x"); err != nil || res.Reason != StopReasonEscalate || res.Output != "true" {
+		t.Errorf("exit_loop result = %+v, %v", res, err)
+	}
+
+	if Escalate(context.Background()) {
+		t.Error("Escalate outside a loop should report false")
+	}
+}
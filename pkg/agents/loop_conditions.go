@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+)
+
+// Stop reasons reported in LoopResult.Reason. Conditions created with
+// StopWhen report their own name.
+const (
+	StopReasonMaxIterations = "max_iterations"
+	StopReasonJSONField     = "json_field"
+	StopReasonEscalate      = "escalate"
+	StopReasonNoChange      = "no_change"
+)
+
+// LoopIteration describes a completed iteration of a LoopAgent.
+type LoopIteration struct {
+	// Index is the 1-based number of the iteration
+	Index int
+	// Input is the message the iteration started with
+	Input string
+	// Output is the response of the last sub-agent
+	Output string
+	// Previous is the output of the previous iteration, empty for the first
+	Previous string
+}
+
+// StopCondition ends a LoopAgent early. Conditions are checked in order after
+// every iteration; the first one that matches ends the loop and its Name is
+// reported as the LoopResult.Reason.
+type StopCondition struct {
+	// Name identifies the condition in LoopResult.Reason
+	Name string
+	// Stop reports whether the loop should end after the iteration
+	Stop func(it LoopIteration) bool
+
+	// escalate makes the loop stop as soon as a sub-agent calls Escalate
+	escalate bool
+}
+
+// StopWhen stops the loop when fn returns true for the iteration output.
+func StopWhen(name string, fn func(output string) bool) StopCondition {
+	return StopCondition{
+		Name: name,
+		Stop: func(it LoopIteration) bool { return fn(it.Output) },
+	}
+}
+
+// StopOnJSONField stops the loop when the output is a JSON object whose field
+// equals value, e.g. StopOnJSONField("finish", true). The output may be
+// wrapped in a ```json code fence.
+func StopOnJSONField(field string, value interface{}) StopCondition {
+	// 统一数值类型，便于与 JSON 解码结果比较
+	var want interface{}
+	if raw, err := json.Marshal(value); err == nil {
+		_ = json.Unmarshal(raw, &want)
+	}
+	return StopCondition{
+		Name: StopReasonJSONField,
+		Stop: func(it LoopIteration) bool {
+			var payload map[string]interface{}
+			if err := json.Unmarshal([]byte(trimCodeFence(it.Output)), &payload); err != nil {
+				return false
+			}
+			got, ok := payload[field]
+			return ok && reflect.DeepEqual(got, want)
+		},
+	}
+}
+
+// StopOnEscalate stops the loop as soon as a sub-agent calls Escalate, without
+// running the remaining sub-agents of the iteration.
+func StopOnEscalate() StopCondition {
+	return StopCondition{Name: StopReasonEscalate, escalate: true}
+}
+
+// StopOnNoChange stops the loop when an iteration produces the same output as
+// the previous one.
+func StopOnNoChange() StopCondition {
+	return StopCondition{
+		Name: StopReasonNoChange,
+		Stop: func(it LoopIteration) bool {
+			return it.Index > 1 && strings.TrimSpace(it.Output) == strings.TrimSpace(it.Previous)
+		},
+	}
+}
+
+// escalationKey is the context key for the escalation flag of the innermost
+// running LoopAgent.
+type escalationKey struct{}
+
+// Escalate asks the innermost LoopAgent running ctx to stop. It is meant to be
+// called from a sub-agent, e.g. in a ProcessFunc, callback or tool; model
+// agents can call tools.ExitLoopTool instead. When ctx
+// carries an invocation context an event with the Escalate action is recorded.
+// It returns false when ctx is not running inside a LoopAgent.
+func Escalate(ctx context.Context) bool {
+	flag, ok := ctx.Value(escalationKey{}).(*atomic.Bool)
+	if !ok {
+		return false
+	}
+	flag.Store(true)
+	emitEvent(ctx, ActionRequested, func(event *events.Event) {
+		event.Actions.Escalate = true
+	})
+	return true
+}
+
+// trimCodeFence removes a surrounding markdown code fence, models often wrap
+// JSON answers in one.
+func trimCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.Index(s, "\n"); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
@@ -0,0 +1,203 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/models"
+)
+
+// Retry policy defaults, used for zero fields of RetryPolicy.
+const (
+	DefaultRetryInitialBackoff = 500 * time.Millisecond
+	DefaultRetryMaxBackoff     = 10 * time.Second
+	DefaultRetryMultiplier     = 2.0
+)
+
+// ErrModelUnavailable is matched by ModelUnavailableError.
+var ErrModelUnavailable = errors.New("model not available")
+
+// ModelUnavailableError is returned when none of the models of an agent, the
+// model and its fallbacks, can be resolved from the registries.
+type ModelUnavailableError struct {
+	Agent  string
+	Models []string
+	// Err joins the lookup errors
+	Err error
+}
+
+// Error implements error.
+func (e *ModelUnavailableError) Error() string {
+	return fmt.Sprintf("agent %s: no model available (%s): %v", e.Agent, strings.Join(e.Models, ", "), e.Err)
+}
+
+// Unwrap returns the lookup errors.
+func (e *ModelUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrModelUnavailable.
+func (e *ModelUnavailableError) Is(target error) bool {
+	return target == ErrModelUnavailable
+}
+
+// Response is the result of Agent.Respond.
+type Response struct {
+	// Text is the response, after the after agent callback
+	Text string
+	// Model is the name of the model that produced the response, empty when
+	// no model was called (process function or before agent callback)
+	Model string
+	// Failures holds the errors of the models that were tried before Model,
+	// in order
+	Failures []error
+	// Value is the decoded JSON output when the agent has an output schema
+	Value interface{}
+	// Repairs is the number of repair prompts the output schema needed
+	Repairs int
+}
+
+// RetryPolicy controls how an agent retries a failed model call. The zero
+// value makes a single attempt per model.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts per model call, including the
+	// first; <=1 disables retries
+	MaxAttempts int
+	// InitialBackoff is the wait before the first retry
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between retries
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after every retry
+	Multiplier float64
+	// RetryIf reports whether an error is worth retrying, by default every
+	// error is. Cancellation and ErrLlmCallsExceeded are never retried.
+	RetryIf func(err error) bool
+}
+
+// backoff returns the wait before the given retry, starting at 1.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	wait := p.InitialBackoff
+	if wait <= 0 {
+		wait = DefaultRetryInitialBackoff
+	}
+	maxWait := p.MaxBackoff
+	if maxWait <= 0 {
+		maxWait = DefaultRetryMaxBackoff
+	}
+	mult := p.Multiplier
+	if mult < 1 {
+		mult = DefaultRetryMultiplier
+	}
+	for i := 1; i < retry && wait < maxWait; i++ {
+		wait = time.Duration(float64(wait) * mult)
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	return wait
+}
+
+// retryable reports whether err should be retried on the same model.
+func (p RetryPolicy) retryable(err error) bool {
+	if fatalModelError(err) {
+		return false
+	}
+	return p.RetryIf == nil || p.RetryIf(err)
+}
+
+// fatalModelError reports errors that end the run instead of falling back to
+// the next model.
+func fatalModelError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrLlmCallsExceeded)
+}
+
+// withRetry runs call until it succeeds, the attempts of the policy are used
+// up or the error is not retryable. Each attempt is counted by budget.
+func (a *Agent) withRetry(ctx context.Context, budget *llmCallBudget, call func() error) error {
+	for attempt := 1; ; attempt++ {
+		if err := budget.take(); err != nil {
+			return err
+		}
+		err := call()
+		if err == nil || attempt >= a.retryPolicy.MaxAttempts || !a.retryPolicy.retryable(err) {
+			return err
+		}
+
+		wait := a.retryPolicy.backoff(attempt)
+		log.Printf("[Agent] 模型调用失败，agent: %s, 第 %d 次尝试, %v 后重试: %v", a.name, attempt, wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// modelChain returns the model followed by the fallback models.
+func (a *Agent) modelChain() []string {
+	return append([]string{a.model}, a.fallbackModels...)
+}
+
+// generate runs the tool loop with the first model of the chain that resolves
+// and succeeds. A model that fails after its retries is replaced by the next
+// one and the loop starts over; the MaxLlmCalls budget is shared by all the
+// models and tool calls already executed are answered with their results
+// instead of running again.
+func (a *Agent) generate(ctx context.Context, message string) (*Response, error) {
+	instruction, err := a.renderInstruction(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		resp       = &Response{}
+		lookupErrs []error
+		called     bool
+		state      = newToolLoopState(ctx)
+	)
+	for _, name := range a.modelChain() {
+		model, err := models.Lookup(name)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			lookupErrs = append(lookupErrs, err)
+			resp.Failures = append(resp.Failures, err)
+			continue
+		}
+
+		called = true
+		text, err := a.runToolLoop(ctx, model, a.buildMessages(ctx, model, instruction, message), state)
+		if err == nil {
+			resp.Text, resp.Model = text, name
+			return resp, nil
+		}
+		if fatalModelError(err) {
+			return nil, err
+		}
+		log.Printf("[Agent] 模型 %s 调用失败，agent: %s, 尝试下一个模型: %v", name, a.name, err)
+		resp.Failures = append(resp.Failures, fmt.Errorf("%s: %w", name, err))
+	}
+
+	if !called {
+		return nil, &ModelUnavailableError{Agent: a.name, Models: a.modelChain(), Err: errors.Join(lookupErrs...)}
+	}
+	return nil, fmt.Errorf("agent %s: all models failed: %w", a.name, errors.Join(resp.Failures...))
+}
@@ -0,0 +1,129 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+)
+
+// flakyModel 前 failures 次调用返回错误，之后返回 reply。
+type flakyModel struct {
+	scriptedModel
+	failures int
+	calls    int
+}
+
+func (m *flakyModel) Generate(ctx context.Context, messages []models.Message) (string, error) {
+	m.calls++
+	if m.calls <= m.failures {
+		return "", errors.New("503 service unavailable")
+	}
+	return m.scriptedModel.Generate(ctx, messages)
+}
+
+func registerFlaky(t *testing.T, name string, failures int, reply string) *flakyModel {
+	m := &flakyModel{scriptedModel: scriptedModel{name: name, replies: []string{reply}}, failures: failures}
+	models.GetRegistry().Register(m)
+	t.Cleanup(func() { models.GetRegistry().Unregister(name) })
+	return m
+}
+
+func TestModelFallback(t *testing.T) {
+	ctx := context.Background()
+	retry := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	// 重试后同一模型成功
+	flaky := registerFlaky(t, "flaky-model", 2, "flaky answer")
+	agent := NewAgent(WithName("retrying"), WithModel("flaky-model"), WithRetryPolicy(retry))
+	resp, err := agent.Respond(ctx, "hi")
+	if err != nil || resp.Text != "flaky answer" || resp.Model != "flaky-model" || flaky.calls != 3 {
+		t.Fatalf("retry: %+v, %v, calls=%d", resp, err, flaky.calls)
+	}
+
+	// 无法解析与持续失败的模型依次被跳过，记录最终回答的模型
+	broken := registerFlaky(t, "broken-model", 100, "")
+	registerFlaky(t, "backup-model", 0, "backup answer")
+	agent = NewAgent(WithName("falling_back"), WithModel("no-such-model"),
+		WithFallbackModels("broken-model", "backup-model"), WithRetryPolicy(retry))
+	resp, err = agent.Respond(ctx, "hi")
+	if err != nil || resp.Text != "backup answer" || resp.Model != "backup-model" || len(resp.Failures) != 2 {
+		t.Fatalf("fallback: %+v, %v", resp, err)
+	}
+	if broken.calls != 3 {
+		t.Errorf("broken model called %d times, want 3", broken.calls)
+	}
+
+	// 没有可用模型时返回类型化错误而非占位文本
+	agent = NewAgent(WithName("unavailable"), WithModel("no-such-model"), WithFallbackModels("no-such-model-2"))
+	out, err := agent.Process(ctx, "hi")
+	var unavailable *ModelUnavailableError
+	if out != "" || !errors.Is(err, ErrModelUnavailable) || !errors.As(err, &unavailable) || len(unavailable.Models) != 2 {
+		t.Errorf("unavailable: %q, %v", out, err)
+	}
+}
+
+// exhaustedModel 依次返回 replies，用完后返回错误。
+type exhaustedModel struct {
+	scriptedModel
+	calls int
+}
+
+func (m *exhaustedModel) Generate(ctx context.Context, messages []models.Message) (string, error) {
+	m.calls++
+	if m.calls > len(m.replies) {
+		return "", errors.New("503 service unavailable")
+	}
+	return m.replies[m.calls-1], nil
+}
+
+func TestModelFallbackKeepsToolsAndBudget(t *testing.T) {
+	ctx := context.Background()
+	saves := 0
+	save := tools.NewTool("save", "saves a record", tools.ToolSchema{},
+		func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			saves++
+			return map[string]interface{}{"saved": saves}, nil
+		})
+	register := func(m models.Model) {
+		models.GetRegistry().Register(m)
+		t.Cleanup(func() { models.GetRegistry().Unregister(m.Name()) })
+	}
+
+	// 主模型执行工具后失败，备用模型请求相同的工具调用时复用结果而不重复执行
+	saveCall := `{"tool_name": "save", "parameters": {"id": "r1"}}`
+	register(&exhaustedModel{scriptedModel: scriptedModel{name: "saving-primary", replies: []string{saveCall}}})
+	backup := registerScripted(t, "saving-backup", saveCall, "saved r1")
+	agent := NewAgent(WithName("saver"), WithModel("saving-primary"), WithFallbackModels("saving-backup"), WithTools(save))
+	resp, err := agent.Respond(ctx, "save r1")
+	if err != nil || resp.Text != "saved r1" || resp.Model != "saving-backup" {
+		t.Fatalf("fallback: %+v, %v", resp, err)
+	}
+	if saves != 1 {
+		t.Errorf("tool ran %d times, want 1", saves)
+	}
+	if last := backup.received[1][len(backup.received[1])-1]; !strings.Contains(last.Content, `"saved": 1`) {
+		t.Errorf("reused result not sent to the backup model: %q", last.Content)
+	}
+
+	// 调用次数上限由全部模型共享
+	var replies []string
+	for i := 0; i < 6; i++ {
+		replies = append(replies, fmt.Sprintf(`{"tool_name": "save", "parameters": {"id": "p%d"}}`, i))
+	}
+	register(&exhaustedModel{scriptedModel: scriptedModel{name: "looping-primary", replies: replies}})
+	looping := &exhaustedModel{scriptedModel: scriptedModel{name: "looping-backup", replies: replies}}
+	register(looping)
+	agent = NewAgent(WithName("looper"), WithModel("looping-primary"), WithFallbackModels("looping-backup"), WithTools(save))
+	if _, err := agent.Respond(ctx, "loop"); !errors.Is(err, ErrLlmCallsExceeded) {
+		t.Fatalf("err = %v, want ErrLlmCallsExceeded", err)
+	}
+	if looping.calls != DefaultMaxLlmCalls-7 {
+		t.Errorf("backup model called %d times, want %d", looping.calls, DefaultMaxLlmCalls-7)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/nvcnvn/adk-golang/pkg/schema"
+)
+
+// DefaultOutputRepairs is the number of repair prompts sent when the output of
+// an agent does not match its output schema, see WithOutputRepairs.
+const DefaultOutputRepairs = 2
+
+// ErrInvalidOutput is matched by OutputError.
+var ErrInvalidOutput = errors.New("invalid structured output")
+
+// OutputError is returned when the output of an agent is not valid JSON, does
+// not match the output schema after all repairs, or cannot be decoded into the
+// value passed to ProcessJSON.
+type OutputError struct {
+	Agent string
+	// Raw is the last output of the model, before JSON extraction
+	Raw string
+	// Attempts is the number of responses that were checked
+	Attempts int
+	// Err is a *schema.ValidationError, or the decoding error
+	Err error
+}
+
+// Error implements error.
+func (e *OutputError) Error() string {
+	return fmt.Sprintf("agent %s: invalid output after %d attempts: %v", e.Agent, e.Attempts, e.Err)
+}
+
+// Unwrap returns the validation or decoding error.
+func (e *OutputError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrInvalidOutput.
+func (e *OutputError) Is(target error) bool {
+	return target == ErrInvalidOutput
+}
+
+// outputInstruction describes the expected output for the system prompt.
+func (a *Agent) outputInstruction() string {
+	if a.outputSchema == nil {
+		return ""
+	}
+	return "\n\nRespond only with a JSON value matching this JSON Schema, without any other text:\n" + a.outputSchema.String()
+}
+
+// respondStructured runs respond and checks the output against the output
+// schema. An invalid output is sent back to the model with the validation
+// errors, up to outputRepairs times. On success the response text is the
+// extracted JSON and Value its decoded form.
+func (a *Agent) respondStructured(ctx context.Context, message string) (*Response, error) {
+	prompt := message
+	for attempt := 1; ; attempt++ {
+		resp, err := a.respond(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		text, value, err := checkOutput(a.outputSchema, resp.Text)
+		if err == nil {
+			resp.Text, resp.Value, resp.Repairs = text, value, attempt-1
+			return resp, nil
+		}
+		if attempt > a.outputRepairs {
+			return nil, &OutputError{Agent: a.name, Raw: resp.Text, Attempts: attempt, Err: err}
+		}
+
+		log.Printf("[Agent] 输出不符合 schema，agent: %s, 第 %d 次修复: %v", a.name, attempt, err)
+		prompt = repairPrompt(message, resp.Text, err)
+	}
+}
+
+// checkOutput extracts the JSON value of output and validates it against s,
+// which may be nil to only require valid JSON.
+func checkOutput(s *schema.Schema, output string) (string, interface{}, error) {
+	text := extractJSON(output)
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return "", nil, &schema.ValidationError{Errors: []schema.FieldError{{Path: "$", Message: "不是合法的 JSON: " + err.Error()}}}
+	}
+	if s != nil {
+		if err := s.Validate(value); err != nil {
+			return "", nil, err
+		}
+	}
+	return text, value, nil
+}
+
+// repairPrompt asks the model to correct its previous output.
+func repairPrompt(message, output string, err error) string {
+	var b strings.Builder
+	b.WriteString(message)
+	b.WriteString("\n\nYour previous answer was:\n")
+	b.WriteString(output)
+	b.WriteString("\n\nIt does not match the required JSON Schema:\n")
+	var verr *schema.ValidationError
+	if errors.As(err, &verr) {
+		for _, fe := range verr.Errors {
+			fmt.Fprintf(&b, "- %s: %s\n", fe.Path, fe.Message)
+		}
+	} else {
+		fmt.Fprintf(&b, "- %v\n", err)
+	}
+	b.WriteString("\nRespond again with only the corrected JSON.")
+	return b.String()
+}
+
+// extractJSON returns the JSON value in output, removing a code fence or the
+// text around the outermost object or array.
+func extractJSON(output string) string {
+	text := trimCodeFence(output)
+	if json.Valid([]byte(text)) {
+		return text
+	}
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return text
+	}
+	closing := "}"
+	if text[start] == '[' {
+		closing = "]"
+	}
+	if end := strings.LastIndex(text, closing); end > start {
+		return text[start : end+1]
+	}
+	return text
+}
+
+// ProcessJSON handles a message like Process and decodes the JSON output into
+// out. The output is validated against the output schema of the agent when it
+// has one, see WithOutputSchema; otherwise it only has to be valid JSON. The
+// returned error is an *OutputError, carrying the raw output, when no valid
+// output was produced.
+func (a *Agent) ProcessJSON(ctx context.Context, message string, out interface{}) error {
+	resp, err := a.Respond(ctx, message)
+	if err != nil {
+		return err
+	}
+	if a.outputSchema == nil {
+		text, _, err := checkOutput(nil, resp.Text)
+		if err != nil {
+			return &OutputError{Agent: a.name, Raw: resp.Text, Attempts: 1, Err: err}
+		}
+		resp.Text = text
+	}
+	if err := json.Unmarshal([]byte(resp.Text), out); err != nil {
+		return &OutputError{Agent: a.name, Raw: resp.Text, Attempts: resp.Repairs + 1, Err: err}
+	}
+	return nil
+}
+
+// OutputSchema returns the output schema of the agent, nil if it has none.
+func (a *Agent) OutputSchema() *schema.Schema {
+	return a.outputSchema
+}
@@ -0,0 +1,47 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/schema"
+)
+
+func TestOutputSchema(t *testing.T) {
+	ctx := context.Background()
+	s, err := schema.Parse([]byte(`{"type":"object","required":["title","score"],"properties":{"title":{"type":"string"},"score":{"type":"integer"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 第一次缺少字段，修复提示携带校验错误后得到合法输出
+	m := registerScripted(t, "schema-model",
+		`{"title": "draft"}`,
+		"```json\n{\"title\": \"final\", \"score\": 3}\n```")
+	agent := NewAgent(WithName("structured"), WithModel("schema-model"), WithOutputSchema(s))
+	var out struct {
+		Title string `json:"title"`
+		Score int    `json:"score"`
+	}
+	if err := agent.ProcessJSON(ctx, "rate it", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Title != "final" || out.Score != 3 {
+		t.Errorf("decoded %+v", out)
+	}
+	if len(m.received) != 2 || !strings.Contains(m.received[0][0].Content, `"required"`) ||
+		!strings.Contains(m.received[1][1].Content, "score") {
+		t.Errorf("unexpected prompts: %+v", m.received)
+	}
+
+	// 修复次数用尽后返回带原始输出的类型化错误
+	registerScripted(t, "bad-schema-model", "not json at all")
+	agent = NewAgent(WithName("unrepairable"), WithModel("bad-schema-model"), WithOutputSchema(s), WithOutputRepairs(1))
+	err = agent.ProcessJSON(ctx, "rate it", &out)
+	var outErr *OutputError
+	if !errors.Is(err, ErrInvalidOutput) || !errors.As(err, &outErr) || outErr.Raw != "not json at all" || outErr.Attempts != 2 {
+		t.Errorf("unrepairable: %v", err)
+	}
+}
@@ -17,16 +17,22 @@ package agents
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
-	"sync"
+	"time"
 )
 
 // ParallelAgent runs its sub-agents in parallel and aggregates their responses.
 type ParallelAgent struct {
 	Agent
-	subAgents []*Agent
-	workers   int
+	subAgents    []*Agent
+	workers      int
+	aggregation  AggregateMode
+	quorum       int
+	reducer      Reducer
+	allowPartial bool
 }
 
 // ParallelAgentConfig holds configuration for creating a ParallelAgent.
@@ -35,6 +41,22 @@ type ParallelAgentConfig struct {
 	Description string
 	SubAgents   []*Agent
 	Workers     int // 最大并发工作协程数，<=0 为 len(SubAgents)
+
+	// Agents are appended after SubAgents and may be any Runnable,
+	// including other composite agents.
+	Agents []Runnable
+
+	// Aggregation selects how the responses are combined, see AggregateMode.
+	Aggregation AggregateMode
+	// Quorum is the number of identical responses required in
+	// AggregateQuorum mode, <=0 for a majority of the sub-agents.
+	Quorum int
+	// Reducer, when set, combines the results instead of Aggregation.
+	Reducer Reducer
+	// AllowPartial treats the run as successful when at least one sub-agent
+	// succeeds: failed sub-agents are logged and skipped, and remain in
+	// ParallelResult.Errors.
+	AllowPartial bool
 }
 
 // MultiError 聚合并行执行过程中发生的多个错误。
@@ -60,75 +82,178 @@ func (m MultiError) Error() string {
 	return fmt.Sprintf("encountered %d error(s): %s", len(parts), strings.Join(parts, "; "))
 }
 
+// Unwrap 返回全部错误，便于 errors.Is / errors.As 匹配其中任意一个。
+func (m MultiError) Unwrap() []error {
+	return m
+}
+
 // NewParallelAgent creates a new agent that processes sub-agents in parallel.
+// As with NewSequentialAgent, the fan-out is bound as the process function of
+// the embedded Agent.
 func NewParallelAgent(config ParallelAgentConfig) *ParallelAgent {
+    subAgents := collectSubAgents(config.SubAgents, config.Agents)
     workers := config.Workers
     if workers <= 0 {
-        workers = len(config.SubAgents)
+        workers = len(subAgents)
     }
-    return &ParallelAgent{
+    agent := &ParallelAgent{
         Agent: Agent{
             name:        config.Name,
             description: config.Description,
+            subAgents:   subAgents,
+            kind:        AgentKindParallel,
         },
-        subAgents: config.SubAgents,
-        workers:   workers,
+        subAgents:    subAgents,
+        workers:      workers,
+        aggregation:  config.Aggregation,
+        quorum:       config.Quorum,
+        reducer:      config.Reducer,
+        allowPartial: config.AllowPartial,
     }
+    agent.processFunc = agent.run
+    agent.adoptSubAgents()
+    return agent
 }
 
-// Process 处理输入消息，按配置的 worker 数并发执行所有子 Agent，收敛错误并支持 ctx 取消。
-func (a *ParallelAgent) Process(ctx context.Context, message string) (string, error) {
+// run 执行 RunParallel 并返回聚合后的响应。
+func (a *ParallelAgent) run(ctx context.Context, message string) (string, error) {
+    result, err := a.RunParallel(ctx, message)
+    if result == nil {
+        return "", err
+    }
+    return result.Output, err
+}
+
+// RunParallel 按配置的 worker 数并发执行所有子 Agent，并按 Aggregation 聚合响应。
+// 返回的 ParallelResult 按子 Agent 顺序记录每个子 Agent 的输出与错误，
+// 因此部分失败时仍可拿到成功部分；此时 error 为 *AgentError 组成的 MultiError。
+// AggregateFirst / AggregateQuorum 在结果确定后取消其余子 Agent，仅在无法确定结果时返回错误。
+func (a *ParallelAgent) RunParallel(ctx context.Context, message string) (*ParallelResult, error) {
     if len(a.subAgents) == 0 {
-        return "", nil
+        return &ParallelResult{}, nil
     }
 
-    if a.workers <= 0 {
-        a.workers = len(a.subAgents)
+    // 若上层已经取消，则提前退出
+    select {
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    default:
     }
 
+    workers := a.workers
+    if workers <= 0 {
+        workers = len(a.subAgents)
+    }
+
+    runCtx, cancel := context.WithCancel(ctx)
+    defer cancel()
+
     var (
-        wg        sync.WaitGroup
-        sem       = make(chan struct{}, a.workers)
-        mu        sync.Mutex
-        responses []string
-        errs      []error
+        sem     = make(chan struct{}, workers)
+        done    = make(chan int, len(a.subAgents))
+        results = make([]AgentResult, len(a.subAgents))
     )
 
-    for _, subAgent := range a.subAgents {
-        // 若上层已经取消，则提前退出
-        select {
-        case <-ctx.Done():
-            return "", ctx.Err()
-        default:
-        }
-
-        wg.Add(1)
-        go func(sa *Agent) {
-            defer wg.Done()
+    for i, subAgent := range a.subAgents {
+        go func(i int, sa *Agent) {
+            defer func() { done <- i }()
+            results[i] = AgentResult{Agent: sa.Name(), Index: i}
 
-            // 限流：获取 token
-            sem <- struct{}{}
+            // 限流：获取 token，结果已确定时不再启动
+            select {
+            case sem <- struct{}{}:
+            case <-runCtx.Done():
+                results[i].Err = runCtx.Err()
+                return
+            }
             defer func() { <-sem }()
 
-            resp, err := sa.Process(ctx, message)
+            start := time.Now()
+            resp, err := runStep(runCtx, sa, message)
+            results[i].Output, results[i].Err, results[i].Duration = resp, err, time.Since(start)
+        }(i, subAgent)
+    }
 
-            mu.Lock()
-            defer mu.Unlock()
-            if err != nil {
-                errs = append(errs, err)
-                return
+    // 按完成顺序收集结果，竞速与投票模式在结果确定后取消其余子 Agent
+    var (
+        order   = make([]int, 0, len(a.subAgents))
+        votes   = map[string]int{}
+        decided = -1
+    )
+    for range a.subAgents {
+        i := <-done
+        order = append(order, i)
+        if decided >= 0 || results[i].Err != nil {
+            continue
+        }
+        switch {
+        case a.reducer != nil:
+            // 自定义 Reducer 需要全部结果
+        case a.aggregation == AggregateFirst:
+            decided = i
+        case a.aggregation == AggregateQuorum:
+            key := quorumKey(results[i].Output)
+            votes[key]++
+            if votes[key] >= a.requiredVotes() {
+                decided = i
             }
-            responses = append(responses, resp)
-        }(subAgent)
+        }
+        if decided >= 0 {
+            cancel()
+        }
     }
 
-    wg.Wait()
+    result := &ParallelResult{Results: results}
+    for _, r := range results {
+        if r.Err == nil || (decided >= 0 && errors.Is(r.Err, context.Canceled) && ctx.Err() == nil) {
+            continue
+        }
+        result.Errors = append(result.Errors, &AgentError{Agent: r.Agent, Err: r.Err})
+    }
+
+    var err error
+    switch {
+    case a.reducer != nil:
+        result.Output, err = a.reducer(results)
+    case a.aggregation == AggregateOrdered:
+        ordered := make([]int, len(results))
+        for i := range ordered {
+            ordered[i] = i
+        }
+        result.Output = joinOutputs(results, ordered)
+    case a.aggregation == AggregateJSON:
+        result.Output, err = jsonOutputs(results)
+    case a.aggregation == AggregateFirst, a.aggregation == AggregateQuorum:
+        if decided >= 0 {
+            result.Output = results[decided].Output
+            return result, nil
+        }
+        if a.aggregation == AggregateQuorum {
+            err = fmt.Errorf("%w (%d of %d)", ErrNoQuorum, a.requiredVotes(), len(a.subAgents))
+        }
+    default:
+        result.Output = joinOutputs(results, order)
+    }
+
+    if err != nil {
+        return result, err
+    }
+    if len(result.Errors) > 0 {
+        if a.allowPartial && len(result.Errors) < len(results) {
+            log.Printf("ParallelAgent %s: skipping failed sub-agents: %v", a.Name(), result.Errors)
+            return result, nil
+        }
+        return result, result.Errors
+    }
+    return result, nil
+}
 
-    combined := strings.Join(responses, "\n")
-    if len(errs) > 0 {
-        return combined, MultiError(errs)
+// requiredVotes 返回 AggregateQuorum 模式下需要的相同响应数。
+func (a *ParallelAgent) requiredVotes() int {
+    if a.quorum > 0 {
+        return a.quorum
     }
-    return combined, nil
+    return len(a.subAgents)/2 + 1
 }
 
 // SubAgents returns the sub-agents of this parallel agent.
@@ -0,0 +1,122 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// delayed 在 d 后返回 out；ctx 取消时返回 ctx.Err()。
+func delayed(name, out string, d time.Duration, err error) *Agent {
+	return NewAgent(WithName(name), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		select {
+		case <-time.After(d):
+			return out, err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}))
+}
+
+func TestParallelAggregation(t *testing.T) {
+	boom := errors.New("boom")
+	fanout := func(mode AggregateMode, agents ...*Agent) *ParallelAgent {
+		return NewParallelAgent(ParallelAgentConfig{Name: "fanout", SubAgents: agents, Aggregation: mode})
+	}
+	ctx := context.Background()
+
+	// 按子 Agent 顺序输出，错误附带 Agent 名称并保留部分结果
+	res, err := fanout(AggregateOrdered,
+		delayed("slow", "1", 20*time.Millisecond, nil),
+		delayed("broken", "", 0, boom),
+		delayed("fast", "3", 0, nil),
+	).RunParallel(ctx, "x")
+	var agentErr *AgentError
+	if !errors.As(err, &agentErr) || agentErr.Agent != "broken" || !errors.Is(err, boom) {
+		t.Fatalf("err = %v", err)
+	}
+	if res.Output != "1\n3" || res.Results[1].Err == nil || res.Results[2].Output != "3" {
+		t.Errorf("ordered result = %+v", res)
+	}
+
+	res, err = fanout(AggregateJSON, delayed("a", "x", 0, nil), delayed("b", "y", 0, nil)).RunParallel(ctx, "x")
+	if err != nil || res.Output != `{"a":"x","b":"y"}` {
+		t.Errorf("json = %q, %v", res.Output, err)
+	}
+
+	// 竞速：首个成功结果胜出，其余被取消且不算错误
+	start := time.Now()
+	res, err = fanout(AggregateFirst,
+		delayed("broken", "", 0, boom),
+		delayed("fast", "fast", 5*time.Millisecond, nil),
+		delayed("slow", "slow", time.Second, nil),
+	).RunParallel(ctx, "x")
+	if err != nil || res.Output != "fast" || !errors.Is(res.Results[2].Err, context.Canceled) {
+		t.Errorf("first = %+v, %v", res, err)
+	}
+	if len(res.Errors) != 1 || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("first should report only the real failure and not wait: %v", res.Errors)
+	}
+
+	res, err = fanout(AggregateQuorum,
+		delayed("a", "yes", 0, nil),
+		delayed("b", " yes\n", 0, nil),
+		delayed("c", "no", 0, nil),
+	).RunParallel(ctx, "x")
+	if err != nil || strings.TrimSpace(res.Output) != "yes" {
+		t.Errorf("quorum = %q, %v", res.Output, err)
+	}
+	_, err = fanout(AggregateQuorum, delayed("a", "yes", 0, nil), delayed("b", "no", 0, nil)).RunParallel(ctx, "x")
+	if !errors.Is(err, ErrNoQuorum) {
+		t.Errorf("quorum err = %v", err)
+	}
+
+	reduce := NewParallelAgent(ParallelAgentConfig{
+		Name:      "reduce",
+		SubAgents: []*Agent{delayed("a", "1", 0, nil), delayed("b", "2", 0, nil)},
+		Reducer: func(results []AgentResult) (string, error) {
+			return results[1].Output + results[0].Output, nil
+		},
+	})
+	if out, err := reduce.Process(ctx, "x"); err != nil || out != "21" {
+		t.Errorf("reducer = %q, %v", out, err)
+	}
+}
+
+// TestParallelAllowPartial 验证 AllowPartial 时单个子 Agent 失败不影响后续步骤处理其余输出
+func TestParallelAllowPartial(t *testing.T) {
+	boom := errors.New("boom")
+	creation := NewParallelAgent(ParallelAgentConfig{
+		Name:         "creation",
+		SubAgents:    []*Agent{delayed("plot", "p", 0, nil), delayed("dialogue", "", 0, boom), delayed("background", "b", 0, nil)},
+		Aggregation:  AggregateJSON,
+		AllowPartial: true,
+	})
+	var formatted string
+	formatter := NewAgent(WithName("formatter"), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		formatted = message
+		return "formatted", nil
+	}))
+	root := NewSequentialAgent(SequentialAgentConfig{Name: "execution", Agents: []Runnable{creation, formatter}})
+
+	out, err := root.Process(context.Background(), "x")
+	if err != nil || out != "formatted" || formatted != `{"background":"b","plot":"p"}` {
+		t.Fatalf("out = %q, formatter input = %q, err = %v", out, formatted, err)
+	}
+	res, err := creation.RunParallel(context.Background(), "x")
+	if err != nil || len(res.Errors) != 1 || res.Errors[0].(*AgentError).Agent != "dialogue" {
+		t.Errorf("partial result = %+v, %v", res, err)
+	}
+
+	// 全部失败时仍返回错误
+	allFailed := NewParallelAgent(ParallelAgentConfig{
+		Name:         "creation",
+		SubAgents:    []*Agent{delayed("plot", "", 0, boom)},
+		AllowPartial: true,
+	})
+	if _, err := allFailed.RunParallel(context.Background(), "x"); !errors.Is(err, boom) {
+		t.Errorf("all failed err = %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AggregateMode selects how a ParallelAgent combines the responses of its
+// sub-agents.
+type AggregateMode string
+
+const (
+	// AggregateConcat joins the successful responses with "\n" in completion
+	// order. It is the default.
+	AggregateConcat AggregateMode = ""
+
+	// AggregateOrdered joins the successful responses with "\n" in sub-agent
+	// order.
+	AggregateOrdered AggregateMode = "ordered"
+
+	// AggregateJSON returns a JSON object mapping each successful sub-agent
+	// name to its response.
+	AggregateJSON AggregateMode = "json"
+
+	// AggregateFirst returns the first successful response and cancels the
+	// sub-agents that are still running.
+	AggregateFirst AggregateMode = "first"
+
+	// AggregateQuorum returns the response given by at least Quorum
+	// sub-agents, a majority by default, and cancels the rest once reached.
+	// Responses are compared after trimming surrounding whitespace.
+	AggregateQuorum AggregateMode = "quorum"
+)
+
+// ErrNoQuorum is returned in AggregateQuorum mode when no response reaches the
+// quorum.
+var ErrNoQuorum = errors.New("no response reached the quorum")
+
+// AgentResult is the outcome of one sub-agent of a ParallelAgent.
+type AgentResult struct {
+	// Agent is the name of the sub-agent
+	Agent string `json:"agent"`
+	// Index is the position of the sub-agent in the ParallelAgent
+	Index int `json:"index"`
+	// Output is the response, empty when Err is set
+	Output string `json:"output,omitempty"`
+	// Err is the failure of the sub-agent, context.Canceled when it was
+	// cancelled after the result had been decided
+	Err error `json:"-"`
+	// Duration is how long the sub-agent ran
+	Duration time.Duration `json:"duration"`
+}
+
+// Reducer combines the results of all sub-agents, ordered by sub-agent, into
+// the ParallelAgent response.
+type Reducer func(results []AgentResult) (string, error)
+
+// ParallelResult is the outcome of a ParallelAgent run.
+type ParallelResult struct {
+	// Output is the aggregated response
+	Output string
+	// Results holds one entry per sub-agent, ordered by sub-agent
+	Results []AgentResult
+	// Errors holds an *AgentError for every sub-agent that failed, except
+	// those cancelled after the result had been decided
+	Errors MultiError
+}
+
+// AgentError attributes a sub-agent failure to the sub-agent.
+type AgentError struct {
+	Agent string
+	Err   error
+}
+
+// Error implements error.
+func (e *AgentError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Agent, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *AgentError) Unwrap() error {
+	return e.Err
+}
+
+// quorumKey normalises a response for voting.
+func quorumKey(output string) string {
+	return strings.TrimSpace(output)
+}
+
+// joinOutputs joins the successful outputs of results in the given order.
+func joinOutputs(results []AgentResult, order []int) string {
+	var parts []string
+	for _, i := range order {
+		if results[i].Err == nil {
+			parts = append(parts, results[i].Output)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// jsonOutputs encodes the successful outputs as an object keyed by agent name.
+func jsonOutputs(results []AgentResult) (string, error) {
+	obj := make(map[string]string, len(results))
+	for _, r := range results {
+		if r.Err == nil {
+			obj[r.Agent] = r.Output
+		}
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -15,35 +15,177 @@
 package agents
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/nvcnvn/adk-golang/pkg/events"
-	"github.com/nvcnvn/adk-golang/pkg/models"
 	"github.com/nvcnvn/adk-golang/pkg/telemetry"
 )
 
-// RemoteAgent represents an agent that runs remotely via HTTP API calls.
+// Paths of the remote agent protocol, relative to the base URL of the server.
+//
+//	POST /api/remote/invoke  runs a RemoteRequest and returns a RemoteResponse
+//	POST /api/remote/stream  runs a RemoteRequest and streams its events as
+//	                         server-sent events, the last one FinalResponse or
+//	                         RunFailed
+//	POST /api/remote/cancel  cancels a running invocation, the body is a
+//	                         RemoteCancelRequest
+//
+// Every call carries an "Authorization: Bearer <token>" header.
+const (
+	RemoteInvokePath = "/api/remote/invoke"
+	RemoteStreamPath = "/api/remote/stream"
+	RemoteCancelPath = "/api/remote/cancel"
+)
+
+// RemoteRequest is the body of the invoke and stream calls.
+type RemoteRequest struct {
+	// InvocationID identifies the invocation on the server, for cancel
+	InvocationID string `json:"invocation_id"`
+	// Workflow is the workflow that handles the input
+	Workflow string `json:"workflow"`
+	// Version pins a version of the workflow, empty for the weighted choice
+	Version string `json:"version,omitempty"`
+	// Agent selects a sub-agent of the workflow, empty for its root
+	Agent string `json:"agent,omitempty"`
+	// Input is the message to process
+	Input string `json:"input"`
+	// Parameters resolve the placeholders of the instructions
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	// UserID and ArchiveID identify the user and archive the run acts for,
+	// like the user_id and archive_id of /api/execute
+	UserID    string `json:"user_id,omitempty"`
+	ArchiveID string `json:"archive_id,omitempty"`
+	// Timeout bounds the run on the server in seconds, the server default
+	// when zero
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// RemoteResponse is the body of the response to the invoke call.
+type RemoteResponse struct {
+	InvocationID string `json:"invocation_id"`
+	// Output is the text of the final response
+	Output string `json:"output,omitempty"`
+	// Events are the events of the run, the final one included
+	Events []*events.Event `json:"events,omitempty"`
+	// Error and ErrorCode describe the failure of the run
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// RemoteCancelRequest is the body of the cancel call.
+type RemoteCancelRequest struct {
+	InvocationID string `json:"invocation_id"`
+}
+
+// RemoteError reports a failed call to a remote agent: a rejected request or a
+// failed run on the server.
+type RemoteError struct {
+	// Agent is the name of the remote agent
+	Agent string
+	// StatusCode is the HTTP status, 0 when the run itself failed
+	StatusCode int
+	// Code is the error code of the RunFailed event, if any
+	Code    string
+	Message string
+}
+
+// Error implements error.
+func (e *RemoteError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("remote agent %s: status %d: %s", e.Agent, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("remote agent %s: %s", e.Agent, e.Message)
+}
+
+// RemoteAgentConfig configures a RemoteAgent.
+type RemoteAgentConfig struct {
+	Name        string
+	Description string
+
+	// URL is the base URL of the server, e.g. "http://writer:8080"
+	URL string
+	// Token is sent as the bearer token of every call
+	Token string
+
+	// Workflow, Version and Agent select the agent on the server; Workflow
+	// defaults to Name.
+	Workflow string
+	Version  string
+	Agent    string
+
+	// Parameters are sent with every call, see RemoteRequest.
+	Parameters map[string]interface{}
+
+	// Stream relays the events of the remote run into the local invocation
+	// as they happen. Without it the events are relayed when the run ends.
+	Stream bool
+
+	// HTTPClient sends the calls, http.DefaultClient when nil. Deadlines come
+	// from the context of the call.
+	HTTPClient *http.Client
+}
+
+// RemoteAgent represents an agent that runs in another deployment and is called
+// through the remote agent protocol. It is a Runnable, so it can be a child of
+// the composite agents; the events of the remote run are recorded on the local
+// invocation under the branch of the RemoteAgent.
 type RemoteAgent struct {
 	name        string
 	url         string
 	description string
+	token       string
+	workflow    string
+	version     string
+	agent       string
+	params      map[string]interface{}
+	stream      bool
 	httpClient  *http.Client
 	parentAgent BaseAgent
+
+	// self represents the remote agent in the agent tree, see AsAgent
+	self *Agent
 }
 
-// NewRemoteAgent creates a new remote agent
+// NewRemoteAgent creates a new remote agent calling the workflow with the same
+// name on the server at url.
 func NewRemoteAgent(name, url, description string) *RemoteAgent {
-	return &RemoteAgent{
-		name:        name,
-		url:         url,
-		description: description,
-		httpClient:  &http.Client{Timeout: 120 * time.Second},
+	return NewRemoteAgentWithConfig(RemoteAgentConfig{Name: name, URL: url, Description: description})
+}
+
+// NewRemoteAgentWithConfig creates a new remote agent from config.
+func NewRemoteAgentWithConfig(config RemoteAgentConfig) *RemoteAgent {
+	a := &RemoteAgent{
+		name:        config.Name,
+		url:         strings.TrimSuffix(config.URL, "/"),
+		description: config.Description,
+		token:       config.Token,
+		workflow:    config.Workflow,
+		version:     config.Version,
+		agent:       config.Agent,
+		params:      config.Parameters,
+		stream:      config.Stream,
+		httpClient:  config.HTTPClient,
 	}
+	if a.workflow == "" {
+		a.workflow = a.name
+	}
+	if a.httpClient == nil {
+		a.httpClient = http.DefaultClient
+	}
+	a.self = NewAgent(
+		WithName(a.name),
+		WithDescription(a.Description()),
+		WithProcessFunc(a.Process),
+	)
+	return a
 }
 
 // Name returns the name of the agent
@@ -59,111 +201,210 @@ func (a *RemoteAgent) Description() string {
 	return a.description
 }
 
-// Run executes the agent with the given invocation context
-func (a *RemoteAgent) Run(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
-	eventCh := make(chan *events.Event)
+// node returns the agent that represents the remote agent in the agent tree.
+func (a *RemoteAgent) node() *Agent {
+	return a.self
+}
 
-	ctx, span := telemetry.StartSpan(ctx, "RemoteAgent.Run")
+// Process sends message to the remote agent and returns its final response.
+// Within an invocation the events of the remote run are recorded on it, with
+// their branch under the branch of the caller. Cancelling ctx cancels the
+// remote run.
+func (a *RemoteAgent) Process(ctx context.Context, message string) (string, error) {
+	ctx, span := telemetry.StartSpan(ctx, "RemoteAgent.Process")
 	defer span.End()
-
 	span.SetAttribute("agent.name", a.name)
 	span.SetAttribute("agent.url", a.url)
 
-	data := map[string]interface{}{
-		"invocation_id": invocationContext.InvocationID,
-		"context": map[string]interface{}{
-			"invocation_id": invocationContext.InvocationID,
-			"agent_name":    a.name,
-		},
+	req := RemoteRequest{
+		InvocationID: events.GenerateID(),
+		Workflow:     a.workflow,
+		Version:      a.version,
+		Agent:        a.agent,
+		Input:        message,
+		Parameters:   a.params,
+		Timeout:      remoteTimeout(ctx),
 	}
+	// 远端以调用方的用户与归档身份运行
+	req.UserID, _ = ctx.Value("user_id").(string)
+	req.ArchiveID, _ = ctx.Value("archive_id").(string)
+	// 本地调用取消时通知远端停止运行
+	stop := context.AfterFunc(ctx, func() { a.cancel(req.InvocationID) })
+	defer stop()
 
-	go func() {
-		defer close(eventCh)
-
-		jsonData, err := json.Marshal(data)
-		if err != nil {
-			span.SetAttribute("error", err.Error())
-			eventCh <- &events.Event{
-				InvocationID: invocationContext.InvocationID,
-				Author:       a.name,
-				Content: &events.Content{
-					Parts: []*models.Part{
-						{Text: fmt.Sprintf("Error preparing request: %v", err)},
-					},
-				},
-			}
-			return
+	var (
+		output string
+		err    error
+	)
+	if a.stream {
+		output, err = a.callStream(ctx, req)
+	} else {
+		output, err = a.callInvoke(ctx, req)
+	}
+	if err != nil {
+		span.SetAttribute("error", err.Error())
+		if ctx.Err() != nil {
+			return "", ctx.Err()
 		}
+		return "", err
+	}
+	return output, nil
+}
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewBuffer(jsonData))
+// remoteTimeout returns the time left before the deadline of ctx in whole
+// seconds, rounded up, or 0 without a deadline.
+func remoteTimeout(ctx context.Context) int {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	left := time.Until(deadline)
+	if left <= 0 {
+		return 1
+	}
+	return int((left + time.Second - 1) / time.Second)
+}
+
+// callInvoke runs req with the invoke call and relays its events.
+func (a *RemoteAgent) callInvoke(ctx context.Context, req RemoteRequest) (string, error) {
+	resp, err := a.post(ctx, RemoteInvokePath, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var out RemoteResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("remote agent %s: decode response: %w", a.name, err)
+	}
+	for _, event := range out.Events {
+		a.relay(ctx, event)
+	}
+	if out.Error != "" {
+		return "", &RemoteError{Agent: a.name, Code: out.ErrorCode, Message: out.Error}
+	}
+	return out.Output, nil
+}
+
+// callStream runs req with the stream call and relays its events as they
+// arrive.
+func (a *RemoteAgent) callStream(ctx context.Context, req RemoteRequest) (string, error) {
+	resp, err := a.post(ctx, RemoteStreamPath, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		data, err := readSSEData(reader)
 		if err != nil {
-			span.SetAttribute("error", err.Error())
-			eventCh <- &events.Event{
-				InvocationID: invocationContext.InvocationID,
-				Author:       a.name,
-				Content: &events.Content{
-					Parts: []*models.Part{
-						{Text: fmt.Sprintf("Error creating request: %v", err)},
-					},
-				},
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
 			}
-			return
+			return "", fmt.Errorf("remote agent %s: read stream: %w", a.name, err)
+		}
+		var event events.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return "", fmt.Errorf("remote agent %s: decode event: %w", a.name, err)
+		}
+		a.relay(ctx, &event)
+		switch event.Type {
+		case events.FinalResponse:
+			return event.Content.GetText(), nil
+		case events.RunFailed:
+			return "", &RemoteError{Agent: a.name, Code: event.ErrorCode, Message: event.ErrorMessage}
 		}
-		req.Header.Set("Content-Type", "application/json")
+	}
+}
 
-		resp, err := a.httpClient.Do(req)
+// readSSEData returns the data of the next server-sent event.
+func readSSEData(r *bufio.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		line, err := r.ReadString('\n')
 		if err != nil {
-			span.SetAttribute("error", err.Error())
-			eventCh <- &events.Event{
-				InvocationID: invocationContext.InvocationID,
-				Author:       a.name,
-				Content: &events.Content{
-					Parts: []*models.Part{
-						{Text: fmt.Sprintf("Error communicating with remote agent: %v", err)},
-					},
-				},
-			}
-			return
+			return nil, err
 		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			errMsg := fmt.Sprintf("Remote agent returned error status: %d", resp.StatusCode)
-			span.SetAttribute("error", errMsg)
-			eventCh <- &events.Event{
-				InvocationID: invocationContext.InvocationID,
-				Author:       a.name,
-				Content: &events.Content{
-					Parts: []*models.Part{
-						{Text: errMsg},
-					},
-				},
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "" && data != nil:
+			return data, nil
+		case strings.HasPrefix(line, "data:"):
+			if data != nil {
+				data = append(data, '\n')
 			}
-			return
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
 		}
+	}
+}
 
-		var responseEvents []*events.Event
-		if err := json.NewDecoder(resp.Body).Decode(&responseEvents); err != nil {
-			span.SetAttribute("error", err.Error())
-			eventCh <- &events.Event{
-				InvocationID: invocationContext.InvocationID,
-				Author:       a.name,
-				Content: &events.Content{
-					Parts: []*models.Part{
-						{Text: fmt.Sprintf("Error parsing response from remote agent: %v", err)},
-					},
-				},
-			}
-			return
+// relay records an event of the remote run on the local invocation, if any.
+// The final event of the remote run is not relayed: its outcome is the
+// response of the remote agent, and the final event of the invocation is the
+// caller's. Branches are nested under the branch of the caller.
+func (a *RemoteAgent) relay(ctx context.Context, event *events.Event) {
+	ic := InvocationContextFromContext(ctx)
+	if ic == nil || event == nil || event.Type == events.FinalResponse || event.Type == events.RunFailed {
+		return
+	}
+	// 远端根智能体通常与本地 RemoteAgent 同名，不重复该段
+	if branch := currentFrame(ctx).branch; branch != "" {
+		name := branch[strings.LastIndex(branch, ".")+1:]
+		switch {
+		case event.Branch == "":
+			event.Branch = branch
+		case event.Branch == name || strings.HasPrefix(event.Branch, name+"."):
+			event.Branch = branch + strings.TrimPrefix(event.Branch, name)
+		default:
+			event.Branch = branch + "." + event.Branch
 		}
+	}
+	event.InvocationID = ic.InvocationID
+	event.Partial = true
+	ic.AddEvent(event)
+}
 
-		for _, event := range responseEvents {
-			event.Author = a.name
-			eventCh <- event
-		}
-	}()
+// post sends a protocol call and checks its status.
+func (a *RemoteAgent) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
 
-	return eventCh, nil
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote agent %s: %w", a.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &RemoteError{Agent: a.name, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// cancel asks the server to stop the invocation, on a context of its own since
+// the context of the call is done.
+func (a *RemoteAgent) cancel(invocationID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if resp, err := a.post(ctx, RemoteCancelPath, RemoteCancelRequest{InvocationID: invocationID}); err == nil {
+		resp.Body.Close()
+	}
+}
+
+// Run executes the agent with the given invocation context
+func (a *RemoteAgent) Run(ctx context.Context, invocationContext *InvocationContext) (<-chan *events.Event, error) {
+	return a.self.Run(ctx, invocationContext)
 }
 
 // RunLive executes the agent in live mode with the given invocation context
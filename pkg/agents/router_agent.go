@@ -0,0 +1,259 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+)
+
+// DefaultMaxTransfers bounds the number of transfers of a RouterAgent run.
+const DefaultMaxTransfers = 5
+
+// ErrTransferLoop is returned when a RouterAgent run exceeds MaxTransfers or
+// repeats a transfer between the same two agents.
+var ErrTransferLoop = errors.New("transfer loop detected")
+
+// RouterAgent lets a model choose which sub-agent handles a message. The
+// model sees the name and description of every sub-agent and calls the
+// transfer_to_agent tool to hand the message over; the chosen sub-agent then
+// produces the response. When the model answers without transferring, its
+// answer is the response.
+//
+// Sub-agents that have tools.TransferToAgentTool can hand the message back to
+// the router by transferring to its name, which lets the router choose again,
+// or pass it directly to a sibling.
+type RouterAgent struct {
+	Agent
+	subAgents    []*Agent
+	maxTransfers int
+
+	// decider is the model agent that chooses the sub-agent
+	decider *Agent
+}
+
+// RouterAgentConfig holds configuration for creating a RouterAgent.
+type RouterAgentConfig struct {
+	Name        string
+	Description string
+	// Model chooses the sub-agent, see WithModel.
+	Model string
+	// Instruction is prepended to the generated routing instruction.
+	Instruction string
+	SubAgents   []*Agent
+
+	// Agents are appended after SubAgents and may be any Runnable,
+	// including other composite agents.
+	Agents []Runnable
+
+	// MaxTransfers bounds the transfers of a run, <=0 for DefaultMaxTransfers.
+	MaxTransfers int
+}
+
+// NewRouterAgent creates a new agent that routes messages to its sub-agents.
+// As with NewSequentialAgent, the routing is bound as the process function of
+// the embedded Agent.
+func NewRouterAgent(config RouterAgentConfig) *RouterAgent {
+	maxTransfers := config.MaxTransfers
+	if maxTransfers <= 0 {
+		maxTransfers = DefaultMaxTransfers
+	}
+
+	subAgents := collectSubAgents(config.SubAgents, config.Agents)
+	agent := &RouterAgent{
+		Agent: Agent{
+			name:        config.Name,
+			model:       config.Model,
+			instruction: config.Instruction,
+			description: config.Description,
+			subAgents:   subAgents,
+			kind:        AgentKindRouter,
+		},
+		subAgents:    subAgents,
+		maxTransfers: maxTransfers,
+	}
+	decider := []Option{
+		WithName(config.Name),
+		WithInstruction(routingInstruction(config.Instruction, subAgents)),
+		WithDescription(config.Description),
+		WithTools(tools.TransferToAgentTool),
+	}
+	if config.Model != "" {
+		decider = append(decider, WithModel(config.Model))
+	}
+	agent.decider = NewAgent(decider...)
+	agent.model = agent.decider.Model()
+	agent.processFunc = agent.run
+	agent.adoptSubAgents()
+	return agent
+}
+
+// routingInstruction lists the sub-agents the model can transfer to.
+func routingInstruction(instruction string, subAgents []*Agent) string {
+	var b strings.Builder
+	if instruction != "" {
+		b.WriteString(instruction)
+		b.WriteString("\n\n")
+	}
+	b.WriteString("You are a router. Choose the agent best suited to handle the request and call the " +
+		"transfer_to_agent tool with its name. Answer directly only if no agent fits.\n\nAgents:\n")
+	for _, sub := range subAgents {
+		fmt.Fprintf(&b, "- %s: %s\n", sub.Name(), sub.Description())
+	}
+	return b.String()
+}
+
+// run routes the message and follows transfers until an agent answers.
+func (a *RouterAgent) run(ctx context.Context, message string) (string, error) {
+	var (
+		current = a.name
+		input   = message
+		seen    = map[string]bool{}
+	)
+	for transfers := 0; ; transfers++ {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		default:
+		}
+
+		agent := a.decider
+		if current != a.name {
+			agent = a.subAgent(current)
+		}
+		t := &transfer{from: current, allowed: a.transferTargets(current)}
+		response, err := agent.Process(t.attach(ctx), input)
+		if err != nil {
+			return "", err
+		}
+
+		target := t.requested()
+		if target == "" {
+			return response, nil
+		}
+
+		hop := current + "->" + target
+		if transfers >= a.maxTransfers || seen[hop] {
+			return "", fmt.Errorf("%w: %s (%d transfers)", ErrTransferLoop, hop, transfers+1)
+		}
+		seen[hop] = true
+		a.recordTransfer(ctx, current, target)
+		log.Printf("[RouterAgent] %s 转交: %s", a.name, hop)
+
+		// 转回路由时附上转出方的说明，便于重新选择
+		input = message
+		if target == a.name && strings.TrimSpace(response) != "" {
+			input = fmt.Sprintf("%s\n\n[%s transferred the request back: %s]", message, current, strings.TrimSpace(response))
+		}
+		current = target
+	}
+}
+
+// transferTargets returns the agents that from can transfer to: the router
+// can transfer to its sub-agents, a sub-agent to the router and its siblings.
+func (a *RouterAgent) transferTargets(from string) map[string]bool {
+	targets := map[string]bool{}
+	if from != a.name {
+		targets[a.name] = true
+	}
+	for _, sub := range a.subAgents {
+		if sub.Name() != from {
+			targets[sub.Name()] = true
+		}
+	}
+	return targets
+}
+
+// recordTransfer records the transfer as an event with the TransferToAgent
+// action on the invocation context carried by ctx, if any.
+func (a *RouterAgent) recordTransfer(ctx context.Context, from, to string) {
+	emitEvent(ctx, ActionRequested, func(event *events.Event) {
+		event.Author = from
+		event.Actions.TransferToAgent = to
+	})
+}
+
+// subAgent returns the direct sub-agent with the given name.
+func (a *RouterAgent) subAgent(name string) *Agent {
+	for _, sub := range a.subAgents {
+		if sub.Name() == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// SubAgents returns the sub-agents of this router agent.
+func (a *RouterAgent) SubAgents() []*Agent {
+	return a.subAgents
+}
+
+// transferKey is the context key for the transfer of the running agent.
+type transferKey struct{}
+
+// transfer records the target requested through transfer_to_agent by the
+// agent a RouterAgent is running.
+type transfer struct {
+	from    string
+	allowed map[string]bool
+
+	mu     sync.Mutex
+	target string
+}
+
+// attach installs t as the transfer handler of ctx.
+func (t *transfer) attach(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, transferKey{}, t)
+	return tools.WithTransferHandler(ctx, t.request)
+}
+
+// request validates and records a transfer.
+func (t *transfer) request(agentName string) error {
+	if !t.allowed[agentName] {
+		names := make([]string, 0, len(t.allowed))
+		for name := range t.allowed {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("cannot transfer from %s to %q, valid agents: %s", t.from, agentName, strings.Join(names, ", "))
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.target = agentName
+	return nil
+}
+
+// requested returns the requested target, empty if none.
+func (t *transfer) requested() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.target
+}
+
+// transferRequested reports whether the agent running ctx has requested a
+// transfer, in which case the tool loop stops without another model call.
+func transferRequested(ctx context.Context) bool {
+	t, ok := ctx.Value(transferKey{}).(*transfer)
+	return ok && t.requested() != ""
+}
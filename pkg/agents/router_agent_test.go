@@ -0,0 +1,76 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+	"github.com/nvcnvn/adk-golang/pkg/types"
+)
+
+// specialist 返回固定前缀；bounce 为 true 时将请求转回路由。
+func specialist(name, router string, bounce bool) *Agent {
+	return NewAgent(WithName(name), WithDescription(name+" specialist"), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		if bounce {
+			res, _ := tools.TransferToAgentTool.Execute(ctx, map[string]interface{}{"agent_name": router})
+			if res["success"] != true {
+				return "", errors.New("transfer back failed")
+			}
+			return "not mine", nil
+		}
+		return name + ": " + message, nil
+	}))
+}
+
+func TestRouterAgent(t *testing.T) {
+	model := registerScripted(t, "scripted-router",
+		`{"tool_name": "transfer_to_agent", "parameters": {"agent_name": "unknown"}}`,
+		`{"tool_name": "transfer_to_agent", "parameters": {"agent_name": "plot"}}`,
+	)
+	router := NewRouterAgent(RouterAgentConfig{
+		Name:      "novel",
+		Model:     "scripted-router",
+		SubAgents: []*Agent{specialist("worldview", "novel", false), specialist("plot", "novel", false)},
+	})
+
+	ic := NewInvocationContext("inv-router", router, &types.RunConfig{MaxLlmCalls: 10})
+	out, err := router.Process(WithInvocationContext(context.Background(), ic), "下一章写什么")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "plot: 下一章写什么" {
+		t.Errorf("output = %q", out)
+	}
+	// 未知目标作为工具错误返回给模型，转交成功后不再调用模型
+	if len(model.received) != 2 || !strings.Contains(model.received[1][len(model.received[1])-1].Content, "valid agents: plot, worldview") {
+		t.Errorf("unexpected model calls: %d", len(model.received))
+	}
+	if !strings.Contains(model.received[0][0].Content, "- worldview: worldview specialist") {
+		t.Errorf("sub-agents not described: %q", model.received[0][0].Content)
+	}
+	var transferred []string
+	for _, ev := range ic.EventsSnapshot() {
+		if ev.Actions != nil && ev.Actions.TransferToAgent != "" {
+			transferred = append(transferred, ev.Actions.TransferToAgent)
+		}
+	}
+	if strings.Join(transferred, ",") != "plot" {
+		t.Errorf("transfer events = %v", transferred)
+	}
+	if router.Kind() != AgentKindRouter {
+		t.Errorf("kind = %q", router.Kind())
+	}
+
+	// 子 Agent 转回路由后路由再次选择同一 Agent，判定为转交循环
+	registerScripted(t, "scripted-router-loop", `{"tool_name": "transfer_to_agent", "parameters": {"agent_name": "character"}}`)
+	looping := NewRouterAgent(RouterAgentConfig{
+		Name:      "looping",
+		Model:     "scripted-router-loop",
+		SubAgents: []*Agent{specialist("character", "looping", true)},
+	})
+	if _, err := looping.Process(context.Background(), "x"); !errors.Is(err, ErrTransferLoop) {
+		t.Errorf("err = %v, want ErrTransferLoop", err)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+)
+
+// ActionRequested is the type of the events that carry a transfer or an
+// escalation in their Actions.
+const ActionRequested events.EventType = "action_requested"
+
+// ErrorCodeAgent is the error code of the RunFailed event, see also
+// ErrorCodeApprovalPending.
+const ErrorCodeAgent = "agent_error"
+
+// frameKey is the context key for the frame of the running agent.
+type frameKey struct{}
+
+// frame identifies the running agent in the agent tree.
+type frame struct {
+	author string
+	// branch is the dot separated path of agent names from the root
+	branch string
+}
+
+// enter returns ctx with the frame of a. The branch of a is the branch of
+// the calling agent, or of the invocation, followed by its name; an agent
+// running on behalf of a parent with the same name, such as the decider of a
+// RouterAgent, keeps the parent branch.
+func (a *Agent) enter(ctx context.Context) context.Context {
+	branch := childBranch(currentFrame(ctx).branch, a.name)
+	return context.WithValue(ctx, frameKey{}, frame{author: a.name, branch: branch})
+}
+
+// childBranch returns the branch of the agent named name called from the
+// agent at parent, see enter.
+func childBranch(parent, name string) string {
+	switch {
+	case parent == name || strings.HasSuffix(parent, "."+name):
+		return parent
+	case parent != "":
+		return parent + "." + name
+	}
+	return name
+}
+
+// currentFrame returns the frame of the agent running ctx, falling back to the
+// agent and branch of the invocation context.
+func currentFrame(ctx context.Context) frame {
+	if f, ok := ctx.Value(frameKey{}).(frame); ok {
+		return f
+	}
+	var f frame
+	if ic := InvocationContextFromContext(ctx); ic != nil {
+		f.branch = ic.Branch
+		if ic.Agent != nil {
+			f.author = ic.Agent.Name()
+		}
+	}
+	return f
+}
+
+// emitEvent records an event of the given type on the invocation context
+// carried by ctx, if any, authored by the running agent. fill sets the fields
+// specific to the type. Every event except FinalResponse and RunFailed is
+// partial.
+func emitEvent(ctx context.Context, typ events.EventType, fill func(event *events.Event)) {
+	ic := InvocationContextFromContext(ctx)
+	if ic == nil {
+		return
+	}
+	f := currentFrame(ctx)
+	event := events.NewEvent()
+	event.Type = typ
+	event.InvocationID = ic.InvocationID
+	event.Author = f.author
+	event.Branch = f.branch
+	event.Partial = typ != events.FinalResponse && typ != events.RunFailed
+	if fill != nil {
+		fill(event)
+	}
+	ic.AddEvent(event)
+}
+
+// textContent wraps text as event content.
+func textContent(text, role string) *events.Content {
+	return &events.Content{Parts: []*models.Part{{Text: text, Role: role}}}
+}
+
+// modelCall starts a model call for the ModelCalled event: the returned
+// context collects the usage reported by the model and done records the event
+// with the latency and the error, if any.
+func modelCall(ctx context.Context, modelName string) (context.Context, func(err error)) {
+	var (
+		mu    sync.Mutex
+		usage *models.Usage
+		start = time.Now()
+	)
+	ctx = models.WithUsageReporter(ctx, func(u models.Usage) {
+		mu.Lock()
+		defer mu.Unlock()
+		usage = &u
+	})
+	return ctx, func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		emitEvent(ctx, events.ModelCalled, func(event *events.Event) {
+			event.ModelCall = &events.ModelCall{Model: modelName, Latency: time.Since(start), Usage: usage}
+			if err != nil {
+				event.ErrorMessage = err.Error()
+			}
+		})
+	}
+}
+
+// SetState sets a value in the state of the session attached to ctx, see
+// WithSession, and records a StateUpdated event with the delta. It returns
+// false when ctx carries no session.
+func SetState(ctx context.Context, key string, value interface{}) bool {
+	session := SessionFromContext(ctx)
+	if session == nil {
+		return false
+	}
+	session.SetState(key, value)
+	emitEvent(ctx, events.StateUpdated, func(event *events.Event) {
+		event.Actions.StateDelta[key] = value
+	})
+	return true
+}
+
+// invoke runs the agent as the root of an invocation and records the final
+// event, FinalResponse on success and RunFailed otherwise, after the events
+// of the run.
+func (a *Agent) invoke(ctx context.Context, ic *InvocationContext, message string) (*events.Event, error) {
+	ctx = WithInvocationContext(ctx, ic)
+	resp, err := a.Respond(ctx, message)
+
+	var final *events.Event
+	ctx = a.enter(ctx)
+	if err != nil {
+		emitEvent(ctx, events.RunFailed, func(event *events.Event) {
+			event.ErrorCode = ErrorCodeAgent
+			if errors.Is(err, ErrApprovalPending) {
+				event.ErrorCode = ErrorCodeApprovalPending
+			}
+			event.ErrorMessage = err.Error()
+			event.Content = textContent(fmt.Sprintf("Error processing message: %v", err), "model")
+			final = event
+		})
+		return final, err
+	}
+	emitEvent(ctx, events.FinalResponse, func(event *events.Event) {
+		event.Content = textContent(resp.Text, "model")
+		final = event
+	})
+	return final, nil
+}
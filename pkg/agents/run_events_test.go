@@ -0,0 +1,80 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+// usageModel 在回答的同时上报 token 用量。
+type usageModel struct {
+	scriptedModel
+}
+
+func (m *usageModel) Generate(ctx context.Context, messages []models.Message) (string, error) {
+	models.ReportUsage(ctx, &models.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15})
+	return m.scriptedModel.Generate(ctx, messages)
+}
+
+func TestRunEvents(t *testing.T) {
+	model := &usageModel{scriptedModel{name: "usage-model", replies: []string{"draft"}}}
+	models.GetRegistry().Register(model)
+	t.Cleanup(func() { models.GetRegistry().Unregister(model.name) })
+
+	writer := NewAgent(WithName("writer"), WithModel("usage-model"))
+	editor := NewAgent(WithName("editor"), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		SetState(ctx, "temp:edited", true)
+		return strings.ToUpper(message), nil
+	}))
+	pipeline := NewSequentialAgent(SequentialAgentConfig{Name: "pipeline", SubAgents: []*Agent{writer, editor}})
+
+	ic := NewInvocationContext("inv-tree", pipeline, nil)
+	ic.InvocationEvent = &events.Event{Content: &events.Content{Parts: []*models.Part{{Text: "go"}}}}
+	ctx := WithSession(context.Background(), &sessions.Session{State: sessions.NewState(nil, nil)})
+	ch, err := pipeline.Run(ctx, ic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	var final, modelCalled *events.Event
+	for ev := range ch {
+		got = append(got, fmt.Sprintf("%s@%s", ev.Type, ev.Branch))
+		switch ev.Type {
+		case events.FinalResponse:
+			final = ev
+		case events.ModelCalled:
+			modelCalled = ev
+		}
+	}
+
+	want := []string{
+		"agent_started@pipeline",
+		"agent_started@pipeline.writer",
+		"model_called@pipeline.writer",
+		"agent_finished@pipeline.writer",
+		"agent_started@pipeline.editor",
+		"state_updated@pipeline.editor",
+		"agent_finished@pipeline.editor",
+		"agent_finished@pipeline",
+		"final_response@pipeline",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if final == nil || final.Partial || final.Content.GetText() != "DRAFT" {
+		t.Errorf("final event = %+v", final)
+	}
+	if modelCalled == nil || modelCalled.ModelCall.Usage == nil || modelCalled.ModelCall.Usage.TotalTokens != 15 {
+		t.Errorf("model call = %+v", modelCalled)
+	}
+
+	// Process 走同一路径并返回最终事件的文本
+	if out, err := pipeline.Process(context.Background(), "go"); err != nil || out != "DRAFT" {
+		t.Errorf("Process() = %q, %v", out, err)
+	}
+}
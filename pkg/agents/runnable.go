@@ -0,0 +1,98 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+)
+
+// Agent kinds reported by Agent.Kind.
+const (
+	AgentKindBasic      = "basic"
+	AgentKindSequential = "sequential"
+	AgentKindParallel   = "parallel"
+	AgentKindLoop       = "loop"
+	AgentKindRouter     = "router"
+	AgentKindApproval   = "approval"
+	AgentKindGraph      = "graph"
+)
+
+// Runnable is implemented by every agent that can process a message: *Agent,
+// the composite agents and any user-defined type. Composite agents accept
+// Runnables as children, so composites nest without extra wiring.
+type Runnable interface {
+	// Name returns the name of the agent
+	Name() string
+
+	// Description returns the description of the agent
+	Description() string
+
+	// Process handles a message and returns the response
+	Process(ctx context.Context, message string) (string, error)
+}
+
+// agentNode is implemented by *Agent and, through embedding, by the composite
+// agents. It returns the node that represents the agent in the agent tree.
+type agentNode interface {
+	node() *Agent
+}
+
+// node returns the agent itself.
+func (a *Agent) node() *Agent {
+	return a
+}
+
+// AsAgent returns the *Agent that represents r in the agent tree.
+//
+// Composite agents return their embedded Agent, whose Process dispatches to the
+// composite orchestration, so the result can be nested anywhere an *Agent is
+// expected. Other Runnable types are wrapped in an Agent that delegates to
+// r.Process.
+func AsAgent(r Runnable) *Agent {
+	if r == nil {
+		return nil
+	}
+	if n, ok := r.(agentNode); ok {
+		return n.node()
+	}
+	return NewAgent(
+		WithName(r.Name()),
+		WithDescription(r.Description()),
+		WithProcessFunc(r.Process),
+	)
+}
+
+// collectSubAgents merges the *Agent and Runnable children of a composite
+// agent configuration, preserving order.
+func collectSubAgents(subAgents []*Agent, runnables []Runnable) []*Agent {
+	out := make([]*Agent, 0, len(subAgents)+len(runnables))
+	out = append(out, subAgents...)
+	for _, r := range runnables {
+		if a := AsAgent(r); a != nil {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Kind returns the agent kind: basic, sequential, parallel, loop, router,
+// approval or graph.
+func (a *Agent) Kind() string {
+	if a.kind == "" {
+		return AgentKindBasic
+	}
+	return a.kind
+}
@@ -0,0 +1,75 @@
+package agents
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// upper 是自定义 Runnable，不依赖 Agent。
+type upper struct{}
+
+func (upper) Name() string        { return "upper" }
+func (upper) Description() string { return "upper-case the message" }
+func (upper) Process(ctx context.Context, message string) (string, error) {
+	return strings.ToUpper(message), nil
+}
+
+func echo(name, suffix string) *Agent {
+	return NewAgent(WithName(name), WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+		return message + suffix, nil
+	}))
+}
+
+func TestCompositesNest(t *testing.T) {
+	inner := NewSequentialAgent(SequentialAgentConfig{
+		Name:      "inner",
+		SubAgents: []*Agent{echo("a", "-a"), echo("b", "-b")},
+	})
+	fanout := NewParallelAgent(ParallelAgentConfig{
+		Name:      "fanout",
+		SubAgents: []*Agent{echo("c", "-c"), echo("d", "-d")},
+	})
+	loop := NewLoopAgent(LoopAgentConfig{
+		Name:          "loop",
+		MaxIterations: 2,
+		SubAgents:     []*Agent{echo("e", "-e")},
+	})
+	root := NewSequentialAgent(SequentialAgentConfig{
+		Name:   "root",
+		Agents: []Runnable{inner, upper{}, loop},
+	})
+
+	// 通过内嵌 Agent 调用同样执行编排逻辑，而非叶子模型调用
+	out, err := root.Agent.Process(context.Background(), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "X-A-B-e-e" {
+		t.Errorf("root output = %q", out)
+	}
+
+	out, err = AsAgent(fanout).Process(context.Background(), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(out, "\n")
+	sort.Strings(lines)
+	if strings.Join(lines, ",") != "x-c,x-d" {
+		t.Errorf("fanout output = %q", out)
+	}
+
+	if got := root.Agent.Kind(); got != AgentKindSequential {
+		t.Errorf("kind = %q", got)
+	}
+	if AsAgent(upper{}).Kind() != AgentKindBasic || AsAgent(loop).Kind() != AgentKindLoop {
+		t.Error("unexpected kinds")
+	}
+	if inner.ParentAgent() != &root.Agent {
+		t.Error("nested composite should be adopted by root")
+	}
+	if root.FindAgent("e") == nil {
+		t.Error("FindAgent should reach agents inside nested composites")
+	}
+}
@@ -30,34 +30,54 @@ type SequentialAgentConfig struct {
 	Name        string
 	Description string
 	SubAgents   []*Agent
+
+	// Agents are appended after SubAgents and may be any Runnable,
+	// including other composite agents.
+	Agents []Runnable
 }
 
 // NewSequentialAgent creates a new agent that processes sub-agents in sequence.
+//
+// The orchestration is bound as the process function of the embedded Agent,
+// so Process, &agent.Agent and AsAgent(agent) all run the sub-agents in
+// sequence, with the before/after callbacks applied around them.
 func NewSequentialAgent(config SequentialAgentConfig) *SequentialAgent {
-	return &SequentialAgent{
+	subAgents := collectSubAgents(config.SubAgents, config.Agents)
+	agent := &SequentialAgent{
 		Agent: Agent{
 			name:        config.Name,
 			description: config.Description,
+			subAgents:   subAgents,
+			kind:        AgentKindSequential,
 		},
-		subAgents: config.SubAgents,
+		subAgents: subAgents,
 	}
+	agent.processFunc = agent.run
+	agent.adoptSubAgents()
+	return agent
 }
 
-// Process handles a message by passing it through each sub-agent in sequence.
-func (a *SequentialAgent) Process(ctx context.Context, message string) (string, error) {
+// run passes the message through each sub-agent in sequence.
+func (a *SequentialAgent) run(ctx context.Context, message string) (string, error) {
 	currentMessage := message
 	var response string
 	var err error
 
+	// A resumed run starts at the step that leads to the paused approval
+	start := 0
+	if r := approvalResumeFromContext(ctx); r != nil {
+		start, currentMessage = r.resumeStep(currentFrame(ctx).branch, a.subAgents, currentMessage)
+	}
+
 	// Process through each sub-agent in sequence
-	for _, subAgent := range a.subAgents {
+	for _, subAgent := range a.subAgents[start:] {
 		select {
 		case <-ctx.Done():
 			return "", ctx.Err()
 		default:
 		}
 
-		response, err = subAgent.Process(ctx, currentMessage)
+		response, err = runStep(ctx, subAgent, currentMessage)
 		if err != nil {
 			return "", err
 		}
@@ -0,0 +1,410 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agents provides the core agent types and functionality.
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+)
+
+// DefaultMaxLlmCalls bounds the tool loop when ctx carries no invocation
+// context or its RunConfig sets no MaxLlmCalls.
+const DefaultMaxLlmCalls = 10
+
+// ErrLlmCallsExceeded is returned when the model is still requesting tools
+// after the MaxLlmCalls limit has been reached.
+var ErrLlmCallsExceeded = errors.New("maximum number of LLM calls exceeded")
+
+// toolProtocol tells the model how to request tools and that results come back
+// as follow-up messages.
+const toolProtocol = "To call a tool, reply with one JSON object per line in the form " +
+	`{"tool_name": "<name>", "parameters": {...}}` + ". " +
+	"The tool results will be sent back to you; reply without any tool call once you have the final answer."
+
+// ToolMode selects how an agent offers its tools to the model.
+type ToolMode string
+
+const (
+	// ToolModeAuto uses native function calling when the model implements
+	// models.ToolCallingModel and the text protocol otherwise.
+	ToolModeAuto ToolMode = ""
+
+	// ToolModeText always describes the tools in the system prompt and parses
+	// tool calls from the reply text.
+	ToolModeText ToolMode = "text"
+)
+
+// toolCall is a tool request parsed from the model output.
+type toolCall struct {
+	ToolName   string                 `json:"tool_name"`
+	Parameters map[string]interface{} `json:"parameters"`
+
+	// id links the call and response events
+	id string
+	// argErr is set when native call arguments are not a JSON object
+	argErr error
+}
+
+// llmCallBudget counts model calls against MaxLlmCalls. With an invocation
+// context that sets MaxLlmCalls the count is shared by every agent in the run,
+// otherwise DefaultMaxLlmCalls applies to each tool loop.
+type llmCallBudget struct {
+	ic    *InvocationContext
+	count int
+}
+
+// take records one model call and fails once the limit is exceeded.
+func (b *llmCallBudget) take() error {
+	if b.ic != nil && b.ic.RunConfig != nil && b.ic.RunConfig.MaxLlmCalls > 0 {
+		if err := b.ic.IncrementLlmCallCount(); err != nil {
+			return fmt.Errorf("%w (%d)", ErrLlmCallsExceeded, b.ic.RunConfig.MaxLlmCalls)
+		}
+		return nil
+	}
+	b.count++
+	if b.count > DefaultMaxLlmCalls {
+		return fmt.Errorf("%w (%d)", ErrLlmCallsExceeded, DefaultMaxLlmCalls)
+	}
+	return nil
+}
+
+// toolLoopState is shared by the tool loops of one response, across the
+// fallback models: the model calls of all of them count against one budget and
+// tool calls that already ran are not run again.
+type toolLoopState struct {
+	budget *llmCallBudget
+	// results holds the results of the executed tool calls by toolCallKey, in
+	// execution order
+	results map[string][]string
+	// seen counts the calls per key made by the current model
+	seen map[string]int
+}
+
+// newToolLoopState returns the state for the tool loops of one response.
+func newToolLoopState(ctx context.Context) *toolLoopState {
+	return &toolLoopState{
+		budget:  &llmCallBudget{ic: InvocationContextFromContext(ctx)},
+		results: make(map[string][]string),
+	}
+}
+
+// nextModel starts the tool loop of the next model.
+func (s *toolLoopState) nextModel() {
+	s.seen = make(map[string]int)
+}
+
+// execute runs call, or returns the result of the same call made by a
+// previous model: the n-th identical call of a model reuses the n-th
+// execution, so a fallback model does not repeat side effects.
+func (s *toolLoopState) execute(ctx context.Context, a *Agent, call toolCall) string {
+	key := toolCallKey(call)
+	n := s.seen[key]
+	s.seen[key]++
+	if n < len(s.results[key]) {
+		log.Printf("[Agent] 工具 %s 已由之前的模型执行，agent: %s, 复用结果", call.ToolName, a.name)
+		return s.results[key][n]
+	}
+	result := a.executeToolCall(ctx, call)
+	s.results[key] = append(s.results[key], result)
+	return result
+}
+
+// toolCallKey identifies a tool call by the tool and its arguments.
+func toolCallKey(call toolCall) string {
+	args, _ := json.Marshal(call.Parameters)
+	return call.ToolName + "\x00" + string(args)
+}
+
+// runToolLoop calls the model, executes the tools it requests, sends the
+// results back as messages and repeats until the model answers without tool
+// calls. Tools are sent natively when the model supports function calling,
+// otherwise calls are parsed from the reply text. Every model call, tool call
+// and tool result is recorded as a partial event on the invocation context
+// carried by ctx, if any; the answer is recorded by Respond after the
+// callbacks. state carries the call budget and the executed tool calls across
+// the fallback models.
+func (a *Agent) runToolLoop(ctx context.Context, model models.Model, msgs []models.Message, state *toolLoopState) (string, error) {
+	state.nextModel()
+	budget := state.budget
+	toolModel, native := a.toolCallingModel(model)
+
+	for step := 1; ; step++ {
+		// 检查上下文是否已取消，避免不必要的模型调用
+		select {
+		case <-ctx.Done():
+			log.Printf("[Agent] 模型调用被上下文取消，agent: %s, 原因: %v", a.name, ctx.Err())
+			return "", ctx.Err()
+		default:
+		}
+
+		log.Printf("[Agent] 开始模型调用，agent: %s, 模型: %s, 第 %d 轮, 原生工具: %v", a.name, a.model, step, native)
+		var (
+			response  string
+			text      string
+			calls     []toolCall
+			assistant models.Message
+			err       error
+		)
+		if native {
+			var resp *models.ToolResponse
+			err = a.withRetry(ctx, budget, func() (callErr error) {
+				callCtx, done := modelCall(ctx, model.Name())
+				resp, callErr = toolModel.GenerateWithTools(callCtx, msgs, a.modelTools())
+				done(callErr)
+				return callErr
+			})
+			if err == nil {
+				response, text = resp.Content, resp.Content
+				calls = nativeToolCalls(resp.ToolCalls)
+				assistant = models.Message{Role: "assistant", Content: resp.Content, ToolCalls: resp.ToolCalls}
+			}
+		} else {
+			err = a.withRetry(ctx, budget, func() (callErr error) {
+				callCtx, done := modelCall(ctx, model.Name())
+				response, callErr = model.Generate(callCtx, msgs)
+				done(callErr)
+				return callErr
+			})
+			text = response
+			if err == nil && len(a.tools) > 0 {
+				calls, text = parseToolCalls(response)
+				for i := range calls {
+					calls[i].id = events.GenerateID()
+				}
+			}
+			assistant = models.Message{Role: "assistant", Content: response}
+		}
+		log.Printf("[Agent] 模型调用完成，agent: %s, 成功: %v", a.name, err == nil)
+		if err != nil {
+			return "", err
+		}
+		if len(calls) == 0 {
+			return response, nil
+		}
+		recordToolCalls(ctx, text, calls)
+
+		msgs = append(msgs, assistant)
+		for _, call := range calls {
+			result := state.execute(ctx, a, call)
+			recordToolResult(ctx, call, result)
+			if native {
+				msgs = append(msgs, models.Message{
+					Role:       "tool",
+					Content:    result,
+					Attrs:      map[string]string{"tool_name": call.ToolName},
+					ToolCallID: call.id,
+				})
+				continue
+			}
+			msgs = append(msgs, models.Message{
+				Role:    "user",
+				Content: fmt.Sprintf("工具 '%s' 的执行结果:\n%s", call.ToolName, result),
+				Attrs:   map[string]string{"tool_name": call.ToolName},
+			})
+		}
+
+		// 已请求转交时由 RouterAgent 接管，不再调用模型
+		if transferRequested(ctx) {
+			return text, nil
+		}
+	}
+}
+
+// toolCallingModel returns the model as a models.ToolCallingModel when the
+// agent has tools, the tool mode allows it and the model supports native
+// function calling.
+func (a *Agent) toolCallingModel(model models.Model) (models.ToolCallingModel, bool) {
+	if len(a.tools) == 0 || a.toolMode == ToolModeText || !models.SupportsToolCalling(model) {
+		return nil, false
+	}
+	tm, ok := model.(models.ToolCallingModel)
+	return tm, ok
+}
+
+// nativeToolCalls converts native function calls to tool calls. The call IDs
+// from the model are kept so that the results can reference them; calls
+// without one get a generated ID, and the function call is updated to match.
+func nativeToolCalls(fcs []*models.FunctionCall) []toolCall {
+	calls := make([]toolCall, 0, len(fcs))
+	for _, fc := range fcs {
+		if fc.ID == "" {
+			fc.ID = events.GenerateID()
+		}
+		call := toolCall{ToolName: fc.Name, id: fc.ID}
+		if strings.TrimSpace(fc.Arguments) != "" {
+			if err := json.Unmarshal([]byte(fc.Arguments), &call.Parameters); err != nil {
+				call.argErr = err
+			}
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// modelTools describes the agent tools for native function calling.
+func (a *Agent) modelTools() []models.Tool {
+	defs := make([]models.Tool, 0, len(a.tools))
+	for _, tool := range a.tools {
+		defs = append(defs, models.Tool{
+			Name:        tool.Name(),
+			Description: tool.Description(),
+			InputSchema: jsonSchema(tool.Schema().Input),
+		})
+	}
+	return defs
+}
+
+// jsonSchema converts a tool parameter schema to a JSON Schema object. The
+// per-property Required flags become the required list of the parent.
+func jsonSchema(p tools.ParameterSchema) map[string]interface{} {
+	schema := map[string]interface{}{}
+	typ := p.Type
+	if typ == "" {
+		typ = "object"
+	}
+	schema["type"] = typ
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	if typ != "object" {
+		return schema
+	}
+
+	props := map[string]interface{}{}
+	var required []string
+	for name, prop := range p.Properties {
+		child := jsonSchema(prop)
+		if prop.Type == "" && len(prop.Properties) == 0 {
+			child["type"] = "string"
+		}
+		props[name] = child
+		if prop.Required {
+			required = append(required, name)
+		}
+	}
+	schema["properties"] = props
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
+
+// parseToolCalls splits a model response into tool calls (one JSON object per
+// line) and the remaining text.
+func parseToolCalls(response string) ([]toolCall, string) {
+	var calls []toolCall
+	var text []string
+	for _, line := range strings.Split(strings.TrimSpace(response), "\n") {
+		trimmed := strings.TrimSpace(line)
+		var call toolCall
+		if strings.HasPrefix(trimmed, "{") && json.Unmarshal([]byte(trimmed), &call) == nil && call.ToolName != "" {
+			calls = append(calls, call)
+			continue
+		}
+		text = append(text, line)
+	}
+	return calls, strings.TrimSpace(strings.Join(text, "\n"))
+}
+
+// executeToolCall runs a single tool call and formats the result, or the
+// failure, as text for the model.
+func (a *Agent) executeToolCall(ctx context.Context, call toolCall) string {
+	return executeTool(ctx, a.name, a.tools, call)
+}
+
+// executeTool runs call with the matching tool of toolset on behalf of the
+// agent named agent, see Agent.executeToolCall.
+func executeTool(ctx context.Context, agent string, toolset []tools.Tool, call toolCall) string {
+	log.Printf("[Agent] 检测到工具调用: %s", call.ToolName)
+	if call.argErr != nil {
+		return fmt.Sprintf("错误：工具 '%s' 的参数不是有效的 JSON 对象: %v", call.ToolName, call.argErr)
+	}
+
+	var target tools.Tool
+	for _, tool := range toolset {
+		if tool.Name() == call.ToolName {
+			target = tool
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Sprintf("错误：未找到工具 '%s'", call.ToolName)
+	}
+
+	events.Publish(events.ToolCalled, map[string]interface{}{
+		"agent": agent,
+		"tool":  call.ToolName,
+	})
+	result, err := target.Execute(ctx, call.Parameters)
+	if err != nil {
+		events.Publish(events.ToolError, map[string]interface{}{
+			"agent": agent,
+			"tool":  call.ToolName,
+			"error": err.Error(),
+		})
+		return fmt.Sprintf("工具 '%s' 执行失败: %v", call.ToolName, err)
+	}
+	events.Publish(events.ToolResultReceived, map[string]interface{}{
+		"agent":  agent,
+		"tool":   call.ToolName,
+		"result": result,
+	})
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return string(resultJSON)
+}
+
+// recordToolCalls records the text of a model step that requested tools as a
+// PartialText event, followed by a ToolCalled event with the calls.
+func recordToolCalls(ctx context.Context, text string, calls []toolCall) {
+	if text != "" {
+		emitEvent(ctx, events.PartialText, func(event *events.Event) {
+			event.Content = textContent(text, "model")
+		})
+	}
+	parts := make([]*models.Part, 0, len(calls))
+	for _, call := range calls {
+		args, _ := json.Marshal(call.Parameters)
+		parts = append(parts, &models.Part{
+			Role:         "model",
+			FunctionCall: &models.FunctionCall{Name: call.ToolName, Arguments: string(args), ID: call.id},
+		})
+	}
+	emitEvent(ctx, events.ToolCalled, func(event *events.Event) {
+		event.Content = &events.Content{Parts: parts}
+	})
+}
+
+// recordToolResult records a tool result as a ToolResultReceived event with a
+// function response.
+func recordToolResult(ctx context.Context, call toolCall, result string) {
+	emitEvent(ctx, events.ToolResultReceived, func(event *events.Event) {
+		event.Content = &events.Content{Parts: []*models.Part{{
+			Role:             "user",
+			FunctionResponse: &models.FunctionResponse{Name: call.ToolName, Content: result, ID: call.id},
+		}}}
+	})
+}
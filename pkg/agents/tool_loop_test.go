@@ -0,0 +1,201 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/tools"
+	"github.com/nvcnvn/adk-golang/pkg/types"
+)
+
+// scriptedModel 依次返回预设回复，并记录每次收到的消息。
+type scriptedModel struct {
+	name     string
+	mu       sync.Mutex
+	replies  []string
+	received [][]models.Message
+}
+
+func (m *scriptedModel) Name() string { return m.name }
+
+func (m *scriptedModel) GenerateStream(ctx context.Context, messages []models.Message) (chan models.StreamedResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *scriptedModel) Generate(ctx context.Context, messages []models.Message) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received = append(m.received, messages)
+	reply := m.replies[0]
+	if len(m.replies) > 1 {
+		m.replies = m.replies[1:]
+	}
+	return reply, nil
+}
+
+func registerScripted(t *testing.T, name string, replies ...string) *scriptedModel {
+	m := &scriptedModel{name: name, replies: replies}
+	models.GetRegistry().Register(m)
+	t.Cleanup(func() { models.GetRegistry().Unregister(name) })
+	return m
+}
+
+func addTool() tools.Tool {
+	return tools.NewTool("add", "add two numbers", tools.ToolSchema{},
+		func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"sum": input["a"].(float64) + input["b"].(float64)}, nil
+		})
+}
+
+func TestToolLoop(t *testing.T) {
+	model := registerScripted(t, "scripted-tool-loop",
+		`{"tool_name": "add", "parameters": {"a": 1, "b": 2}}`,
+		"The answer is 3.",
+	)
+	agent := NewAgent(WithName("calc"), WithModel("scripted-tool-loop"), WithTools(addTool()))
+
+	ic := NewInvocationContext("inv-1", agent, &types.RunConfig{MaxLlmCalls: 5})
+	out, err := agent.Process(WithInvocationContext(context.Background(), ic), "1+2?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "The answer is 3." {
+		t.Errorf("output = %q", out)
+	}
+
+	// 第二次调用时模型能看到工具结果
+	if len(model.received) != 2 {
+		t.Fatalf("model called %d times, want 2", len(model.received))
+	}
+	last := model.received[1][len(model.received[1])-1]
+	if !strings.Contains(last.Content, `"sum": 3`) {
+		t.Errorf("tool result not sent back to model: %q", last.Content)
+	}
+
+	// 运行过程按顺序记录为事件，工具调用与结果通过 ID 关联
+	evs := ic.EventsSnapshot()
+	var types []string
+	for _, ev := range evs {
+		types = append(types, string(ev.Type))
+		if ev.InvocationID != "inv-1" || ev.Author != "calc" || ev.Branch != "calc" {
+			t.Errorf("event %s: invocation %q, author %q, branch %q", ev.Type, ev.InvocationID, ev.Author, ev.Branch)
+		}
+	}
+	want := "agent_started,model_called,tool_called,tool_result_received,model_called,agent_finished"
+	if strings.Join(types, ",") != want {
+		t.Fatalf("event types = %v, want %s", types, want)
+	}
+	call := evs[2].GetFunctionCalls()
+	resp := evs[3].Content.Parts[0].FunctionResponse
+	if len(call) != 1 || call[0].Name != "add" || resp == nil || resp.ID != call[0].ID {
+		t.Errorf("unexpected events: %+v %+v", evs[2].Content.Parts, evs[3].Content.Parts)
+	}
+	if mc := evs[1].ModelCall; mc == nil || mc.Model != "scripted-tool-loop" || mc.Latency <= 0 {
+		t.Errorf("model call = %+v", mc)
+	}
+	if ic.GetLlmCallCount() != 2 {
+		t.Errorf("llm calls = %d", ic.GetLlmCallCount())
+	}
+}
+
+func TestToolLoopMaxLlmCalls(t *testing.T) {
+	registerScripted(t, "scripted-tool-forever", `{"tool_name": "add", "parameters": {"a": 1, "b": 1}}`)
+	agent := NewAgent(WithName("looper"), WithModel("scripted-tool-forever"), WithTools(addTool()))
+
+	ic := NewInvocationContext("inv-2", agent, &types.RunConfig{MaxLlmCalls: 3})
+	ic.InvocationEvent = &events.Event{Content: &events.Content{Parts: []*models.Part{{Text: "go"}}}}
+	ch, err := agent.Run(context.Background(), ic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []*events.Event
+	for ev := range ch {
+		got = append(got, ev)
+	}
+	// 开始事件、3 轮各自的模型调用、工具调用与结果事件、结束事件，最后是失败事件
+	if len(got) != 12 {
+		t.Fatalf("got %d events, want 12", len(got))
+	}
+	last := got[len(got)-1]
+	if last.Type != events.RunFailed || !last.IsFinalResponse() || !strings.Contains(last.Content.GetText(), ErrLlmCallsExceeded.Error()) {
+		t.Errorf("last event = %+v", last)
+	}
+
+	_, err = agent.Process(context.Background(), "go")
+	if !errors.Is(err, ErrLlmCallsExceeded) {
+		t.Errorf("err = %v, want ErrLlmCallsExceeded", err)
+	}
+}
+
+// nativeModel 通过原生函数调用返回预设的工具调用。
+type nativeModel struct {
+	scriptedModel
+	calls [][]*models.FunctionCall
+	tools []models.Tool
+}
+
+func (m *nativeModel) GenerateWithTools(ctx context.Context, messages []models.Message, defs []models.Tool) (*models.ToolResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received = append(m.received, messages)
+	m.tools = defs
+	if len(m.calls) > 0 {
+		calls := m.calls[0]
+		m.calls = m.calls[1:]
+		return &models.ToolResponse{ToolCalls: calls}, nil
+	}
+	return &models.ToolResponse{Content: m.replies[0]}, nil
+}
+
+func TestToolLoopNative(t *testing.T) {
+	model := &nativeModel{
+		scriptedModel: scriptedModel{name: "native-tool-loop", replies: []string{"3 and 7"}},
+		calls: [][]*models.FunctionCall{{
+			{Name: "add", Arguments: `{"a": 1, "b": 2}`, ID: "call_1"},
+			{Name: "add", Arguments: `{"a": 3, "b": 4}`, ID: "call_2"},
+		}},
+	}
+	models.GetRegistry().Register(model)
+	t.Cleanup(func() { models.GetRegistry().Unregister(model.name) })
+
+	agent := NewAgent(WithName("calc"), WithModel(model.name), WithInstruction("be brief"), WithTools(addTool()))
+	out, err := agent.Process(context.Background(), "1+2? 3+4?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "3 and 7" {
+		t.Errorf("output = %q", out)
+	}
+
+	// 原生模式下系统提示不包含文本协议，工具通过 tools 传递
+	if sys := model.received[0][0].Content; sys != "be brief" {
+		t.Errorf("system prompt = %q", sys)
+	}
+	if len(model.tools) != 1 || model.tools[0].Name != "add" {
+		t.Errorf("tools = %+v", model.tools)
+	}
+
+	// 并行调用的结果以 tool 消息按调用 ID 发回
+	msgs := model.received[1]
+	if len(msgs) != 5 || len(msgs[2].ToolCalls) != 2 {
+		t.Fatalf("unexpected history: %+v", msgs)
+	}
+	if msgs[3].Role != "tool" || msgs[3].ToolCallID != "call_1" || !strings.Contains(msgs[4].Content, `"sum": 7`) {
+		t.Errorf("tool results = %+v %+v", msgs[3], msgs[4])
+	}
+
+	// ToolModeText 强制使用文本协议
+	model.calls = nil
+	text := NewAgent(WithName("calc_text"), WithModel(model.name), WithTools(addTool()), WithToolMode(ToolModeText))
+	if _, err := text.Process(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if last := model.received[len(model.received)-1]; !strings.Contains(last[0].Content, "tool_name") {
+		t.Errorf("text protocol not in system prompt: %q", last[0].Content)
+	}
+}
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+// 审批记录状态
+const (
+	ApprovalStatusPending  = "pending"  // 等待人工决定
+	ApprovalStatusApproved = "approved" // 已通过（含修改或输入），运行已恢复
+	ApprovalStatusRejected = "rejected" // 已拒绝，运行不再恢复
+)
+
+// ErrApprovalNotFound 表示审批记录不存在。
+// ErrApprovalResolved 表示审批记录已有决定，不能再次提交。
+var (
+	ErrApprovalNotFound = errors.New("审批记录未找到") // 审批记录不存在
+	ErrApprovalResolved = errors.New("审批已处理")   // 审批记录已有决定
+)
+
+// 审批索引会话：记录每个审批所在的运行会话，服务重启后据此从会话状态恢复审批记录
+const (
+	approvalIndexApp     = "adk_approvals"
+	approvalIndexUser    = "system"
+	approvalIndexSession = "index"
+)
+
+// approvalStatePrefix 为审批记录在会话状态中的键前缀
+const approvalStatePrefix = "approval."
+
+// approvalStateKey 返回审批记录在会话状态中的键
+func approvalStateKey(id string) string {
+	return approvalStatePrefix + id
+}
+
+// approvalLocation 为审批索引中的条目：审批记录所在的运行会话
+type approvalLocation struct {
+	Workflow  string `json:"workflow"`
+	UserId    string `json:"user_id"`
+	SessionId string `json:"session_id"`
+}
+
+// ApprovalRecord 待审批记录：在审批步骤暂停的运行，以及恢复运行所需的请求与各步骤输入。
+// 记录保存在会话状态中（会话 ID 为运行的 trace_id），请求审批与人工决定分别作为
+// request_approval 函数调用 / 函数响应事件追加到会话。
+type ApprovalRecord struct {
+	agents.PendingApproval
+
+	Status     string                   `json:"status"`                // pending / approved / rejected
+	Workflow   string                   `json:"workflow"`              // 工作流名称
+	Version    string                   `json:"version,omitempty"`     // 暂停时服务的版本，恢复时固定该版本
+	EntryAgent string                   `json:"entry_agent,omitempty"` // 请求指定的子 Agent
+	Input      string                   `json:"input"`                 // 运行的原始输入
+	UserId     string                   `json:"user_id"`               // 用户标识
+	ArchiveId  string                   `json:"archive_id"`            // 归档标识符
+	TraceId    string                   `json:"trace_id"`              // 运行的追踪ID
+	SessionId  string                   `json:"session_id"`            // 保存运行的会话
+	Parameters map[string]interface{}   `json:"parameters,omitempty"`  // 请求参数
+	StepInputs map[string]string        `json:"step_inputs,omitempty"` // 各步骤（按分支）的输入
+	Decision   *agents.ApprovalDecision `json:"decision,omitempty"`    // 人工决定
+	CreatedAt  time.Time                `json:"created_at"`
+	DecidedAt  *time.Time               `json:"decided_at,omitempty"`
+}
+
+// resume 返回从该记录恢复运行的 ApprovalResume
+func (r *ApprovalRecord) resume() *agents.ApprovalResume {
+	resume := &agents.ApprovalResume{
+		Approval: r.PendingApproval,
+		Inputs:   r.StepInputs,
+	}
+	if r.Decision != nil {
+		resume.Decision = *r.Decision
+	}
+	return resume
+}
+
+// pause 保存在审批步骤暂停的运行：创建（或沿用恢复前的）会话，追加请求审批事件并记录待审批记录
+func (s *WorkflowService) pause(ctx context.Context, req WorkflowRequest, version string, ic *agents.InvocationContext, approval *agents.PendingApproval, resumed *ApprovalRecord) (*ApprovalRecord, error) {
+	record := &ApprovalRecord{
+		PendingApproval: *approval,
+		Status:          ApprovalStatusPending,
+		Workflow:        req.Workflow,
+		Version:         version,
+		EntryAgent:      req.Agent,
+		Input:           req.Input,
+		UserId:          req.UserId,
+		ArchiveId:       req.ArchiveId,
+		TraceId:         req.TraceId,
+		SessionId:       req.TraceId,
+		Parameters:      req.Parameters,
+		StepInputs:      make(map[string]string),
+		CreatedAt:       time.Now(),
+	}
+	if resumed != nil {
+		record.SessionId = resumed.SessionId
+		for branch, input := range resumed.StepInputs {
+			record.StepInputs[branch] = input
+		}
+	}
+	for branch, input := range agents.RecordedInputs(ic.EventsSnapshot()) {
+		record.StepInputs[branch] = input
+	}
+
+	session, err := s.sessions.GetSession(ctx, req.Workflow, req.UserId, record.SessionId, nil)
+	if err != nil || session == nil {
+		if session, err = s.sessions.CreateSession(ctx, req.Workflow, req.UserId, nil, record.SessionId); err != nil {
+			return nil, err
+		}
+	}
+
+	args, _ := json.Marshal(approval)
+	event := events.NewEvent()
+	event.Type = agents.ApprovalRequested
+	event.InvocationID = req.TraceId
+	event.Author = approval.Agent
+	event.Branch = approval.Branch
+	event.LongRunningToolIDs = []string{approval.ID}
+	event.Content = &events.Content{Parts: []*models.Part{{
+		Role:         "model",
+		FunctionCall: &models.FunctionCall{Name: agents.RequestApprovalFunction, Arguments: string(args), ID: approval.ID},
+	}}}
+	event.Actions.StateDelta[approvalStateKey(approval.ID)] = record
+	if _, err := s.sessions.AppendEvent(ctx, session, event); err != nil {
+		return nil, err
+	}
+	if err := s.indexApproval(ctx, record); err != nil {
+		return nil, err
+	}
+
+	s.approvals.Store(approval.ID, record)
+	log.Printf("[API] 工作流 %s@%s 在 %s 等待人工审批，审批ID: %s，TraceID: %s", req.Workflow, version, approval.Branch, approval.ID, req.TraceId)
+	return record, nil
+}
+
+// indexApproval 在审批索引会话中记录审批所在的运行会话
+func (s *WorkflowService) indexApproval(ctx context.Context, record *ApprovalRecord) error {
+	// 并发暂停的运行不能重复创建索引会话
+	s.approvalMu.Lock()
+	defer s.approvalMu.Unlock()
+
+	index, err := s.sessions.GetSession(ctx, approvalIndexApp, approvalIndexUser, approvalIndexSession, nil)
+	if err != nil || index == nil {
+		if index, err = s.sessions.CreateSession(ctx, approvalIndexApp, approvalIndexUser, nil, approvalIndexSession); err != nil {
+			return err
+		}
+	}
+	event := events.NewEvent()
+	event.InvocationID = record.TraceId
+	event.Author = approvalIndexUser
+	event.Actions.StateDelta[approvalStateKey(record.ID)] = approvalLocation{
+		Workflow:  record.Workflow,
+		UserId:    record.UserId,
+		SessionId: record.SessionId,
+	}
+	_, err = s.sessions.AppendEvent(ctx, index, event)
+	return err
+}
+
+// loadApproval 从运行会话的状态（approval.<id>）恢复不在内存中的审批记录
+func (s *WorkflowService) loadApproval(ctx context.Context, id string) (*ApprovalRecord, bool) {
+	index, err := s.sessions.GetSession(ctx, approvalIndexApp, approvalIndexUser, approvalIndexSession, nil)
+	if err != nil || index == nil {
+		return nil, false
+	}
+	var loc approvalLocation
+	if !decodeSessionState(index, approvalStateKey(id), &loc) {
+		return nil, false
+	}
+	session, err := s.sessions.GetSession(ctx, loc.Workflow, loc.UserId, loc.SessionId, nil)
+	if err != nil || session == nil {
+		return nil, false
+	}
+	record := &ApprovalRecord{}
+	if !decodeSessionState(session, approvalStateKey(id), record) {
+		return nil, false
+	}
+	actual, _ := s.approvals.LoadOrStore(id, record)
+	return actual.(*ApprovalRecord), true
+}
+
+// restoreApprovals 恢复审批索引中全部不在内存中的审批记录
+func (s *WorkflowService) restoreApprovals(ctx context.Context) {
+	index, err := s.sessions.GetSession(ctx, approvalIndexApp, approvalIndexUser, approvalIndexSession, nil)
+	if err != nil || index == nil || index.State == nil {
+		return
+	}
+	for key := range index.State.ToMap() {
+		id, ok := strings.CutPrefix(key, approvalStatePrefix)
+		if !ok {
+			continue
+		}
+		if _, loaded := s.approvals.Load(id); !loaded {
+			s.loadApproval(ctx, id)
+		}
+	}
+}
+
+// decodeSessionState 将会话状态中 key 的值解码到 out；持久化的会话中值为 JSON 解码后的 map
+func decodeSessionState(session *sessions.Session, key string, out interface{}) bool {
+	if session.State == nil {
+		return false
+	}
+	v, ok := session.State.Get(key)
+	if !ok {
+		return false
+	}
+	raw, err := json.Marshal(v)
+	return err == nil && json.Unmarshal(raw, out) == nil
+}
+
+// ListApprovals 列出审批记录，按创建时间排序；status / workflow / userID 为空时不过滤。
+// 不在内存中的记录（如服务重启前暂停的运行）从会话状态恢复。
+func (s *WorkflowService) ListApprovals(status, workflow, userID string) []*ApprovalRecord {
+	s.restoreApprovals(context.Background())
+	var records []*ApprovalRecord
+	s.approvals.Range(func(_, v interface{}) bool {
+		r := v.(*ApprovalRecord)
+		if (status == "" || r.Status == status) && (workflow == "" || r.Workflow == workflow) && (userID == "" || r.UserId == userID) {
+			records = append(records, r)
+		}
+		return true
+	})
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return records
+}
+
+// GetApproval 获取审批记录，不在内存中时从会话状态恢复
+func (s *WorkflowService) GetApproval(id string) (*ApprovalRecord, error) {
+	if v, ok := s.approvals.Load(id); ok {
+		return v.(*ApprovalRecord), nil
+	}
+	if record, ok := s.loadApproval(context.Background(), id); ok {
+		return record, nil
+	}
+	return nil, ErrApprovalNotFound
+}
+
+// ResolveApproval 记录人工决定并恢复运行。通过（可附修改后的内容）或输入步骤从审批步骤继续执行，
+// 已完成的步骤不再执行；拒绝时不恢复运行，返回 Success 为 false 的响应。
+// 恢复后的运行可能在下一个审批步骤再次暂停，此时返回 ErrApprovalPending。
+func (s *WorkflowService) ResolveApproval(ctx context.Context, id string, decision agents.ApprovalDecision) (*WorkflowResponse, error) {
+	s.approvalMu.Lock()
+	record, err := s.GetApproval(id)
+	if err != nil {
+		s.approvalMu.Unlock()
+		return nil, err
+	}
+	if record.Status != ApprovalStatusPending {
+		s.approvalMu.Unlock()
+		return nil, ErrApprovalResolved
+	}
+	if record.Kind == agents.ApprovalKindInput {
+		if strings.TrimSpace(decision.Content) == "" {
+			s.approvalMu.Unlock()
+			return nil, ErrInvalidRequest
+		}
+		decision.Approved = true
+	}
+
+	// 记录副本，运行中的读取方看到的记录保持不变
+	decided := *record
+	now := time.Now()
+	decided.Decision = &decision
+	decided.DecidedAt = &now
+	decided.Status = ApprovalStatusApproved
+	if !decision.Approved {
+		decided.Status = ApprovalStatusRejected
+	}
+	if err := s.appendDecision(ctx, &decided); err != nil {
+		s.approvalMu.Unlock()
+		return nil, err
+	}
+	s.approvals.Store(id, &decided)
+	s.approvalMu.Unlock()
+
+	if !decision.Approved {
+		log.Printf("[API] 审批 %s 已拒绝，工作流 %s，TraceID: %s", id, decided.Workflow, decided.TraceId)
+		resp := errorResponse(decided.Workflow, (&agents.ApprovalRejectedError{Agent: decided.Agent, Comment: decision.Comment}).Error(), decided.TraceId)
+		resp.Version = decided.Version
+		resp.Approval = &decided
+		return resp, nil
+	}
+
+	log.Printf("[API] 审批 %s 已通过，恢复工作流 %s，TraceID: %s", id, decided.Workflow, decided.TraceId)
+	req := WorkflowRequest{
+		Workflow:   decided.Workflow,
+		Version:    decided.Version,
+		Agent:      decided.EntryAgent,
+		Input:      decided.Input,
+		UserId:     decided.UserId,
+		ArchiveId:  decided.ArchiveId,
+		TraceId:    decided.TraceId,
+		Parameters: decided.Parameters,
+	}
+	return s.execute(ctx, req, runOptions{approval: &decided})
+}
+
+// appendDecision 将人工决定作为 request_approval 的函数响应追加到会话，并更新会话中的记录
+func (s *WorkflowService) appendDecision(ctx context.Context, record *ApprovalRecord) error {
+	session, err := s.sessions.GetSession(ctx, record.Workflow, record.UserId, record.SessionId, nil)
+	if err != nil {
+		return err
+	}
+	content, _ := json.Marshal(record.Decision)
+	event := events.NewEvent()
+	event.InvocationID = record.TraceId
+	event.Author = agents.UserAuthor
+	event.Branch = record.Branch
+	event.Content = &events.Content{Parts: []*models.Part{{
+		Role:             "user",
+		FunctionResponse: &models.FunctionResponse{Name: agents.RequestApprovalFunction, Content: string(content), ID: record.ID},
+	}}}
+	event.Actions.StateDelta[approvalStateKey(record.ID)] = record
+	_, err = s.sessions.AppendEvent(ctx, session, event)
+	return err
+}
+
+// handleApprovals 列出审批记录
+//
+//	GET /api/approvals?status=pending&workflow=...&user_id=...  status 默认为 pending，all 表示全部
+func (s *HttpServer) handleApprovals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "仅支持 GET 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	status := q.Get("status")
+	switch status {
+	case "":
+		status = ApprovalStatusPending
+	case "all":
+		status = ""
+	}
+	approvals := s.service.ListApprovals(status, q.Get("workflow"), q.Get("user_id"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"approvals": approvals,
+		"count":     len(approvals),
+	})
+}
+
+// handleApproval 查看或处理单个审批记录
+//
+//	GET  /api/approvals/{id}  查看审批记录
+//	POST /api/approvals/{id}  提交决定 {"approved": true, "content": "修改后的内容", "comment": "..."}，
+//	                          恢复运行并返回与 /api/execute 相同的响应；再次暂停时返回 202
+func (s *HttpServer) handleApproval(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(r.URL.Path[len("/api/approvals/"):], "/")
+	if id == "" {
+		http.Error(w, "缺少审批ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		record, err := s.service.GetApproval(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(record)
+	case http.MethodPost:
+		var decision agents.ApprovalDecision
+		if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+			http.Error(w, "请求格式错误", http.StatusBadRequest)
+			return
+		}
+		resp, err := s.service.ResolveApproval(r.Context(), id, decision)
+		switch err {
+		case nil:
+		case ErrApprovalPending:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(resp)
+			return
+		case ErrApprovalNotFound, ErrWorkflowNotFound, ErrVersionNotFound, ErrAgentNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case ErrApprovalResolved:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case ErrInvalidRequest:
+			http.Error(w, "输入步骤需提供 content", http.StatusBadRequest)
+			return
+		default:
+			http.Error(w, "执行工作流失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/flow"
+)
+
+// TestApprovalPauseAndResume 验证运行在审批步骤暂停、通过 API 查看待审批记录并以修改后的内容恢复
+func TestApprovalPauseAndResume(t *testing.T) {
+	outlines := 0
+	outline := agents.NewAgent(agents.WithName("outline"), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+		outlines++
+		return "大纲: " + msg, nil
+	}))
+	review := agents.NewApprovalAgent(agents.ApprovalAgentConfig{Name: "review", Prompt: "请审批大纲"})
+	writer := agents.NewAgent(agents.WithName("writer"), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+		return "正文 <- " + msg, nil
+	}))
+	root := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+		Name:   "novel",
+		Agents: []agents.Runnable{outline, review, writer},
+	})
+
+	mgr := flow.NewManager()
+	mgr.Register("novel", &root.Agent)
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+
+	// 执行在审批步骤暂停，返回 202 与待审批记录
+	rec := httptest.NewRecorder()
+	httpSrv.handleExecute(rec, httptest.NewRequest(http.MethodPost, "/api/execute",
+		strings.NewReader(`{"workflow":"novel","input":"龙","user_id":"u1","archive_id":"a1"}`)))
+	var resp WorkflowResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusAccepted || resp.Approval == nil || resp.Approval.Content != "大纲: 龙" {
+		t.Fatalf("期望 202 及待审批记录，实际 %d %s", rec.Code, rec.Body.String())
+	}
+	id := resp.Approval.ID
+
+	rec = httptest.NewRecorder()
+	httpSrv.handleApprovals(rec, httptest.NewRequest(http.MethodGet, "/api/approvals?user_id=u1", nil))
+	var list struct {
+		Approvals []*ApprovalRecord `json:"approvals"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Approvals) != 1 || list.Approvals[0].ID != id || list.Approvals[0].Branch != "novel.review" {
+		t.Fatalf("待审批列表错误: %s", rec.Body.String())
+	}
+
+	// 会话中记录了请求审批的长时间运行函数调用
+	session, err := httpSrv.service.sessions.GetSession(context.Background(), "novel", "u1", resp.TraceId, nil)
+	if err != nil {
+		t.Fatalf("会话未创建: %v", err)
+	}
+	if len(session.Events) != 1 || session.Events[0].LongRunningToolIDs[0] != id {
+		t.Fatalf("会话未保存审批事件: %+v", session.Events)
+	}
+
+	decide := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		httpSrv.handleApproval(rec, httptest.NewRequest(http.MethodPost, "/api/approvals/"+id, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	// 以修改后的内容恢复，已完成的大纲步骤不再执行
+	rec = decide(`{"approved":true,"content":"修改后的大纲"}`)
+	resp = WorkflowResponse{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Output != "正文 <- 修改后的大纲" || outlines != 1 {
+		t.Fatalf("恢复运行错误: %d %s (outlines %d)", rec.Code, rec.Body.String(), outlines)
+	}
+	if r, err := httpSrv.service.GetApproval(id); err != nil || r.Status != ApprovalStatusApproved || len(session.Events) != 2 {
+		t.Errorf("审批记录 = %+v, %v，会话事件 %d 个", r, err, len(session.Events))
+	}
+
+	// 已处理的审批不能再次提交
+	if rec = decide(`{"approved":false}`); rec.Code != http.StatusConflict {
+		t.Errorf("期望 409，实际 %d", rec.Code)
+	}
+}
+
+// TestApprovalRestoredAfterRestart 验证服务重启后（共享会话与检查点存储）仍能查看并处理重启前暂停的审批
+func TestApprovalRestoredAfterRestart(t *testing.T) {
+	review := agents.NewApprovalAgent(agents.ApprovalAgentConfig{Name: "review", Prompt: "请审批"})
+	writer := agents.NewAgent(agents.WithName("writer"), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+		return "正文 <- " + msg, nil
+	}))
+	root := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+		Name:   "novel",
+		Agents: []agents.Runnable{review, writer},
+	})
+
+	mgr := flow.NewManager()
+	mgr.Register("novel", &root.Agent)
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+
+	resp, err := httpSrv.service.Execute(context.Background(), WorkflowRequest{Workflow: "novel", Input: "龙", UserId: "u1"})
+	if err != ErrApprovalPending || resp.Approval == nil {
+		t.Fatalf("期望暂停，实际 %+v, %v", resp, err)
+	}
+	id := resp.Approval.ID
+
+	// 新的服务实例内存中没有审批记录，从会话状态恢复
+	restarted := NewWorkflowService(mgr, httpSrv.sched)
+	restarted.SetSessionService(httpSrv.service.sessions)
+	restarted.SetCheckpointStore(httpSrv.service.checkpoints)
+
+	if r, err := restarted.GetApproval(id); err != nil || r.Status != ApprovalStatusPending || r.Input != "龙" {
+		t.Fatalf("审批记录未恢复: %+v, %v", r, err)
+	}
+	if list := restarted.ListApprovals(ApprovalStatusPending, "novel", "u1"); len(list) != 1 || list[0].ID != id {
+		t.Fatalf("待审批列表未恢复: %+v", list)
+	}
+	resp, err = restarted.ResolveApproval(context.Background(), id, agents.ApprovalDecision{Approved: true})
+	if err != nil || resp.Output != "正文 <- 龙" {
+		t.Fatalf("恢复运行错误: %+v, %v", resp, err)
+	}
+}
@@ -28,6 +28,39 @@
 //  3. POST /api/execute             同步执行工作流，返回 JSON 结果
 //  4. POST /api/stream              流式执行工作流，返回 Server-Sent Events
 //  5. GET  /health                  服务健康检查
+//  6. GET  /api/admin/flows/{name}          查看工作流版本、权重与各版本统计
+//  7. PUT  /api/admin/flows/{name}/weights  设置版本流量权重（灰度发布）
+//  8. GET  /api/admin/plugins               查询插件文件加载状态（需 SetLoader）
+//  9. GET  /api/admin/routes                列出工作流声明的自定义路由
+// 10. POST /api/warmup                      会话开始时预热工作流的预生成阶段
+// 11. GET  /api/admin/config                查看生效配置（隐去密钥）、日志级别与重载记录（需 SetConfigReloader）
+// 12. POST /api/admin/config/reload         立即重新加载配置文件，失败时返回 422
+// 13. GET  /api/approvals                   列出待审批记录
+// 14. GET  /api/approvals/{id}              查看审批记录
+// 15. POST /api/approvals/{id}              提交人工决定并恢复运行
+// 16. GET  /api/runs                        列出运行记录
+// 17. GET  /api/runs/{trace_id}             查看运行记录与步骤检查点
+// 18. POST /api/runs/{trace_id}/resume      从最后一个成功的步骤恢复运行，可强制重跑指定步骤
+// 19. DELETE /api/runs/{trace_id}           删除运行记录与检查点
+//
+// 工作流中的 agents.ApprovalAgent 会让运行暂停：/api/execute 返回 202 与待审批记录，
+// 运行状态保存在会话服务中，提交决定后从审批步骤继续执行，已完成的步骤不再执行。
+//
+// 组合 Agent 的每个步骤成功后以 trace_id 为运行ID保存检查点，失败的运行可通过
+// /api/runs/{trace_id}/resume 以原始请求恢复，输入未变的已完成步骤直接使用保存的输出。
+//
+// 配置了 pre_generate 的工作流在主流程前执行预生成 Agent。客户端可在打开会话时调用
+// /api/warmup（字段同 /api/execute），预生成结果按 user_id + archive_id 缓存，
+// 由该用户输入相同的下一次执行请求直接使用。
+//
+// 工作流可通过 flow.RouteConfig 声明自定义路由（如 POST /novel/outline），
+// 请求直接交由 Agent 树中指定的子 Agent 处理。路由随工作流加载 / 卸载动态挂载，
+// 请求体先按路由的 request schema 校验，失败时返回 400 与字段错误列表；
+// 请求体的 "input" 字段作为输入（缺省时为整个 JSON），"user_id"、"archive_id"、
+// "version" 与 /api/execute 含义相同。
+//
+// 同一工作流可同时加载多个版本。执行请求可通过 `version` 字段固定版本，
+// 否则按权重分流；响应的 `version` 字段记录实际服务的版本。
 //
 // 请求/响应体均采用 JSON 编码。字段含义请参考各结构体的 GoDoc 注释。
 //
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/flow"
+	"github.com/nvcnvn/adk-golang/pkg/schema"
+)
+
+// TestFlowRoutes 验证自定义路由的挂载、子 Agent 分发、schema 校验与卸载
+func TestFlowRoutes(t *testing.T) {
+	outline := newEchoAgent("outline", "outline result")
+	root := agents.NewAgent(
+		agents.WithName("novel"),
+		agents.WithSubAgents(outline),
+	)
+	reqSchema, err := schema.Parse([]byte(`{"type":"object","required":["input"],"properties":{"input":{"type":"string","minLength":1}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := []flow.RouteConfig{{Path: "/novel/outline", Agent: "outline", Request: reqSchema}}
+	if err := flow.ValidateRoutes(root, routes); err != nil {
+		t.Fatalf("路由校验失败: %v", err)
+	}
+	if err := flow.ValidateRoutes(root, []flow.RouteConfig{{Path: "/novel/x", Agent: "missing"}}); err == nil {
+		t.Fatal("指向不存在 agent 的路由应校验失败")
+	}
+
+	mgr := flow.NewManager()
+	mgr.RegisterVersion("novel", "v1", root, routes...)
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+
+	call := func(method, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		httpSrv.handleFlowRoute(rec, httptest.NewRequest(method, "/novel/outline", bytes.NewBufferString(body)))
+		return rec
+	}
+
+	// 由子 Agent 处理
+	rec := call(http.MethodPost, `{"input":"写个大纲","user_id":"u1"}`)
+	var resp WorkflowResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Output != "outline result" || resp.Version != "v1" {
+		t.Fatalf("路由执行错误: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// schema 校验失败返回字段错误
+	rec = call(http.MethodPost, `{"input":""}`)
+	var verr schema.ValidationError
+	json.Unmarshal(rec.Body.Bytes(), &verr)
+	if rec.Code != http.StatusBadRequest || len(verr.Errors) != 1 || verr.Errors[0].Path != "$.input" {
+		t.Fatalf("期望 400 及字段错误，实际 %d %s", rec.Code, rec.Body.String())
+	}
+
+	// 方法不匹配
+	if rec = call(http.MethodGet, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("期望 405，实际 %d", rec.Code)
+	}
+
+	// 权重将流量全部分给未声明该路由的 v2 时，路由请求仍在 v1 执行
+	mgr.RegisterVersion("novel", "v2", agents.NewAgent(agents.WithName("novel")))
+	if err := mgr.SetWeights("novel", map[string]int{"v2": 100}); err != nil {
+		t.Fatal(err)
+	}
+	if ri, ok := mgr.MatchRoute(http.MethodPost, "/novel/outline"); !ok || ri.Version != "v1" {
+		t.Fatalf("路由版本错误: %+v", ri)
+	}
+	for i := 0; i < 5; i++ {
+		rec = call(http.MethodPost, `{"input":"写个大纲"}`)
+		resp = WorkflowResponse{}
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		if rec.Code != http.StatusOK || resp.Version != "v1" {
+			t.Fatalf("路由未固定在声明版本: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// 卸载工作流后路由随之移除
+	mgr.UnregisterVersion("novel", "v1")
+	if rec = call(http.MethodPost, `{"input":"hi"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("期望 404，实际 %d", rec.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/flow"
+)
+
+// newEchoAgent 创建直接返回固定文本的 Agent，便于区分服务的版本
+func newEchoAgent(name, reply string) *agents.Agent {
+	return agents.NewAgent(
+		agents.WithName(name),
+		agents.WithBeforeAgentCallback(func(ctx context.Context, msg string) (string, bool) {
+			return reply, true
+		}),
+	)
+}
+
+// TestFlowVersionRouting 验证版本固定、权重分流及响应中的版本记录
+func TestFlowVersionRouting(t *testing.T) {
+	mgr := flow.NewManager()
+	mgr.RegisterVersion("novel", "v1", newEchoAgent("novel_v1", "from v1"))
+	mgr.RegisterVersion("novel", "v2", newEchoAgent("novel_v2", "from v2"))
+
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+
+	execute := func(req WorkflowRequest) (*httptest.ResponseRecorder, WorkflowResponse) {
+		body, _ := json.Marshal(req)
+		rec := httptest.NewRecorder()
+		httpSrv.handleExecute(rec, httptest.NewRequest(http.MethodPost, "/api/execute", bytes.NewReader(body)))
+		var resp WorkflowResponse
+		json.Unmarshal(rec.Body.Bytes(), &resp)
+		return rec, resp
+	}
+
+	// 未配置权重时由最近注册的版本承接流量
+	if _, resp := execute(WorkflowRequest{Workflow: "novel", Input: "hi"}); resp.Version != "v2" || resp.Output != "from v2" {
+		t.Fatalf("默认版本错误: %+v", resp)
+	}
+
+	// 固定版本
+	if _, resp := execute(WorkflowRequest{Workflow: "novel", Version: "v1", Input: "hi"}); resp.Version != "v1" || resp.Output != "from v1" {
+		t.Fatalf("固定版本错误: %+v", resp)
+	}
+
+	// 不存在的版本返回 404
+	if rec, _ := execute(WorkflowRequest{Workflow: "novel", Version: "v9", Input: "hi"}); rec.Code != http.StatusNotFound {
+		t.Fatalf("期望 404，实际 %d", rec.Code)
+	}
+	stream := WorkflowRequest{Workflow: "novel", Version: "v9", Input: "hi"}
+	if err := httpSrv.service.ExecuteStream(context.Background(), stream, func(string, bool, error) {}); err != ErrVersionNotFound {
+		t.Fatalf("流式执行不存在的版本: 期望 ErrVersionNotFound，实际 %v", err)
+	}
+
+	// 通过管理接口将全部流量切到 v1
+	rec := httptest.NewRecorder()
+	httpSrv.handleAdminFlow(rec, httptest.NewRequest(http.MethodPut, "/api/admin/flows/novel/weights", bytes.NewBufferString(`{"v1":100,"v2":0}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("设置权重失败: %d %s", rec.Code, rec.Body.String())
+	}
+	for i := 0; i < 10; i++ {
+		if _, resp := execute(WorkflowRequest{Workflow: "novel", Input: "hi"}); resp.Version != "v1" {
+			t.Fatalf("权重分流错误: %+v", resp)
+		}
+	}
+
+	// 负权重被拒绝
+	rec = httptest.NewRecorder()
+	httpSrv.handleAdminFlow(rec, httptest.NewRequest(http.MethodPut, "/api/admin/flows/novel/weights", bytes.NewBufferString(`{"v1":-1}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("期望 400，实际 %d", rec.Code)
+	}
+
+	// 统计按版本记录
+	counts := map[string]int64{}
+	for _, v := range mgr.ListVersions("novel") {
+		counts[v.Version] = v.Requests
+	}
+	if counts["v1"] != 11 || counts["v2"] != 1 {
+		t.Fatalf("版本统计错误: %v", counts)
+	}
+}
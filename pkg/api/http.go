@@ -8,9 +8,14 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/config"
 	"github.com/nvcnvn/adk-golang/pkg/flow"
+	"github.com/nvcnvn/adk-golang/pkg/logger"
 	"github.com/nvcnvn/adk-golang/pkg/scheduler"
 )
 
@@ -20,20 +25,33 @@ type HttpServer struct {
 	sched   scheduler.Scheduler
 	addr    string
 	server  *http.Server
+	loader  *flow.Loader // 可选，用于查询插件加载状态
+	config  *config.Reloader // 可选，用于配置热重载
+
+	remoteMu     sync.RWMutex
+	remoteTokens []string // 远程智能体协议允许的 Bearer 令牌
 }
 
 // NewHttpServer 创建 HTTP API 服务器
 func NewHttpServer(manager *flow.Manager, addr string) *HttpServer {
 	// 创建调度器，默认 8 workers, 队列 32
 	proc := func(ctx context.Context, task *scheduler.Task) (string, error) {
-		ag, ok := manager.Get(task.Workflow)
+		ag, _, ok := manager.Resolve(task.Workflow, task.Version)
 		if !ok {
 			return "", errors.New("workflow not found")
 		}
+		if task.Agent != "" {
+			// 自定义路由直接由指定子 Agent 处理
+			if ag = flow.FindAgent(ag, task.Agent); ag == nil {
+				return "", fmt.Errorf("agent %s not found in workflow %s", task.Agent, task.Workflow)
+			}
+		}
 		
 		// 注入用户标识和归档标识到context中，供插件层访问
 		ctx = context.WithValue(ctx, "user_id", task.UserID)
 		ctx = context.WithValue(ctx, "archive_id", task.ArchiveID)
+		// 请求参数用于解析 instruction 中的占位符
+		ctx = agents.WithTemplateParams(ctx, task.Params)
 		
 		return ag.Process(ctx, task.Input)
 	}
@@ -47,6 +65,16 @@ func NewHttpServer(manager *flow.Manager, addr string) *HttpServer {
 	}
 }
 
+// SetLoader 设置插件加载器，启用 /api/admin/plugins 查询
+func (s *HttpServer) SetLoader(loader *flow.Loader) {
+	s.loader = loader
+}
+
+// SetConfigReloader 设置配置热重载器，启用 /api/admin/config 查询与手动重载
+func (s *HttpServer) SetConfigReloader(r *config.Reloader) {
+	s.config = r
+}
+
 // Start 启动 HTTP 服务
 func (s *HttpServer) Start() error {
 	mux := http.NewServeMux()
@@ -56,8 +84,24 @@ func (s *HttpServer) Start() error {
 	mux.HandleFunc("/api/workflows/", s.handleWorkflowInfo)
 	mux.HandleFunc("/api/execute", s.handleExecute)
 	mux.HandleFunc("/api/stream", s.handleExecuteStream)
+	mux.HandleFunc("/api/warmup", s.handleWarmup)
+	mux.HandleFunc("/api/approvals", s.handleApprovals)
+	mux.HandleFunc("/api/approvals/", s.handleApproval)
+	mux.HandleFunc("/api/runs", s.handleRuns)
+	mux.HandleFunc("/api/runs/", s.handleRun)
+	mux.HandleFunc(agents.RemoteInvokePath, s.handleRemoteInvoke)
+	mux.HandleFunc(agents.RemoteStreamPath, s.handleRemoteStream)
+	mux.HandleFunc(agents.RemoteCancelPath, s.handleRemoteCancel)
+	mux.HandleFunc("/api/admin/flows/", s.handleAdminFlow)
+	mux.HandleFunc("/api/admin/plugins", s.handleAdminPlugins)
+	mux.HandleFunc("/api/admin/routes", s.handleAdminRoutes)
+	mux.HandleFunc("/api/admin/config", s.handleAdminConfig)
+	mux.HandleFunc("/api/admin/config/reload", s.handleAdminConfigReload)
 	mux.HandleFunc("/health", s.handleHealth)
 
+	// 工作流声明的自定义路由，随工作流加载 / 卸载动态生效
+	mux.HandleFunc("/", s.handleFlowRoute)
+
 	// 创建 HTTP 服务器
 	s.server = &http.Server{
 		Addr:    s.addr,
@@ -164,8 +208,17 @@ func (s *HttpServer) handleExecute(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.service.Execute(ctx, req)
 	if err != nil {
 		switch err {
+		case ErrApprovalPending:
+			// 运行在审批步骤暂停，响应携带待审批记录
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(resp)
 		case ErrWorkflowNotFound:
 			http.Error(w, "工作流未找到", http.StatusNotFound)
+		case ErrVersionNotFound:
+			http.Error(w, "工作流版本未找到", http.StatusNotFound)
+		case ErrAgentNotFound:
+			http.Error(w, "子 Agent 未找到", http.StatusNotFound)
 		case ErrInvalidRequest:
 			http.Error(w, "无效的请求", http.StatusBadRequest)
 		default:
@@ -178,6 +231,33 @@ func (s *HttpServer) handleExecute(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// handleWarmup 会话开始时预热工作流的预生成阶段，立即返回 202
+func (s *HttpServer) handleWarmup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST 请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req WorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+
+	warming, err := s.service.Warmup(req)
+	if err != nil {
+		http.Error(w, "工作流未找到", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"workflow": req.Workflow,
+		"warming":  warming,
+	})
+}
+
 // handleExecuteStream 流式执行工作流
 func (s *HttpServer) handleExecuteStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -228,6 +308,212 @@ func (s *HttpServer) handleExecuteStream(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// handleAdminFlow 管理工作流版本
+//
+//	GET /api/admin/flows/{name}          查看版本列表、权重及各版本请求/错误统计
+//	PUT /api/admin/flows/{name}/weights  设置版本权重，请求体形如 {"v1": 90, "v2": 10}
+func (s *HttpServer) handleAdminFlow(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path[len("/api/admin/flows/"):], "/")
+	name, sub, _ := strings.Cut(path, "/")
+	if name == "" {
+		http.Error(w, "缺少工作流名称", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case sub == "" && r.Method == http.MethodGet:
+		versions, err := s.service.ListVersions(name)
+		if err != nil {
+			http.Error(w, "工作流未找到", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":     name,
+			"versions": versions,
+		})
+	case sub == "weights" && r.Method == http.MethodPut:
+		var weights map[string]int
+		if err := json.NewDecoder(r.Body).Decode(&weights); err != nil {
+			http.Error(w, "请求格式错误", http.StatusBadRequest)
+			return
+		}
+		if err := s.service.SetWeights(name, weights); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		versions, _ := s.service.ListVersions(name)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"name":     name,
+			"weights":  weights,
+			"versions": versions,
+		})
+	case sub == "" || sub == "weights":
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleAdminPlugins 查询插件文件加载状态（loaded / failed / superseded / unloaded）
+func (s *HttpServer) handleAdminPlugins(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "仅支持 GET 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.loader == nil {
+		http.Error(w, "未启用插件加载器", http.StatusNotFound)
+		return
+	}
+	plugins := s.loader.Status()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"plugins": plugins,
+		"count":   len(plugins),
+	})
+}
+
+// handleAdminConfig 查看当前生效配置（隐去密钥）、日志级别与最近的重载记录
+func (s *HttpServer) handleAdminConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "仅支持 GET 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config == nil {
+		http.Error(w, "未启用配置热重载", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":    s.config.Current().Redacted(),
+		"log_level": logger.Level(),
+		"reloads":   s.config.History(),
+	})
+}
+
+// handleAdminConfigReload 立即重新加载配置文件，返回本次重载结果
+func (s *HttpServer) handleAdminConfigReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config == nil {
+		http.Error(w, "未启用配置热重载", http.StatusNotFound)
+		return
+	}
+	st := s.config.Reload(config.ReloadTriggerManual)
+	w.Header().Set("Content-Type", "application/json")
+	if !st.Success {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(st)
+}
+
+// handleAdminRoutes 列出当前挂载的工作流自定义路由
+func (s *HttpServer) handleAdminRoutes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "仅支持 GET 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	routes := s.service.ListRoutes()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"routes": routes,
+		"count":  len(routes),
+	})
+}
+
+// handleFlowRoute 处理工作流通过 RouteConfig 声明的路由。
+//
+// 请求体（GET 为查询参数）先按路由的 request schema 校验，失败返回 400 及字段错误列表；
+// 通过后以 "input" 字段作为输入（缺省时为整个请求体 JSON），交由路由指定的子 Agent 执行。
+// "user_id"、"archive_id"、"version" 字段与 /api/execute 含义相同；
+// 未指定 "version" 时固定在声明该路由的版本执行，不参与按权重选择版本。
+func (s *HttpServer) handleFlowRoute(w http.ResponseWriter, r *http.Request) {
+	route, ok := s.service.MatchRoute(r.Method, r.URL.Path)
+	if !ok {
+		if s.service.HasRoutePath(r.URL.Path) {
+			http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	var (
+		body map[string]interface{}
+		raw  []byte
+	)
+	if r.Method == http.MethodGet {
+		body = make(map[string]interface{})
+		for k, vs := range r.URL.Query() {
+			if len(vs) > 0 {
+				body[k] = vs[0]
+			}
+		}
+		raw, _ = json.Marshal(body)
+	} else {
+		var err error
+		if raw, err = io.ReadAll(r.Body); err != nil {
+			http.Error(w, "读取请求体失败", http.StatusBadRequest)
+			return
+		}
+		if len(strings.TrimSpace(string(raw))) == 0 {
+			raw = []byte("{}")
+		}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			http.Error(w, "请求格式错误，需为 JSON 对象", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := route.Request.Validate(body); err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(err)
+		return
+	}
+
+	req := WorkflowRequest{
+		Workflow: route.Flow,
+		Version:  route.Version,
+		Agent:    route.Agent,
+		Input:    string(raw),
+	}
+	if v, ok := body["input"].(string); ok {
+		req.Input = v
+	}
+	if v, ok := body["user_id"].(string); ok {
+		req.UserId = v
+	}
+	if v, ok := body["archive_id"].(string); ok {
+		req.ArchiveId = v
+	}
+	if v, ok := body["version"].(string); ok {
+		req.Version = v
+	}
+	req.Parameters = body
+
+	resp, err := s.service.Execute(r.Context(), req)
+	if err != nil {
+		switch err {
+		case ErrApprovalPending:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(resp)
+		case ErrWorkflowNotFound, ErrVersionNotFound, ErrAgentNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		default:
+			http.Error(w, "执行工作流失败", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
 // 发送 SSE 事件
 func sendEvent(w io.Writer, event, data string) {
 	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
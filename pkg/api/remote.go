@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/flow"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/scheduler"
+	"github.com/nvcnvn/adk-golang/pkg/types"
+)
+
+// ErrInvocationExists 表示远程调用的 invocation_id 已在运行中。
+var ErrInvocationExists = errors.New("invocation_id 已在运行中")
+
+// RunRemote 按远程智能体协议执行工作流，返回运行事件，最后一个事件为 FinalResponse 或 RunFailed。
+// 与 /api/execute 一样通过调度器执行并受超时约束（默认 30 秒），队列已满时返回 scheduler.ErrQueueFull；
+// user_id / archive_id 注入 context 供插件层访问。运行期间可通过 CancelRemote 以 invocation_id 取消。
+func (s *WorkflowService) RunRemote(ctx context.Context, req agents.RemoteRequest) (<-chan *events.Event, error) {
+	if req.InvocationID == "" {
+		req.InvocationID = events.GenerateID()
+	}
+	if req.Timeout <= 0 {
+		req.Timeout = 30
+	}
+	agent, version, exists := s.manager.Resolve(req.Workflow, req.Version)
+	if !exists {
+		if req.Version != "" && len(s.manager.ListVersions(req.Workflow)) > 0 {
+			return nil, ErrVersionNotFound
+		}
+		return nil, ErrWorkflowNotFound
+	}
+	if req.Agent != "" {
+		if agent = flow.FindAgent(agent, req.Agent); agent == nil {
+			return nil, ErrAgentNotFound
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Second)
+	runCtx = context.WithValue(runCtx, "user_id", req.UserID)
+	runCtx = context.WithValue(runCtx, "archive_id", req.ArchiveID)
+	runCtx = agents.WithTemplateParams(runCtx, req.Parameters)
+	ic := agents.NewInvocationContext(req.InvocationID, agent, &types.RunConfig{StreamingMode: types.StreamingModeSSE})
+	ic.InvocationEvent = &events.Event{
+		Author:  agents.UserAuthor,
+		Content: &events.Content{Parts: []*models.Part{{Text: req.Input, Role: "user"}}},
+	}
+	// 排队期间取消同样生效，Agent.Run 开始后替换为其运行的取消函数
+	ic.SetCancelFunc(cancel)
+	if _, loaded := s.remoteRuns.LoadOrStore(req.InvocationID, ic); loaded {
+		cancel()
+		return nil, ErrInvocationExists
+	}
+
+	// 调度器 worker 启动运行并交出事件通道，直到事件转发完毕、运行结束才释放 worker
+	started := make(chan (<-chan *events.Event), 1)
+	finished := make(chan struct{})
+	resultCh := make(chan scheduler.Result, 1)
+	task := &scheduler.Task{
+		Ctx:       runCtx,
+		Workflow:  req.Workflow,
+		Version:   version,
+		Agent:     req.Agent,
+		Input:     req.Input,
+		UserID:    req.UserID,
+		ArchiveID: req.ArchiveID,
+		Params:    req.Parameters,
+		Run: func(ctx context.Context) (string, error) {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			eventCh, err := agent.Run(ctx, ic)
+			if err != nil {
+				return "", err
+			}
+			started <- eventCh
+			<-finished
+			// 转发方在排队超时或取消时可能未读取事件就已返回（两者与 started 同时就绪），
+			// 由 worker 读完剩余事件，避免 Agent 的运行协程阻塞在发送上
+			for range eventCh {
+			}
+			return "", nil
+		},
+		ResultChan: resultCh,
+	}
+	if err := s.sched.Submit(task); err != nil {
+		s.remoteRuns.Delete(req.InvocationID)
+		cancel()
+		return nil, err
+	}
+
+	log.Printf("[API] 远程调用工作流 %s@%s，InvocationID: %s", req.Workflow, version, req.InvocationID)
+	out := make(chan *events.Event)
+	go func() {
+		defer close(out)
+		defer cancel()
+		defer s.remoteRuns.Delete(req.InvocationID)
+		defer close(finished)
+
+		var (
+			runErr   error
+			terminal bool
+		)
+		select {
+		case eventCh := <-started:
+			for ev := range eventCh {
+				switch ev.Type {
+				case events.FinalResponse:
+					terminal = true
+				case events.RunFailed:
+					terminal = true
+					runErr = errors.New(ev.ErrorMessage)
+				}
+				out <- ev
+			}
+		case res := <-resultCh:
+			// 运行未能开始
+			runErr = res.Err
+		case <-runCtx.Done():
+			// 排队期间超时或被取消
+		}
+		if !terminal {
+			// 运行的 context 结束后其事件不再转发，以 RunFailed 结束事件流
+			if runErr == nil {
+				runErr = runCtx.Err()
+			}
+			if runErr == nil {
+				runErr = errors.New("运行结束但没有最终响应")
+			}
+			out <- remoteFailure(ic, agent.Name(), runErr)
+		}
+		s.manager.RecordResult(req.Workflow, version, runErr)
+	}()
+	return out, nil
+}
+
+// remoteFailure 为没有以 FinalResponse 或 RunFailed 结束的远程调用生成 RunFailed 事件
+func remoteFailure(ic *agents.InvocationContext, author string, err error) *events.Event {
+	ev := events.NewEvent()
+	ev.Type = events.RunFailed
+	ev.InvocationID = ic.InvocationID
+	ev.Author = author
+	ev.ErrorCode = agents.ErrorCodeAgent
+	ev.ErrorMessage = err.Error()
+	return ev
+}
+
+// CancelRemote 取消运行中的远程调用，调用不存在时返回 false。
+func (s *WorkflowService) CancelRemote(invocationID string) bool {
+	v, ok := s.remoteRuns.Load(invocationID)
+	if !ok {
+		return false
+	}
+	v.(*agents.InvocationContext).Cancel()
+	return true
+}
+
+// SetRemoteTokens 设置远程智能体协议允许的 Bearer 令牌，为空时 /api/remote/* 不可用
+func (s *HttpServer) SetRemoteTokens(tokens []string) {
+	s.remoteMu.Lock()
+	defer s.remoteMu.Unlock()
+	s.remoteTokens = append([]string(nil), tokens...)
+}
+
+// authorizeRemote 校验远程调用的 Bearer 令牌，失败时写入错误响应并返回 false
+func (s *HttpServer) authorizeRemote(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "仅支持 POST 请求", http.StatusMethodNotAllowed)
+		return false
+	}
+
+	s.remoteMu.RLock()
+	tokens := s.remoteTokens
+	s.remoteMu.RUnlock()
+	if len(tokens) == 0 {
+		http.Error(w, "远程智能体协议未启用", http.StatusNotFound)
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	for _, t := range tokens {
+		// 常量时间比较，避免通过响应耗时猜测令牌
+		if t != "" && subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="adk"`)
+	http.Error(w, "未授权", http.StatusUnauthorized)
+	return false
+}
+
+// startRemote 解析请求并开始远程调用，失败时写入错误响应
+func (s *HttpServer) startRemote(w http.ResponseWriter, r *http.Request) (agents.RemoteRequest, <-chan *events.Event, bool) {
+	var req agents.RemoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "请求格式错误", http.StatusBadRequest)
+		return req, nil, false
+	}
+	if req.InvocationID == "" {
+		req.InvocationID = events.GenerateID()
+	}
+
+	eventCh, err := s.service.RunRemote(r.Context(), req)
+	if err != nil {
+		switch err {
+		case ErrWorkflowNotFound:
+			http.Error(w, "工作流未找到", http.StatusNotFound)
+		case ErrVersionNotFound:
+			http.Error(w, "工作流版本未找到", http.StatusNotFound)
+		case ErrAgentNotFound:
+			http.Error(w, "子 Agent 未找到", http.StatusNotFound)
+		case ErrInvocationExists:
+			http.Error(w, err.Error(), http.StatusConflict)
+		case scheduler.ErrQueueFull:
+			http.Error(w, "系统繁忙，请稍后再试", http.StatusServiceUnavailable)
+		default:
+			http.Error(w, "执行工作流失败", http.StatusInternalServerError)
+		}
+		return req, nil, false
+	}
+	return req, eventCh, true
+}
+
+// handleRemoteInvoke 执行远程调用，运行结束后一次性返回输出与全部事件
+//
+//	POST /api/remote/invoke  请求体为 agents.RemoteRequest，响应为 agents.RemoteResponse
+func (s *HttpServer) handleRemoteInvoke(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeRemote(w, r) {
+		return
+	}
+	req, eventCh, ok := s.startRemote(w, r)
+	if !ok {
+		return
+	}
+
+	resp := agents.RemoteResponse{InvocationID: req.InvocationID}
+	for ev := range eventCh {
+		resp.Events = append(resp.Events, ev)
+		switch ev.Type {
+		case events.FinalResponse:
+			resp.Output = ev.Content.GetText()
+		case events.RunFailed:
+			resp.Error, resp.ErrorCode = ev.ErrorMessage, ev.ErrorCode
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleRemoteStream 执行远程调用，以 SSE 逐个推送运行事件（event: event），
+// 最后一个事件为 FinalResponse 或 RunFailed
+//
+//	POST /api/remote/stream  请求体为 agents.RemoteRequest
+func (s *HttpServer) handleRemoteStream(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeRemote(w, r) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "流式传输不支持", http.StatusInternalServerError)
+		return
+	}
+	_, eventCh, ok := s.startRemote(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	for ev := range eventCh {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			continue
+		}
+		sendEvent(w, "event", string(data))
+		flusher.Flush()
+	}
+}
+
+// handleRemoteCancel 取消运行中的远程调用
+//
+//	POST /api/remote/cancel  请求体形如 {"invocation_id": "..."}，调用不存在时返回 404
+func (s *HttpServer) handleRemoteCancel(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeRemote(w, r) {
+		return
+	}
+	var req agents.RemoteCancelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.InvocationID == "" {
+		http.Error(w, "请求格式错误", http.StatusBadRequest)
+		return
+	}
+	if !s.service.CancelRemote(req.InvocationID) {
+		http.Error(w, "调用不存在或已结束", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"invocation_id": req.InvocationID,
+		"cancelled":     true,
+	})
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/flow"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+)
+
+// TestRemoteAgentProtocol 验证 RemoteAgent 作为本地流水线的子 Agent 调用另一部署的工作流
+func TestRemoteAgentProtocol(t *testing.T) {
+	cancelled := make(chan struct{}, 4)
+	mgr := flow.NewManager()
+	mgr.Register("specialist", agents.NewAgent(
+		agents.WithName("specialist"),
+		agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+			return strings.ToUpper(msg), nil
+		}),
+	))
+	mgr.Register("slow", agents.NewAgent(
+		agents.WithName("slow"),
+		agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+			<-ctx.Done()
+			cancelled <- struct{}{}
+			return "", ctx.Err()
+		}),
+	))
+	mgr.Register("whoami", agents.NewAgent(
+		agents.WithName("whoami"),
+		agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+			user, _ := ctx.Value("user_id").(string)
+			archive, _ := ctx.Value("archive_id").(string)
+			return user + "/" + archive, nil
+		}),
+	))
+
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+	httpSrv.SetRemoteTokens([]string{"secret"})
+	mux := http.NewServeMux()
+	mux.HandleFunc(agents.RemoteInvokePath, httpSrv.handleRemoteInvoke)
+	mux.HandleFunc(agents.RemoteStreamPath, httpSrv.handleRemoteStream)
+	mux.HandleFunc(agents.RemoteCancelPath, httpSrv.handleRemoteCancel)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	// 流式调用：远端事件挂在本地分支下转发
+	remote := agents.NewRemoteAgentWithConfig(agents.RemoteAgentConfig{
+		Name: "specialist", URL: ts.URL, Token: "secret", Stream: true,
+	})
+	draft := agents.NewAgent(agents.WithName("draft"), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+		return msg + " draft", nil
+	}))
+	pipeline := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+		Name:   "pipeline",
+		Agents: []agents.Runnable{draft, remote},
+	})
+	ic := agents.NewInvocationContext("local", pipeline, nil)
+	ic.InvocationEvent = &events.Event{Content: &events.Content{Parts: []*models.Part{{Text: "a"}}}}
+	ch, err := pipeline.Run(context.Background(), ic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final *events.Event
+	relayed := false
+	for ev := range ch {
+		if ev.Type == events.FinalResponse {
+			final = ev
+		}
+		if ev.Type == events.AgentStarted && ev.Branch == "pipeline.specialist" && ev.Author == "specialist" && ev.InvocationID == "local" {
+			relayed = relayed || ev.Content.GetText() == "a draft"
+		}
+	}
+	if final == nil || final.Content.GetText() != "A DRAFT" {
+		t.Fatalf("final event = %+v", final)
+	}
+	if !relayed {
+		t.Error("remote events not relayed")
+	}
+
+	// 错误的令牌返回 401
+	bad := agents.NewRemoteAgentWithConfig(agents.RemoteAgentConfig{Name: "specialist", URL: ts.URL, Token: "wrong"})
+	var remoteErr *agents.RemoteError
+	if _, err := bad.Process(context.Background(), "a"); !errors.As(err, &remoteErr) || remoteErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("bad token: %v", err)
+	}
+
+	// 取消本地调用时远端运行随之取消
+	slow := agents.NewRemoteAgentWithConfig(agents.RemoteAgentConfig{Name: "slow", URL: ts.URL, Token: "secret"})
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := slow.Process(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("slow: %v", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("remote run not cancelled")
+	}
+
+	// 远端以调用方的用户与归档身份运行
+	whoami := agents.NewRemoteAgentWithConfig(agents.RemoteAgentConfig{Name: "whoami", URL: ts.URL, Token: "secret"})
+	userCtx := context.WithValue(context.Background(), "user_id", "u1")
+	userCtx = context.WithValue(userCtx, "archive_id", "a1")
+	if out, err := whoami.Process(userCtx, "a"); err != nil || out != "u1/a1" {
+		t.Fatalf("whoami: %q, %v", out, err)
+	}
+
+	// 服务端超时结束运行
+	start := time.Now()
+	eventCh, err := httpSrv.service.RunRemote(context.Background(), agents.RemoteRequest{Workflow: "slow", Input: "a", Timeout: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := lastEvent(eventCh); last == nil || last.Type != events.RunFailed || time.Since(start) > 5*time.Second {
+		t.Fatalf("timeout: last event = %+v", last)
+	}
+	<-cancelled
+
+	// /api/remote/cancel 取消仍有客户端等待的运行
+	eventCh, err = httpSrv.service.RunRemote(context.Background(), agents.RemoteRequest{InvocationID: "inv-cancel", Workflow: "slow", Input: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+agents.RemoteCancelPath, strings.NewReader(`{"invocation_id":"inv-cancel"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("cancel status = %d", resp.StatusCode)
+	}
+	if last := lastEvent(eventCh); last == nil || last.Type != events.RunFailed {
+		t.Fatalf("cancel: last event = %+v", last)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("remote run not cancelled by /api/remote/cancel")
+	}
+	if httpSrv.service.CancelRemote("inv-cancel") {
+		t.Error("finished invocation still cancellable")
+	}
+}
+
+// lastEvent 读完事件通道并返回最后一个事件
+func lastEvent(ch <-chan *events.Event) *events.Event {
+	var last *events.Event
+	for ev := range ch {
+		last = ev
+	}
+	return last
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+// 运行状态
+const (
+	RunStatusRunning   = "running"   // 执行中
+	RunStatusSucceeded = "succeeded" // 执行成功
+	RunStatusFailed    = "failed"    // 执行失败，可从检查点恢复
+	RunStatusPaused    = "paused"    // 在审批步骤暂停，通过 /api/approvals/{id} 恢复
+)
+
+// DefaultRunRetention 已结束运行记录的默认保留时长
+const DefaultRunRetention = 24 * time.Hour
+
+// ErrRunNotFound 表示运行记录不存在。
+// ErrRunActive 表示运行尚未结束，不能恢复或删除。
+var (
+	ErrRunNotFound = errors.New("运行记录未找到") // 运行记录不存在
+	ErrRunActive   = errors.New("运行尚未结束")  // 运行执行中
+)
+
+// RunRecord 运行记录：运行的原始请求与状态。运行以 trace_id 标识，
+// 组合 Agent 每个步骤成功后的输出以 trace_id 为运行ID保存为检查点。
+type RunRecord struct {
+	Request     WorkflowRequest      `json:"request"`               // 原始请求，恢复时使用相同的输入与参数
+	Version     string               `json:"version,omitempty"`     // 服务的版本，恢复时固定该版本
+	Status      string               `json:"status"`                // running / succeeded / failed / paused
+	Error       string               `json:"error,omitempty"`       // 最近一次执行的错误
+	Attempts    int                  `json:"attempts"`              // 执行次数（含恢复）
+	Checkpoints []*agents.Checkpoint `json:"checkpoints,omitempty"` // 已完成步骤的检查点（仅 GetRun 返回）
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+}
+
+// ResumeRequest 从检查点恢复运行的请求
+type ResumeRequest struct {
+	Force   []string `json:"force,omitempty"`   // 强制重新执行的步骤，按 Agent 名称或分支（如 novel.format）
+	Timeout int      `json:"timeout,omitempty"` // 超时（秒），为空时沿用原请求
+}
+
+// runStatus 返回以 err 结束的运行的状态
+func runStatus(err error) string {
+	if err != nil {
+		return RunStatusFailed
+	}
+	return RunStatusSucceeded
+}
+
+// recordRun 更新运行记录；进入 running 状态时计为一次执行。
+// 运行成功后不再需要恢复，删除其检查点；运行结束时清理超过保留时长的运行记录。
+func (s *WorkflowService) recordRun(req WorkflowRequest, version, status string, err error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	now := time.Now()
+	record := &RunRecord{CreatedAt: now}
+	if v, ok := s.runs.Load(req.TraceId); ok {
+		prev := *v.(*RunRecord)
+		record = &prev
+	}
+	record.Request = req
+	record.Version = version
+	record.Status = status
+	record.Error = ""
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if status == RunStatusRunning {
+		record.Attempts++
+	}
+	record.UpdatedAt = now
+	s.runs.Store(req.TraceId, record)
+
+	if status == RunStatusSucceeded {
+		if err := s.checkpoints.DeleteCheckpoints(context.Background(), req.TraceId); err != nil {
+			log.Printf("[API] 删除运行 %s 的检查点失败: %v", req.TraceId, err)
+		}
+	}
+	if status != RunStatusRunning {
+		s.pruneRuns(now)
+	}
+}
+
+// pruneRuns 删除更新时间早于保留时长的已结束运行记录及其检查点；调用方需持有 runMu。
+// 执行中与暂停（等待审批）的运行不清理。
+func (s *WorkflowService) pruneRuns(now time.Time) {
+	if s.runRetention <= 0 {
+		return
+	}
+	s.runs.Range(func(k, v interface{}) bool {
+		r := v.(*RunRecord)
+		if r.Status != RunStatusSucceeded && r.Status != RunStatusFailed {
+			return true
+		}
+		if now.Sub(r.UpdatedAt) <= s.runRetention {
+			return true
+		}
+		traceID := k.(string)
+		if err := s.checkpoints.DeleteCheckpoints(context.Background(), traceID); err != nil {
+			log.Printf("[API] 删除运行 %s 的检查点失败: %v", traceID, err)
+			return true
+		}
+		s.runs.Delete(traceID)
+		return true
+	})
+}
+
+// ListRuns 列出运行记录，按创建时间排序；status / workflow / userID 为空时不过滤
+func (s *WorkflowService) ListRuns(status, workflow, userID string) []*RunRecord {
+	var records []*RunRecord
+	s.runs.Range(func(_, v interface{}) bool {
+		r := v.(*RunRecord)
+		if (status == "" || r.Status == status) && (workflow == "" || r.Request.Workflow == workflow) && (userID == "" || r.Request.UserId == userID) {
+			records = append(records, r)
+		}
+		return true
+	})
+	sort.Slice(records, func(i, j int) bool { return records[i].CreatedAt.Before(records[j].CreatedAt) })
+	return records
+}
+
+// GetRun 获取运行记录及其检查点；成功的运行检查点已删除
+func (s *WorkflowService) GetRun(ctx context.Context, traceID string) (*RunRecord, error) {
+	v, ok := s.runs.Load(traceID)
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	record := *v.(*RunRecord)
+	checkpoints, err := s.checkpoints.LoadCheckpoints(ctx, traceID)
+	if err != nil {
+		return nil, err
+	}
+	record.Checkpoints = checkpoints
+	return &record, nil
+}
+
+// ResumeRun 以原始请求重新执行运行：已保存检查点且输入未变的步骤直接使用保存的输出，
+// 从最后一个成功的步骤之后继续；opts.Force 中的步骤及其之后输入发生变化的步骤重新执行。
+func (s *WorkflowService) ResumeRun(ctx context.Context, traceID string, opts ResumeRequest) (*WorkflowResponse, error) {
+	s.runMu.Lock()
+	v, ok := s.runs.Load(traceID)
+	if !ok {
+		s.runMu.Unlock()
+		return nil, ErrRunNotFound
+	}
+	record := v.(*RunRecord)
+	if record.Status == RunStatusRunning {
+		s.runMu.Unlock()
+		return nil, ErrRunActive
+	}
+	// 标记为执行中，避免并发恢复同一运行
+	running := *record
+	running.Status = RunStatusRunning
+	s.runs.Store(traceID, &running)
+	s.runMu.Unlock()
+
+	req := record.Request
+	req.Version = record.Version
+	if opts.Timeout > 0 {
+		req.Timeout = opts.Timeout
+	}
+	log.Printf("[API] 从检查点恢复工作流 %s@%s，强制重跑: %v，TraceID: %s", req.Workflow, record.Version, opts.Force, traceID)
+	resp, err := s.execute(ctx, req, runOptions{resume: true, force: opts.Force})
+	if err != nil && s.runStillRunning(traceID) {
+		// 运行未开始（如工作流或版本已不存在），恢复原状态
+		s.runs.Store(traceID, record)
+	}
+	return resp, err
+}
+
+// runStillRunning 报告运行记录是否仍为执行中
+func (s *WorkflowService) runStillRunning(traceID string) bool {
+	v, ok := s.runs.Load(traceID)
+	return ok && v.(*RunRecord).Status == RunStatusRunning
+}
+
+// DeleteRun 删除运行记录及其检查点
+func (s *WorkflowService) DeleteRun(ctx context.Context, traceID string) error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	v, ok := s.runs.Load(traceID)
+	if !ok {
+		return ErrRunNotFound
+	}
+	if v.(*RunRecord).Status == RunStatusRunning {
+		return ErrRunActive
+	}
+	if err := s.checkpoints.DeleteCheckpoints(ctx, traceID); err != nil {
+		return err
+	}
+	s.runs.Delete(traceID)
+	return nil
+}
+
+// handleRuns 列出运行记录
+//
+//	GET /api/runs?status=failed&workflow=...&user_id=...  status 为空时返回全部
+func (s *HttpServer) handleRuns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "仅支持 GET 请求", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	runs := s.service.ListRuns(q.Get("status"), q.Get("workflow"), q.Get("user_id"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"runs":  runs,
+		"count": len(runs),
+	})
+}
+
+// handleRun 查看、恢复或删除单个运行
+//
+//	GET    /api/runs/{trace_id}         查看运行记录与检查点
+//	POST   /api/runs/{trace_id}/resume  从检查点恢复 {"force": ["format"], "timeout": 600}，
+//	                                    返回与 /api/execute 相同的响应
+//	DELETE /api/runs/{trace_id}         删除运行记录与检查点
+func (s *HttpServer) handleRun(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path[len("/api/runs/"):], "/")
+	id, action, _ := strings.Cut(path, "/")
+	if id == "" {
+		http.Error(w, "缺少运行ID", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		record, err := s.service.GetRun(r.Context(), id)
+		if err == ErrRunNotFound {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "读取检查点失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(record)
+	case action == "" && r.Method == http.MethodDelete:
+		switch err := s.service.DeleteRun(r.Context(), id); err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case ErrRunNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case ErrRunActive:
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "删除检查点失败", http.StatusInternalServerError)
+		}
+	case action == "resume" && r.Method == http.MethodPost:
+		var opts ResumeRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, "请求格式错误", http.StatusBadRequest)
+				return
+			}
+		}
+		resp, err := s.service.ResumeRun(r.Context(), id, opts)
+		switch err {
+		case nil:
+		case ErrApprovalPending:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusAccepted)
+			json.NewEncoder(w).Encode(resp)
+			return
+		case ErrRunNotFound, ErrWorkflowNotFound, ErrVersionNotFound, ErrAgentNotFound:
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case ErrRunActive:
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		default:
+			http.Error(w, "恢复运行失败", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/flow"
+)
+
+// TestResumeRunFromCheckpoint 验证失败的运行从最后一个成功的步骤恢复，并可强制重跑指定步骤
+func TestResumeRunFromCheckpoint(t *testing.T) {
+	runs := map[string]int{}
+	formatterDown := true
+	step := func(name string, process func(string) (string, error)) *agents.Agent {
+		return agents.NewAgent(agents.WithName(name), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+			runs[name]++
+			return process(msg)
+		}))
+	}
+	root := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+		Name: "novel",
+		SubAgents: []*agents.Agent{
+			step("outline", func(m string) (string, error) { return "大纲: " + m, nil }),
+			step("chapter", func(m string) (string, error) { return "章节 <- " + m, nil }),
+			step("formatter", func(m string) (string, error) {
+				if formatterDown {
+					return "", errors.New("格式化服务不可用")
+				}
+				return "《" + m + "》", nil
+			}),
+		},
+	})
+
+	mgr := flow.NewManager()
+	mgr.Register("novel", &root.Agent)
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+
+	// 格式化步骤失败
+	rec := httptest.NewRecorder()
+	httpSrv.handleExecute(rec, httptest.NewRequest(http.MethodPost, "/api/execute",
+		strings.NewReader(`{"workflow":"novel","input":"龙","user_id":"u1","trace_id":"run-1"}`)))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("期望 500，实际 %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	httpSrv.handleRun(rec, httptest.NewRequest(http.MethodGet, "/api/runs/run-1", nil))
+	var record RunRecord
+	json.Unmarshal(rec.Body.Bytes(), &record)
+	if record.Status != RunStatusFailed || len(record.Checkpoints) != 2 || record.Checkpoints[1].Branch != "novel.chapter" {
+		t.Fatalf("运行记录错误: %s", rec.Body.String())
+	}
+
+	resume := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		httpSrv.handleRun(rec, httptest.NewRequest(http.MethodPost, "/api/runs/run-1/resume", strings.NewReader(body)))
+		return rec
+	}
+
+	// 强制重跑章节步骤；章节输出未变，格式化步骤仍失败
+	if rec = resume(`{"force":["chapter"]}`); rec.Code != http.StatusInternalServerError || runs["outline"] != 1 || runs["chapter"] != 2 || runs["formatter"] != 2 {
+		t.Fatalf("强制重跑错误: %d %s (%v)", rec.Code, rec.Body.String(), runs)
+	}
+
+	// 从失败的格式化步骤继续，之前的步骤不再执行
+	formatterDown = false
+	rec = resume(``)
+	var resp WorkflowResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if rec.Code != http.StatusOK || resp.Output != "《章节 <- 大纲: 龙》" || runs["outline"] != 1 || runs["chapter"] != 2 || runs["formatter"] != 3 {
+		t.Fatalf("恢复运行错误: %d %s (%v)", rec.Code, rec.Body.String(), runs)
+	}
+	// 运行成功后检查点已删除
+	if r, _ := httpSrv.service.GetRun(context.Background(), "run-1"); r.Status != RunStatusSucceeded || r.Attempts != 3 || len(r.Checkpoints) != 0 {
+		t.Errorf("运行记录 = %+v", r)
+	}
+
+	rec = httptest.NewRecorder()
+	httpSrv.handleRun(rec, httptest.NewRequest(http.MethodDelete, "/api/runs/run-1", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("期望 204，实际 %d", rec.Code)
+	}
+	if rec = resume(``); rec.Code != http.StatusNotFound {
+		t.Errorf("期望 404，实际 %d", rec.Code)
+	}
+}
+
+// TestRunRetention 验证超过保留时长的已结束运行记录及其检查点被清理
+func TestRunRetention(t *testing.T) {
+	root := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+		Name: "novel",
+		SubAgents: []*agents.Agent{
+			agents.NewAgent(agents.WithName("outline"), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+				return "大纲: " + msg, nil
+			})),
+			agents.NewAgent(agents.WithName("formatter"), agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+				if msg == "大纲: 坏" {
+					return "", errors.New("格式化失败")
+				}
+				return "《" + msg + "》", nil
+			})),
+		},
+	})
+
+	mgr := flow.NewManager()
+	mgr.Register("novel", &root.Agent)
+	httpSrv := NewHttpServer(mgr, ":0")
+	defer httpSrv.sched.Stop()
+	svc := httpSrv.service
+	svc.SetRunRetention(50 * time.Millisecond)
+
+	ctx := context.Background()
+	if _, err := svc.Execute(ctx, WorkflowRequest{Workflow: "novel", Input: "坏", TraceId: "old"}); err == nil {
+		t.Fatal("期望运行失败")
+	}
+	if checkpoints, _ := svc.checkpoints.LoadCheckpoints(ctx, "old"); len(checkpoints) != 1 {
+		t.Fatalf("失败的运行应保留检查点，实际 %d 个", len(checkpoints))
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, err := svc.Execute(ctx, WorkflowRequest{Workflow: "novel", Input: "龙", TraceId: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.GetRun(ctx, "old"); err != ErrRunNotFound {
+		t.Errorf("过期的运行记录未清理: %v", err)
+	}
+	if checkpoints, _ := svc.checkpoints.LoadCheckpoints(ctx, "old"); len(checkpoints) != 0 {
+		t.Errorf("过期运行的检查点未删除: %d 个", len(checkpoints))
+	}
+	if runs := svc.ListRuns("", "", ""); len(runs) != 1 || runs[0].Request.TraceId != "new" {
+		t.Errorf("运行记录 = %+v", runs)
+	}
+}
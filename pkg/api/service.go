@@ -2,14 +2,20 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/nvcnvn/adk-golang/pkg/agents"
+	"github.com/nvcnvn/adk-golang/pkg/events"
 	"github.com/nvcnvn/adk-golang/pkg/flow"
+	"github.com/nvcnvn/adk-golang/pkg/models"
 	"github.com/nvcnvn/adk-golang/pkg/scheduler"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+	"github.com/nvcnvn/adk-golang/pkg/types"
 )
 
 // 错误常量定义。
@@ -17,15 +23,23 @@ import (
 // ErrWorkflowNotFound 表示指定的工作流不存在。
 // ErrInvalidRequest 表示请求参数不合法。
 // ErrInternalError 表示服务器内部错误。
+// ErrVersionNotFound 表示请求固定的工作流版本不存在。
+// ErrAgentNotFound 表示工作流中不存在请求指定的子 Agent。
+// ErrApprovalPending 表示运行在审批步骤暂停，等待人工决定后恢复。
 var (
 	ErrWorkflowNotFound = errors.New("工作流未找到") // 工作流未找到错误
 	ErrInvalidRequest   = errors.New("无效的请求")   // 无效请求错误
 	ErrInternalError    = errors.New("内部服务错误") // 服务器内部错误
+	ErrVersionNotFound  = errors.New("工作流版本未找到") // 指定的工作流版本不存在
+	ErrAgentNotFound    = errors.New("子 Agent 未找到") // 指定的子 Agent 不存在
+	ErrApprovalPending  = errors.New("等待人工审批") // 运行在审批步骤暂停
 )
 
 // WorkflowRequest 工作流执行请求
 type WorkflowRequest struct {
 	Workflow     string                 `json:"workflow"`                // 工作流名称
+	Version      string                 `json:"version,omitempty"`       // 固定版本（可选），为空时按权重分流
+	Agent        string                 `json:"agent,omitempty"`         // 目标子 Agent（可选），为空时由根 Agent 处理
 	Input        string                 `json:"input"`                   // 输入文本
 	UserId       string                 `json:"user_id"`                 // 用户标识
 	ArchiveId    string                 `json:"archive_id"`              // 归档标识符
@@ -38,12 +52,14 @@ type WorkflowRequest struct {
 // WorkflowResponse 工作流执行结果
 type WorkflowResponse struct {
 	Workflow    string                 `json:"workflow"`           // 工作流名称
+	Version     string                 `json:"version,omitempty"`  // 实际服务的工作流版本
 	Output      string                 `json:"output"`             // 输出文本
 	Success     bool                   `json:"success"`            // 是否成功
 	Message     string                 `json:"message,omitempty"`  // 消息（错误时有值）
 	Metadata    map[string]interface{} `json:"metadata,omitempty"` // 元数据
 	ProcessTime int64                  `json:"process_time_ms"`    // 处理时间（毫秒）
 	TraceId     string                 `json:"trace_id,omitempty"` // 请求追踪ID
+	Approval    *ApprovalRecord        `json:"approval,omitempty"` // 待审批记录（运行暂停时有值）
 }
 
 // StreamCallback 流式回调函数
@@ -54,18 +70,62 @@ type WorkflowService struct {
 	manager *flow.Manager
 	sched   scheduler.Scheduler
 	activeJobs sync.Map // 记录活跃的工作
+	remoteRuns sync.Map // 运行中的远程调用：invocation_id -> *agents.InvocationContext
+
+	sessions   sessions.SessionService // 保存暂停运行的会话
+	approvalMu sync.Mutex              // 保护审批记录的状态变更
+	approvals  sync.Map                // 审批记录：id -> *ApprovalRecord
+
+	checkpoints  agents.CheckpointStore // 保存各步骤输出的检查点，按 trace_id 区分运行
+	runMu        sync.Mutex             // 保护运行记录的状态变更
+	runs         sync.Map               // 运行记录：trace_id -> *RunRecord
+	runRetention time.Duration          // 已结束运行记录的保留时长，<= 0 时不清理
 }
 
 // NewWorkflowService 创建工作流服务
 func NewWorkflowService(manager *flow.Manager, sched scheduler.Scheduler) *WorkflowService {
 	return &WorkflowService{
-		manager: manager,
-		sched:   sched,
+		manager:  manager,
+		sched:    sched,
+		sessions:     sessions.NewInMemorySessionService(),
+		checkpoints:  agents.NewInMemoryCheckpointStore(),
+		runRetention: DefaultRunRetention,
 	}
 }
 
-// Execute 执行工作流（同步）
+// SetSessionService 设置保存暂停运行的会话服务，默认为内存实现
+func (s *WorkflowService) SetSessionService(svc sessions.SessionService) {
+	s.sessions = svc
+}
+
+// SetCheckpointStore 设置保存步骤检查点的存储，默认为内存实现
+func (s *WorkflowService) SetCheckpointStore(store agents.CheckpointStore) {
+	s.checkpoints = store
+}
+
+// SetRunRetention 设置已结束（成功或失败）运行记录的保留时长，超过后删除记录及其检查点；
+// d <= 0 时不清理。默认为 DefaultRunRetention
+func (s *WorkflowService) SetRunRetention(d time.Duration) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	s.runRetention = d
+}
+
+// Execute 执行工作流（同步）。运行在审批步骤暂停时返回 ErrApprovalPending，
+// 响应的 Approval 字段为待审批记录。
 func (s *WorkflowService) Execute(ctx context.Context, req WorkflowRequest) (*WorkflowResponse, error) {
+	return s.execute(ctx, req, runOptions{})
+}
+
+// runOptions 恢复运行的方式
+type runOptions struct {
+	approval *ApprovalRecord // 非空时从待审批记录恢复
+	resume   bool            // 从检查点恢复，跳过已完成的步骤
+	force    []string        // 从检查点恢复时强制重新执行的步骤
+}
+
+// execute 执行工作流，按 opts 从待审批记录或检查点恢复运行
+func (s *WorkflowService) execute(ctx context.Context, req WorkflowRequest, opts runOptions) (*WorkflowResponse, error) {
 	startTime := time.Now()
 	
 	// 确保有 trace_id
@@ -82,25 +142,52 @@ func (s *WorkflowService) Execute(ctx context.Context, req WorkflowRequest) (*Wo
 	timeoutCtx, cancel := context.WithTimeout(ctx, time.Duration(req.Timeout)*time.Second)
 	defer cancel()
 	
-	// 获取工作流
-	// 检查工作流是否存在
-	if _, exists := s.manager.Get(req.Workflow); !exists {
+	// 获取工作流，确定本次请求服务的版本
+	root, version, exists := s.manager.Resolve(req.Workflow, req.Version)
+	if !exists {
+		if req.Version != "" && len(s.manager.ListVersions(req.Workflow)) > 0 {
+			log.Printf("[API] 工作流 %s 版本 %s 未找到", req.Workflow, req.Version)
+			return errorResponse(req.Workflow, "工作流版本未找到", req.TraceId), ErrVersionNotFound
+		}
 		log.Printf("[API] 工作流 %s 未找到", req.Workflow)
 		return errorResponse(req.Workflow, "工作流未找到", req.TraceId), ErrWorkflowNotFound
 	}
-	
+	if req.Agent != "" && flow.FindAgent(root, req.Agent) == nil {
+		log.Printf("[API] 工作流 %s@%s 中未找到子 Agent %s", req.Workflow, version, req.Agent)
+		return errorResponse(req.Workflow, "子 Agent 未找到", req.TraceId), ErrAgentNotFound
+	}
+
+    // 运行事件记录在 InvocationContext 上，暂停时据此保存各步骤的输入；
+    // 各步骤的输出以 trace_id 为运行ID保存检查点，失败后可通过 /api/runs/{trace_id}/resume 恢复
+    s.recordRun(req, version, RunStatusRunning, nil)
+    ic := agents.NewInvocationContext(req.TraceId, root, &types.RunConfig{StreamingMode: types.StreamingModeNone})
+    taskCtx := agents.WithInvocationContext(timeoutCtx, ic)
+    taskCtx = agents.WithCheckpointing(taskCtx, &agents.Checkpointing{
+        Store:  s.checkpoints,
+        RunID:  req.TraceId,
+        Resume: opts.resume,
+        Force:  opts.force,
+    })
+    if opts.approval != nil {
+        taskCtx = agents.WithApprovalResume(taskCtx, opts.approval.resume())
+    }
+
     // 通过调度器提交任务
     resultCh := make(chan scheduler.Result, 1)
     task := &scheduler.Task{
-        Ctx:        timeoutCtx,
+        Ctx:        taskCtx,
         Workflow:   req.Workflow,
+        Version:    version,
+        Agent:      req.Agent,
         Input:      req.Input,
         UserID:     req.UserId,
         ArchiveID:  req.ArchiveId,
+        Params:     req.Parameters,
         ResultChan: resultCh,
     }
 
     if err := s.sched.Submit(task); err != nil {
+        s.recordRun(req, version, RunStatusFailed, err)
         if err == scheduler.ErrQueueFull {
             return errorResponse(req.Workflow, "系统繁忙，请稍后再试", req.TraceId), err
         }
@@ -114,23 +201,55 @@ func (s *WorkflowService) Execute(ctx context.Context, req WorkflowRequest) (*Wo
     case res := <-resultCh:
         output, err = res.Output, res.Err
     case <-timeoutCtx.Done():
-        return errorResponse(req.Workflow, "工作流执行超时", req.TraceId), timeoutCtx.Err()
+        s.manager.RecordResult(req.Workflow, version, timeoutCtx.Err())
+        s.recordRun(req, version, RunStatusFailed, timeoutCtx.Err())
+        resp := errorResponse(req.Workflow, "工作流执行超时", req.TraceId)
+        resp.Version = version
+        return resp, timeoutCtx.Err()
     }
 
-	
+	// 记录版本执行结果，用于版本间错误率对比；等待审批不计为错误
+	if errors.Is(err, agents.ErrApprovalPending) {
+		s.manager.RecordResult(req.Workflow, version, nil)
+		s.recordRun(req, version, RunStatusPaused, nil)
+	} else {
+		s.manager.RecordResult(req.Workflow, version, err)
+		s.recordRun(req, version, runStatus(err), err)
+	}
+
+	// 运行在审批步骤暂停，保存待审批记录
+	var pending *agents.ApprovalPendingError
+	if errors.As(err, &pending) {
+		record, saveErr := s.pause(ctx, req, version, ic, pending.Approval, opts.approval)
+		if saveErr != nil {
+			log.Printf("[API] 工作流 %s@%s 保存待审批记录失败: %v, TraceID: %s", req.Workflow, version, saveErr, req.TraceId)
+			resp := errorResponse(req.Workflow, saveErr.Error(), req.TraceId)
+			resp.Version = version
+			return resp, ErrInternalError
+		}
+		resp := errorResponse(req.Workflow, "等待人工审批", req.TraceId)
+		resp.Version = version
+		resp.Approval = record
+		resp.ProcessTime = time.Since(startTime).Milliseconds()
+		return resp, ErrApprovalPending
+	}
+
 	// 处理执行错误
 	if err != nil {
-		log.Printf("[API] 工作流 %s 执行失败: %v, TraceID: %s", req.Workflow, err, req.TraceId)
-		return errorResponse(req.Workflow, err.Error(), req.TraceId), ErrInternalError
+		log.Printf("[API] 工作流 %s@%s 执行失败: %v, TraceID: %s", req.Workflow, version, err, req.TraceId)
+		resp := errorResponse(req.Workflow, err.Error(), req.TraceId)
+		resp.Version = version
+		return resp, ErrInternalError
 	}
 	
 	// 计算处理时间
 	processTime := time.Since(startTime).Milliseconds()
-	log.Printf("[API] 工作流 %s 执行成功，处理时间: %dms，TraceID: %s", req.Workflow, processTime, req.TraceId)
+	log.Printf("[API] 工作流 %s@%s 执行成功，处理时间: %dms，TraceID: %s", req.Workflow, version, processTime, req.TraceId)
 	
 	// 返回结果
 	return &WorkflowResponse{
 		Workflow:    req.Workflow,
+		Version:     version,
 		Output:      output,
 		Success:     true,
 		ProcessTime: processTime,
@@ -138,12 +257,13 @@ func (s *WorkflowService) Execute(ctx context.Context, req WorkflowRequest) (*Wo
 		Metadata: map[string]interface{}{
 			"user_id":      req.UserId,
 			"workflow":     req.Workflow,
+			"version":      version,
 			"experiment_id": req.ExperimentId,
 		},
 	}, nil
 }
 
-// ExecuteStream 执行工作流（异步流式）
+// ExecuteStream 流式执行工作流，运行结束后返回
 func (s *WorkflowService) ExecuteStream(ctx context.Context, req WorkflowRequest, callback StreamCallback) error {
 	// 确保有 trace_id
 	if req.TraceId == "" {
@@ -151,30 +271,89 @@ func (s *WorkflowService) ExecuteStream(ctx context.Context, req WorkflowRequest
 	}
 
 	// 获取工作流
-	agent, exists := s.manager.Get(req.Workflow)
+	agent, version, exists := s.manager.Resolve(req.Workflow, req.Version)
 	if !exists {
-		callback("", false, ErrWorkflowNotFound)
-		return ErrWorkflowNotFound
+		err := ErrWorkflowNotFound
+		if req.Version != "" && len(s.manager.ListVersions(req.Workflow)) > 0 {
+			err = ErrVersionNotFound
+		}
+		callback("", false, err)
+		return err
+	}
+	if req.Agent != "" {
+		if agent = flow.FindAgent(agent, req.Agent); agent == nil {
+			callback("", false, ErrAgentNotFound)
+			return ErrAgentNotFound
+		}
 	}
 
 	// 执行工作流（异步）
-	log.Printf("[API] 开始流式执行工作流 %s，TraceID: %s", req.Workflow, req.TraceId)
+	log.Printf("[API] 开始流式执行工作流 %s@%s，TraceID: %s", req.Workflow, version, req.TraceId)
 
 	// 注册活跃工作
 	jobID := req.TraceId
 	s.activeJobs.Store(jobID, true)
 	defer s.activeJobs.Delete(jobID)
 
-	// TODO: 实现实际的流式处理
-	// 这里是模拟实现，真正实现需要 Agent 提供流式接口
-	go func() {
-		result, err := agent.Process(ctx, req.Input)
-		if err != nil {
-			callback("", false, err)
-			return
+	// 通过 Agent.Run 执行：运行中的事件（子 Agent 开始/结束、模型调用、工具调用等）以 JSON 逐个推送，
+	// 最终事件的文本作为 done 数据
+	ctx = context.WithValue(ctx, "user_id", req.UserId)
+	ctx = context.WithValue(ctx, "archive_id", req.ArchiveId)
+	ctx = agents.WithTemplateParams(ctx, req.Parameters)
+	ctx = agents.WithCheckpointing(ctx, &agents.Checkpointing{Store: s.checkpoints, RunID: req.TraceId})
+	s.recordRun(req, version, RunStatusRunning, nil)
+	ic := agents.NewInvocationContext(req.TraceId, agent, &types.RunConfig{StreamingMode: types.StreamingModeSSE})
+	ic.InvocationEvent = &events.Event{
+		Author:  agents.UserAuthor,
+		Content: &events.Content{Parts: []*models.Part{{Text: req.Input, Role: "user"}}},
+	}
+	eventCh, err := agent.Run(ctx, ic)
+	if err != nil {
+		s.manager.RecordResult(req.Workflow, version, err)
+		s.recordRun(req, version, RunStatusFailed, err)
+		callback("", false, err)
+		return nil
+	}
+
+	var (
+		runErr error
+		paused bool
+	)
+	for ev := range eventCh {
+		switch ev.Type {
+		case events.FinalResponse:
+			callback(ev.Content.GetText(), true, nil)
+		case events.RunFailed:
+			if ev.ErrorCode == agents.ErrorCodeApprovalPending {
+				// 运行在审批步骤暂停，保存待审批记录后通过 /api/approvals/{id} 恢复
+				if approval := agents.PendingApprovalFromEvents(ic.EventsSnapshot()); approval != nil {
+					record, err := s.pause(ctx, req, version, ic, approval, nil)
+					if err == nil {
+						paused = true
+						callback("", false, fmt.Errorf("%w: %s", ErrApprovalPending, record.ID))
+						continue
+					}
+					runErr = err
+					callback("", false, err)
+					continue
+				}
+			}
+			runErr = errors.New(ev.ErrorMessage)
+			callback("", false, runErr)
+		default:
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			callback(string(data), false, nil)
 		}
-		callback(result, true, nil)
-	}()
+	}
+	s.manager.RecordResult(req.Workflow, version, runErr)
+	if paused {
+		s.recordRun(req, version, RunStatusPaused, nil)
+	} else {
+		s.recordRun(req, version, runStatus(runErr), runErr)
+	}
 
 	return nil
 }
@@ -206,14 +385,65 @@ func (s *WorkflowService) GetWorkflowInfo(name string) (map[string]interface{},
 		"description": agent.Description(),
 		"model":       agent.Model(),
 		"type":        getAgentType(agent),
+		"versions":    s.manager.ListVersions(name),
+		"weights":     s.manager.Weights(name),
 	}
 
 	return info, nil
 }
 
-// 获取 Agent 类型
+// ListVersions 获取工作流全部版本及流量统计
+func (s *WorkflowService) ListVersions(name string) ([]flow.VersionInfo, error) {
+	versions := s.manager.ListVersions(name)
+	if len(versions) == 0 {
+		return nil, ErrWorkflowNotFound
+	}
+	return versions, nil
+}
+
+// SetWeights 更新工作流各版本的流量权重
+func (s *WorkflowService) SetWeights(name string, weights map[string]int) error {
+	if err := s.manager.SetWeights(name, weights); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	log.Printf("[API] 工作流 %s 权重已更新: %v", name, weights)
+	return nil
+}
+
+// Warmup 在会话开始时推测性地执行工作流的预生成阶段，结果供该用户下一次请求使用。
+// 返回值表示工作流是否配置了预生成。
+func (s *WorkflowService) Warmup(req WorkflowRequest) (bool, error) {
+	if _, _, ok := s.manager.Resolve(req.Workflow, req.Version); !ok {
+		return false, ErrWorkflowNotFound
+	}
+	warming := s.manager.Warmup(req.Workflow, req.Version, req.UserId, req.ArchiveId, req.Input)
+	if warming {
+		log.Printf("[API] 工作流 %s 为用户 %s 预热预生成阶段", req.Workflow, req.UserId)
+	}
+	return warming, nil
+}
+
+// ListRoutes 返回当前挂载的工作流自定义路由
+func (s *WorkflowService) ListRoutes() []flow.RouteInfo {
+	return s.manager.Routes()
+}
+
+// MatchRoute 按请求方法与路径查找工作流自定义路由
+func (s *WorkflowService) MatchRoute(method, path string) (flow.RouteInfo, bool) {
+	return s.manager.MatchRoute(method, path)
+}
+
+// HasRoutePath 判断路径是否被任意方法的自定义路由占用，用于区分 404 与 405
+func (s *WorkflowService) HasRoutePath(path string) bool {
+	for _, method := range []string{"GET", "POST"} {
+		if _, ok := s.manager.MatchRoute(method, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 获取 Agent 类型：basic / sequential / parallel / loop
 func getAgentType(agent *agents.Agent) string {
-	// 根据 agent 特性判断类型
-	// 这是一个简化实现
-	return "basic" // 可能为 "sequential", "parallel", "basic" 等
+	return agent.Kind()
 }
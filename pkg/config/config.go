@@ -1,7 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -18,6 +22,45 @@ type ModelPoolConfig struct {
 	Endpoints []EndpointConfig `yaml:"endpoints"` // 端点列表
 }
 
+// 记忆后端类型。
+const (
+	MemoryTypeCustomRag   = "custom_rag"   // FastAPI + Milvus RAG 服务
+	MemoryTypeQuad        = "quad"         // GraphDB 四元组记忆
+	MemoryTypeInMemory    = "in_memory"    // 进程内关键词匹配，仅用于开发测试
+	MemoryTypeLocalVector = "local_vector" // 进程内向量检索，无需外部服务
+)
+
+// MemoryBackendConfig 定义单个命名记忆后端。
+type MemoryBackendConfig struct {
+	Type           string `yaml:"type"`             // custom_rag / quad / in_memory / local_vector
+	URL            string `yaml:"url"`              // custom_rag / quad 服务地址
+	TopK           int    `yaml:"top_k"`            // 检索返回条数
+	TimeoutMs      int    `yaml:"timeout_ms"`       // 单次请求超时
+	MaxRetries     int    `yaml:"max_retries"`      // 网络错误或 5xx 时的最大尝试次数
+	RetryBackoffMs int    `yaml:"retry_backoff_ms"` // 重试退避基数
+	Repository     string `yaml:"repository"`       // quad: GraphDB 仓库 ID
+	Username       string `yaml:"username"`         // quad: 认证用户名
+	Password       string `yaml:"password"`         // quad: 认证密码
+	Dimensions     int    `yaml:"dimensions"`       // local_vector: 向量维度
+}
+
+// MemoryConfig 定义命名记忆后端集合，流程与工具按名称解析后端。
+type MemoryConfig struct {
+	Default  string                         `yaml:"default"` // 未指定名称时使用的后端
+	Backends map[string]MemoryBackendConfig `yaml:"backends"`
+}
+
+// defaultMemoryConfig 为未配置 memory 段时使用的后端，与早期版本的内置地址保持一致。
+func defaultMemoryConfig() MemoryConfig {
+	return MemoryConfig{
+		Default: "custom_rag",
+		Backends: map[string]MemoryBackendConfig{
+			"custom_rag": {Type: MemoryTypeCustomRag, URL: "http://host.docker.internal:18000", TopK: 10},
+			"quad":       {Type: MemoryTypeQuad, URL: "http://host.docker.internal:7200", Repository: "main", MaxRetries: 3, RetryBackoffMs: 1000},
+		},
+	}
+}
+
 // Config 代表全局配置文件结构，与 config.yaml 对齐。
 // 字段保持首字母大写以便 yaml 解码。
 type Config struct {
@@ -38,9 +81,39 @@ type Config struct {
 	
 	// ModelAPIPools 配置多个API端点池，按模型类型分组
 	ModelAPIPools map[string]ModelPoolConfig `yaml:"model_api_pools"`
+
+	// FlowVersions 配置同名工作流各版本的流量权重：flowName -> version -> weight
+	FlowVersions map[string]map[string]int `yaml:"flow_versions"`
+
+	// Memory 配置命名记忆后端，未配置时使用 defaultMemoryConfig
+	Memory MemoryConfig `yaml:"memory"`
+
+	// Remote 配置远程智能体协议（/api/remote/*）
+	Remote RemoteConfig `yaml:"remote"`
+}
+
+// RemoteConfig 配置供其他部署调用的远程智能体协议。
+type RemoteConfig struct {
+	// Tokens 为允许的 Bearer 令牌，为空时远程协议不可用
+	Tokens []string `yaml:"tokens"`
+}
+
+// ValidationError 汇总配置文件中的全部问题。
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("配置校验失败（%d 个问题）:\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
 }
 
 // Load 从 path 读取 yaml，如 path 为空则默认 ./config.yaml。
+//
+// 加载顺序：
+//  1. 解析 yaml，替换标量值中的 ${VAR} / ${VAR:-default}，变量未设置时可由 VAR_FILE 指向的文件提供；
+//  2. 填补默认值；
+//  3. 以 ADK_* 环境变量覆盖字段（见 applyEnvOverrides），同样支持 _FILE 后缀；
+//  4. 校验整个配置，全部问题以 *ValidationError 一并返回。
 func Load(path string) (*Config, error) {
 	if path == "" {
 		path = "./config.yaml"
@@ -49,10 +122,29 @@ func Load(path string) (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	return Parse(data)
+}
+
+// Parse 按 Load 的规则解析配置内容。
+func Parse(data []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
 		return nil, err
 	}
+	problems := expandEnv(&doc)
+
+	var cfg Config
+	if doc.Kind != 0 {
+		if err := doc.Decode(&cfg); err != nil {
+			return nil, err
+		}
+	}
+	// 默认记忆后端需在环境变量覆盖前填入，以便 ADK_MEMORY_BACKENDS_* 可覆盖其地址
+	if len(cfg.Memory.Backends) == 0 {
+		cfg.Memory = defaultMemoryConfig()
+	}
+	problems = append(problems, applyEnvOverrides(&cfg)...)
+
 	// 填补默认值
 	if cfg.PluginDir == "" {
 		cfg.PluginDir = "./plugins"
@@ -61,6 +153,154 @@ func Load(path string) (*Config, error) {
 		cfg.LogLevel = "info"
 	}
 	// 默认非开发模式
-	
+
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
 	return &cfg, nil
 }
+
+// 已支持的枚举取值。
+var (
+	validLogLevels   = []string{"debug", "info", "warn", "error"}
+	validQueueImpls  = []string{"memory", "redis", "nats"}
+	validMemoryTypes = []string{MemoryTypeCustomRag, MemoryTypeQuad, MemoryTypeInMemory, MemoryTypeLocalVector}
+)
+
+// Validate 校验配置，返回 *ValidationError 汇总全部问题，无问题时返回 nil。
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.LogLevel != "" && !contains(validLogLevels, c.LogLevel) {
+		add("log_level %q 无效，应为 %s 之一", c.LogLevel, strings.Join(validLogLevels, "/"))
+	}
+
+	if c.Queue.Impl != "" {
+		if !contains(validQueueImpls, c.Queue.Impl) {
+			add("queue.impl %q 无效，应为 %s 之一", c.Queue.Impl, strings.Join(validQueueImpls, "/"))
+		} else if c.Queue.Impl != "memory" && c.Queue.Addr == "" {
+			add("queue.impl 为 %s 时 queue.addr 不能为空", c.Queue.Impl)
+		}
+	}
+
+	for _, name := range sortedKeys(c.ModelAPIPools) {
+		pool := c.ModelAPIPools[name]
+		prefix := "model_api_pools." + name
+		if pool.Base == "" {
+			add("%s.base 不能为空", prefix)
+		}
+		if len(pool.Endpoints) == 0 {
+			add("%s.endpoints 至少需要一个端点", prefix)
+		}
+		for i, ep := range pool.Endpoints {
+			if ep.URL == "" {
+				add("%s.endpoints[%d].url 不能为空", prefix, i)
+				continue
+			}
+			if u, err := url.Parse(ep.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("%s.endpoints[%d].url %q 不是合法的 http(s) 地址", prefix, i, ep.URL)
+			}
+		}
+	}
+
+	if c.Memory.Default != "" {
+		if _, ok := c.Memory.Backends[c.Memory.Default]; !ok {
+			add("memory.default %q 未在 memory.backends 中定义", c.Memory.Default)
+		}
+	}
+	for name, b := range c.Memory.Backends {
+		prefix := "memory.backends." + name
+		if !contains(validMemoryTypes, b.Type) {
+			add("%s.type %q 无效，应为 %s 之一", prefix, b.Type, strings.Join(validMemoryTypes, "/"))
+		} else if (b.Type == MemoryTypeCustomRag || b.Type == MemoryTypeQuad) && b.URL == "" {
+			add("%s.url 不能为空", prefix)
+		}
+		if b.URL != "" {
+			if u, err := url.Parse(b.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("%s.url %q 不是合法的 http(s) 地址", prefix, b.URL)
+			}
+		}
+		if b.TopK < 0 || b.TimeoutMs < 0 || b.MaxRetries < 0 || b.RetryBackoffMs < 0 || b.Dimensions < 0 {
+			add("%s 的 top_k / timeout_ms / max_retries / retry_backoff_ms / dimensions 不能为负数", prefix)
+		}
+	}
+
+	for flowName, weights := range c.FlowVersions {
+		for version, w := range weights {
+			if w < 0 {
+				add("flow_versions.%s.%s 权重不能为负数: %d", flowName, version, w)
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return &ValidationError{Problems: problems}
+}
+
+// Redacted 返回隐去密钥、远程令牌与数据库连接串的配置副本，供日志与管理接口展示。
+func (c *Config) Redacted() *Config {
+	out := *c
+	if out.DB.DSN != "" {
+		out.DB.DSN = redactedValue
+	}
+	if c.ModelAPIPools != nil {
+		out.ModelAPIPools = make(map[string]ModelPoolConfig, len(c.ModelAPIPools))
+		for name, pool := range c.ModelAPIPools {
+			eps := make([]EndpointConfig, len(pool.Endpoints))
+			for i, ep := range pool.Endpoints {
+				if ep.APIKey != "" {
+					ep.APIKey = redactedValue
+				}
+				eps[i] = ep
+			}
+			pool.Endpoints = eps
+			out.ModelAPIPools[name] = pool
+		}
+	}
+	if c.Memory.Backends != nil {
+		out.Memory.Backends = make(map[string]MemoryBackendConfig, len(c.Memory.Backends))
+		for name, b := range c.Memory.Backends {
+			if b.Password != "" {
+				b.Password = redactedValue
+			}
+			out.Memory.Backends[name] = b
+		}
+	}
+	if c.Remote.Tokens != nil {
+		out.Remote.Tokens = make([]string, len(c.Remote.Tokens))
+		for i := range c.Remote.Tokens {
+			out.Remote.Tokens[i] = redactedValue
+		}
+	}
+	return &out
+}
+
+// redactedValue 为 Redacted 中替换敏感字段的占位符。
+const redactedValue = "******"
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(pools map[string]ModelPoolConfig) []string {
+	keys := make([]string, 0, len(pools))
+	for k := range pools {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
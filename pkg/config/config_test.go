@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvAndOverrides(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(secret, []byte("sk-from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_REDIS_ADDR", "redis://cache:6379")
+	t.Setenv("TEST_SECRET", "p#ss: word\nlog_level: error")
+	t.Setenv("TEST_WEIGHT", "90")
+	t.Setenv("TEST_POOL_KEY_FILE", secret)
+	t.Setenv("ADK_LOG_LEVEL", "debug")
+	t.Setenv("ADK_LOG_DEV", "true")
+	t.Setenv("ADK_MODEL_API_POOLS_GLM_POOL_ENDPOINTS_0_URL", "https://override.example.com")
+	t.Setenv("ADK_FLOW_VERSIONS_NOVEL_V2", "30")
+
+	cfg, err := Parse([]byte(`
+queue:
+  impl: redis
+  addr: ${TEST_REDIS_ADDR}
+  stream: ${TEST_STREAM:-adk_tasks} # 注释中的 ${TEST_UNSET} 不替换
+model_api_pools:
+  glm_pool:
+    base: glm
+    endpoints:
+      - url: http://localhost:3000
+        apikey: ${TEST_POOL_KEY}
+      - url: http://localhost:3001
+        apikey: ${TEST_SECRET}
+flow_versions:
+  novel:
+    v1: ${TEST_WEIGHT}
+    v2: 10
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := cfg.ModelAPIPools["glm_pool"].Endpoints[0]
+	switch {
+	case cfg.Queue.Addr != "redis://cache:6379", cfg.Queue.Stream != "adk_tasks":
+		t.Fatalf("插值错误: %+v", cfg.Queue)
+	case cfg.ModelAPIPools["glm_pool"].Endpoints[1].APIKey != "p#ss: word\nlog_level: error", cfg.FlowVersions["novel"]["v1"] != 90:
+		t.Fatalf("变量值不应改变文档结构: %+v %v", cfg.ModelAPIPools["glm_pool"].Endpoints[1], cfg.FlowVersions)
+	case ep.APIKey != "sk-from-file", ep.URL != "https://override.example.com":
+		t.Fatalf("端点错误: %+v", ep)
+	case cfg.LogLevel != "debug", !cfg.LogDev, cfg.FlowVersions["novel"]["v2"] != 30:
+		t.Fatalf("环境变量覆盖错误: %+v", cfg)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg, err := Parse([]byte(`
+db:
+  dsn: postgres://adk:secret@db/adk
+model_api_pools:
+  glm_pool:
+    base: glm
+    endpoints:
+      - url: http://localhost:3000
+        apikey: sk-secret
+memory:
+  backends:
+    graph:
+      type: quad
+      url: http://graphdb:7200
+      password: graph-secret
+remote:
+  tokens: [token-a, token-b]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := cfg.Redacted()
+	switch {
+	case out.DB.DSN != redactedValue:
+		t.Errorf("DSN 未隐去: %q", out.DB.DSN)
+	case out.ModelAPIPools["glm_pool"].Endpoints[0].APIKey != redactedValue:
+		t.Errorf("APIKey 未隐去: %+v", out.ModelAPIPools["glm_pool"].Endpoints[0])
+	case out.Memory.Backends["graph"].Password != redactedValue:
+		t.Errorf("Password 未隐去: %+v", out.Memory.Backends["graph"])
+	case len(out.Remote.Tokens) != 2 || out.Remote.Tokens[0] != redactedValue || out.Remote.Tokens[1] != redactedValue:
+		t.Errorf("远程令牌未隐去: %v", out.Remote.Tokens)
+	}
+	// 原配置不受影响
+	if cfg.Remote.Tokens[0] != "token-a" || cfg.ModelAPIPools["glm_pool"].Endpoints[0].APIKey != "sk-secret" {
+		t.Errorf("Redacted 修改了原配置: %+v", cfg)
+	}
+}
+
+func TestParseReportsAllProblems(t *testing.T) {
+	_, err := Parse([]byte(`
+log_level: verbose
+queue:
+  impl: kafka
+model_api_pools:
+  empty_pool:
+    base: deepseek
+  bad_pool:
+    endpoints:
+      - url: localhost:3000
+        apikey: ${TEST_MISSING_KEY}
+`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 *ValidationError，实际: %v", err)
+	}
+	want := []string{"TEST_MISSING_KEY", "log_level", "queue.impl", "empty_pool.endpoints", "bad_pool.base", "bad_pool.endpoints[0].url"}
+	if len(verr.Problems) != len(want) {
+		t.Fatalf("问题数量错误: %v", verr.Problems)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("缺少问题 %q: %v", w, err)
+		}
+	}
+}
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("log_level: info\n")
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReloader(path, cfg)
+	var seen []string
+	r.OnReload("log", func(oldCfg, newCfg *Config) ([]string, error) {
+		seen = append(seen, oldCfg.LogLevel+"->"+newCfg.LogLevel)
+		return []string{"level " + newCfg.LogLevel}, nil
+	})
+
+	// 校验失败时保留旧配置
+	write("log_level: loud\n")
+	if st := r.Reload(ReloadTriggerManual); st.Success || !strings.Contains(st.Error, "log_level") {
+		t.Fatalf("期望校验失败: %+v", st)
+	}
+	if r.Current().LogLevel != "info" || len(seen) != 0 {
+		t.Fatal("失败的重载不应替换配置或通知处理函数")
+	}
+
+	write("log_level: debug\n")
+	st := r.Reload(ReloadTriggerManual)
+	if !st.Success || len(st.Changes) != 1 || st.Changes[0] != "log: level debug" {
+		t.Fatalf("重载结果错误: %+v", st)
+	}
+	if r.Current().LogLevel != "debug" || seen[0] != "info->debug" {
+		t.Fatalf("配置未更新: %v", seen)
+	}
+	if h := r.History(); len(h) != 2 || !h[0].Success {
+		t.Fatalf("重载记录错误: %+v", h)
+	}
+}
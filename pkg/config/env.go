@@ -0,0 +1,184 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 为环境变量覆盖配置项时使用的前缀。
+const EnvPrefix = "ADK_"
+
+// envRef 匹配 ${VAR} 与 ${VAR:-default}。
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// lookupEnv 读取环境变量；未设置时若存在 NAME_FILE，则读取该文件内容（去除首尾空白）。
+// 适用于 Docker / Kubernetes secret 挂载文件的场景。
+func lookupEnv(name string) (string, bool, error) {
+	if v, ok := os.LookupEnv(name); ok {
+		return v, true, nil
+	}
+	if path, ok := os.LookupEnv(name + "_FILE"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("读取 %s_FILE 指向的文件失败: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	return "", false, nil
+}
+
+// expandEnv 替换 yaml 文档中标量值里的 ${VAR} 与 ${VAR:-default}。
+// 替换在解析之后进行，变量值中的 #、": "、换行等只作为值的内容，不会改变文档结构；
+// 整个标量未加引号时按替换后的文本重新推断类型，因此 ${PORT} 仍可填入数值字段。
+// 未设置且没有默认值的变量作为问题返回，不会静默替换为空字符串；
+// 需要允许为空时请写 ${VAR:-}。注释不做替换。
+func expandEnv(node *yaml.Node) []string {
+	var problems []string
+	expandNode(node, &problems)
+	return problems
+}
+
+func expandNode(n *yaml.Node, problems *[]string) {
+	if n.Kind == yaml.ScalarNode {
+		expandScalar(n, problems)
+	}
+	// 别名指向的节点已在文档中出现，不重复处理
+	for _, c := range n.Content {
+		expandNode(c, problems)
+	}
+}
+
+func expandScalar(n *yaml.Node, problems *[]string) {
+	replaced := false
+	value := envRef.ReplaceAllStringFunc(n.Value, func(ref string) string {
+		replaced = true
+		m := envRef.FindStringSubmatch(ref)
+		name, hasDefault, def := m[1], m[2] != "", m[3]
+		v, ok, err := lookupEnv(name)
+		if err != nil {
+			*problems = append(*problems, fmt.Sprintf("第 %d 行: %v", n.Line, err))
+			return ""
+		}
+		if ok && (v != "" || !hasDefault) {
+			return v
+		}
+		if hasDefault {
+			return def
+		}
+		*problems = append(*problems, fmt.Sprintf("第 %d 行: 环境变量 %s 未设置且没有默认值", n.Line, name))
+		return ""
+	})
+	if !replaced {
+		return
+	}
+	n.Value = value
+	if n.Style == 0 {
+		n.Tag = ""
+	}
+}
+
+// applyEnvOverrides 以 ADK_* 环境变量覆盖配置字段。
+//
+// 变量名由 yaml 标签路径转为大写、以下划线连接得到，例如：
+//
+//	log_level                          -> ADK_LOG_LEVEL
+//	queue.addr                         -> ADK_QUEUE_ADDR
+//	model_api_pools.glm_pool.endpoints[0].apikey -> ADK_MODEL_API_POOLS_GLM_POOL_ENDPOINTS_0_APIKEY
+//	flow_versions.novel_flow.v2        -> ADK_FLOW_VERSIONS_NOVEL_FLOW_V2
+//
+// map 与切片只能覆盖配置文件中已存在的键和下标。每个变量同样支持 _FILE 间接读取。
+func applyEnvOverrides(cfg *Config) []string {
+	var problems []string
+	overrideValue(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"), &problems)
+	return problems
+}
+
+func overrideValue(v reflect.Value, name string, problems *[]string) {
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			tag := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if tag == "" || tag == "-" || !f.IsExported() {
+				continue
+			}
+			overrideValue(v.Field(i), name+"_"+envKey(tag), problems)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			// map 元素不可寻址，复制后修改再写回
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			overrideValue(elem, name+"_"+envKey(k.String()), problems)
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			overrideValue(v.Index(i), name+"_"+strconv.Itoa(i), problems)
+		}
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Float64:
+		raw, ok, err := lookupEnv(name)
+		if err != nil {
+			*problems = append(*problems, err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+		if err := setScalar(v, raw); err != nil {
+			*problems = append(*problems, fmt.Sprintf("环境变量 %s: %v", name, err))
+		}
+	}
+}
+
+func setScalar(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("无法解析为布尔值: %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("无法解析为整数: %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("无法解析为数字: %q", raw)
+		}
+		v.SetFloat(f)
+	}
+	return nil
+}
+
+// envKey 将 yaml 键转为环境变量片段：大写，非字母数字字符替换为下划线。
+func envKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// 重载触发来源。
+const (
+	ReloadTriggerFile   = "file"   // 配置文件变化
+	ReloadTriggerManual = "manual" // 管理接口或代码主动触发
+)
+
+// reloadDebounce 为文件事件去抖时长，编辑器保存时常产生多次写入。
+const reloadDebounce = 300 * time.Millisecond
+
+// reloadHistoryLimit 为保留的重载记录条数。
+const reloadHistoryLimit = 20
+
+// ReloadHandler 在配置重新加载成功后被调用，返回本次应用的变更描述。
+// 返回错误不会阻止其他处理函数执行，也不会回滚新配置。
+type ReloadHandler func(oldCfg, newCfg *Config) ([]string, error)
+
+// ReloadStatus 记录一次重载的结果。
+type ReloadStatus struct {
+	Time    time.Time `json:"time"`
+	Trigger string    `json:"trigger"`
+	Success bool      `json:"success"`
+	Changes []string  `json:"changes,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type namedHandler struct {
+	name    string
+	handler ReloadHandler
+}
+
+// Reloader 监听配置文件，变化后重新加载并通知各处理函数（模型池、日志级别等）。
+// 加载或校验失败时保留当前配置，失败原因记录在 History 中。
+type Reloader struct {
+	path string
+
+	mu       sync.RWMutex
+	current  *Config
+	checksum []byte
+	handlers []namedHandler
+	history  []ReloadStatus
+
+	reloadMu sync.Mutex // 串行化重载
+	watcher  *fsnotify.Watcher
+	timer    *time.Timer
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReloader 创建 Reloader，cfg 为启动时已加载的配置。
+func NewReloader(path string, cfg *Config) *Reloader {
+	r := &Reloader{path: path, current: cfg, done: make(chan struct{})}
+	if data, err := os.ReadFile(path); err == nil {
+		sum := sha256.Sum256(data)
+		r.checksum = sum[:]
+	}
+	return r
+}
+
+// OnReload 注册重载处理函数，按注册顺序调用。name 用于标注变更来源。
+func (r *Reloader) OnReload(name string, h ReloadHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers = append(r.handlers, namedHandler{name: name, handler: h})
+}
+
+// Current 返回当前生效的配置。
+func (r *Reloader) Current() *Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// History 返回最近的重载记录，最新的在前。
+func (r *Reloader) History() []ReloadStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ReloadStatus, len(r.history))
+	for i, st := range r.history {
+		out[len(r.history)-1-i] = st
+	}
+	return out
+}
+
+// Reload 立即重新加载配置文件并通知处理函数。
+func (r *Reloader) Reload(trigger string) ReloadStatus {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	st := ReloadStatus{Time: time.Now(), Trigger: trigger}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		st.Error = err.Error()
+		return r.record(st)
+	}
+	sum := sha256.Sum256(data)
+
+	r.mu.RLock()
+	unchanged := bytes.Equal(sum[:], r.checksum)
+	r.mu.RUnlock()
+	if unchanged && trigger == ReloadTriggerFile {
+		// 内容未变化（如 touch 或编辑器重复保存），不记录
+		return ReloadStatus{Time: st.Time, Trigger: trigger, Success: true}
+	}
+
+	newCfg, err := Parse(data)
+	if err != nil {
+		st.Error = err.Error()
+		return r.record(st)
+	}
+
+	r.mu.Lock()
+	oldCfg := r.current
+	r.current = newCfg
+	r.checksum = sum[:]
+	handlers := append([]namedHandler(nil), r.handlers...)
+	r.mu.Unlock()
+
+	var errs []error
+	for _, h := range handlers {
+		changes, err := h.handler(oldCfg, newCfg)
+		for _, c := range changes {
+			st.Changes = append(st.Changes, h.name+": "+c)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		st.Error = err.Error()
+	} else {
+		st.Success = true
+	}
+	return r.record(st)
+}
+
+// record 保存并输出重载结果。
+func (r *Reloader) record(st ReloadStatus) ReloadStatus {
+	switch {
+	case !st.Success:
+		log.Printf("[config] 重载 %s 失败（%s）: %s", r.path, st.Trigger, st.Error)
+	case len(st.Changes) == 0:
+		log.Printf("[config] 重载 %s 完成（%s），无生效变更", r.path, st.Trigger)
+	default:
+		log.Printf("[config] 重载 %s 完成（%s）: %v", r.path, st.Trigger, st.Changes)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.history = append(r.history, st)
+	if len(r.history) > reloadHistoryLimit {
+		r.history = r.history[len(r.history)-reloadHistoryLimit:]
+	}
+	return st
+}
+
+// Start 开始监听配置文件所在目录。监听目录而非文件本身，
+// 以兼容编辑器「写临时文件再 rename」与 Kubernetes ConfigMap 的符号链接替换。
+func (r *Reloader) Start() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(filepath.Dir(r.path)); err != nil {
+		w.Close()
+		return err
+	}
+	r.watcher = w
+	target := filepath.Clean(r.path)
+	go func() {
+		for {
+			select {
+			case <-r.done:
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) == target && ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					r.schedule()
+				} else if filepath.Base(ev.Name) == "..data" {
+					// Kubernetes ConfigMap 通过替换 ..data 符号链接更新
+					r.schedule()
+				}
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("[config] 监听配置文件出错: %v", err)
+			}
+		}
+	}()
+	log.Printf("[config] 开始监听配置文件 %s", r.path)
+	return nil
+}
+
+// schedule 去抖后触发重载。
+func (r *Reloader) schedule() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Reset(reloadDebounce)
+		return
+	}
+	r.timer = time.AfterFunc(reloadDebounce, func() {
+		select {
+		case <-r.done:
+			return
+		default:
+		}
+		r.Reload(ReloadTriggerFile)
+	})
+}
+
+// Stop 停止监听，可重复调用。
+func (r *Reloader) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+		r.mu.Lock()
+		if r.timer != nil {
+			r.timer.Stop()
+		}
+		r.mu.Unlock()
+		if r.watcher != nil {
+			r.watcher.Close()
+		}
+	})
+}
@@ -25,11 +25,39 @@ import (
 // Content is a convenience alias to models.Content to avoid importing models everywhere
 type Content = models.Content
 
+// Event types of the events emitted by an agent run, see Event.Type. Tool
+// calls and results use ToolCalled and ToolResultReceived.
+const (
+	AgentStarted  EventType = "agent_started"
+	AgentFinished EventType = "agent_finished"
+	ModelCalled   EventType = "model_called"
+	PartialText   EventType = "partial_text"
+	StateUpdated  EventType = "state_updated"
+	RunFailed     EventType = "run_failed"
+	FinalResponse EventType = "final_response"
+)
+
+// ModelCall describes the model call of a ModelCalled event.
+type ModelCall struct {
+	// Model is the name of the model that was called
+	Model string `json:"model"`
+	// Latency is how long the call took
+	Latency time.Duration `json:"latency"`
+	// Usage is the token usage, nil when the model does not report it
+	Usage *models.Usage `json:"usage,omitempty"`
+}
+
 // Event represents an event in the agent system
 type Event struct {
 	// ID is a unique identifier for this event
 	ID string `json:"id"`
 
+	// Type classifies the event, empty for events not emitted by an agent run
+	Type EventType `json:"type,omitempty"`
+
+	// Timestamp is when the event was created
+	Timestamp time.Time `json:"timestamp"`
+
 	// InvocationID links this event to an invocation
 	InvocationID string `json:"invocationId,omitempty"`
 
@@ -59,13 +87,17 @@ type Event struct {
 
 	// Actions contains actions associated with this event
 	Actions *EventActions `json:"actions,omitempty"`
+
+	// ModelCall is set on ModelCalled events
+	ModelCall *ModelCall `json:"modelCall,omitempty"`
 }
 
 // NewEvent creates a new event with a unique ID
 func NewEvent() *Event {
 	return &Event{
-		ID:      uuid.New().String(),
-		Actions: NewEventActions(),
+		ID:        uuid.New().String(),
+		Timestamp: time.Now(),
+		Actions:   NewEventActions(),
 	}
 }
 
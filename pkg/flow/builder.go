@@ -0,0 +1,162 @@
+package flow
+
+// builder.go 根据 FlowConfig 构造 Agent 树，用于无需编译的配置化工作流。
+//
+//   leaf       -> agents.NewAgent
+//   sequential -> agents.NewSequentialAgent
+//   parallel   -> agents.NewParallelAgent（Workers 控制并发）
+//   router     -> agents.NewRouterAgent（由 Model 根据子 Agent 的描述选择并转交）
+//   approval   -> agents.NewApprovalAgent（Instruction 作为审批提示，运行在此暂停等待人工决定）
+//   graph      -> agents.NewGraphAgent（子节点按 DependsOn 组成有向无环图，Input 为节点输入模板）
+//
+// 多个顶层 Agent 时以工作流名称创建串行根节点。
+// 启用 pre_generate 时，预生成 Agent 不参与串行编排，根节点为先执行预生成再执行主流程的包装节点。
+
+import (
+    "encoding/json"
+    "fmt"
+    "os"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+// LoadFlowConfig 读取并校验 FlowConfig JSON 文件。
+func LoadFlowConfig(path string) (*FlowConfig, error) {
+    data, err := os.ReadFile(path)
+    if err != nil {
+        return nil, err
+    }
+    var fc FlowConfig
+    if err := json.Unmarshal(data, &fc); err != nil {
+        return nil, fmt.Errorf("解析工作流配置失败: %w", err)
+    }
+    if err := fc.Validate(); err != nil {
+        return nil, fmt.Errorf("工作流配置校验失败: %w", err)
+    }
+    return &fc, nil
+}
+
+// BuildFromConfig 根据 FlowConfig 构造根 Agent。
+func BuildFromConfig(fc *FlowConfig) (*agents.Agent, error) {
+    if err := fc.Validate(); err != nil {
+        return nil, err
+    }
+    var (
+        roots []*agents.Agent
+        pre   *agents.Agent
+    )
+    for _, ac := range fc.Agents {
+        a, err := buildAgentFromConfig(ac)
+        if err != nil {
+            return nil, err
+        }
+        if fc.PreGenerate.Enabled && ac.ID == fc.PreGenerate.Agent {
+            pre = a
+            continue
+        }
+        roots = append(roots, a)
+    }
+
+    var main *agents.Agent
+    if len(roots) == 1 {
+        main = roots[0]
+    } else {
+        name := fc.Name
+        if pre != nil {
+            // 根节点名称留给预生成包装节点
+            name = fc.Name + "_main"
+        }
+        seq := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+            Name:      name,
+            SubAgents: roots,
+        })
+        main = &seq.Agent
+    }
+    if pre == nil {
+        return main, nil
+    }
+
+    pg, err := NewPreGenerator(fc.PreGenerate, pre)
+    if err != nil {
+        return nil, err
+    }
+    return pg.Wrap(fc.Name, main), nil
+}
+
+// buildAgentFromConfig 递归构造单个 Agent。
+// 组合 Agent 的编排逻辑绑定在其内嵌 Agent 上，返回的 *agents.Agent 可直接 Process。
+func buildAgentFromConfig(ac AgentConfig) (*agents.Agent, error) {
+    subs := make([]*agents.Agent, 0, len(ac.SubAgents))
+    for _, sc := range ac.SubAgents {
+        a, err := buildAgentFromConfig(sc)
+        if err != nil {
+            return nil, err
+        }
+        subs = append(subs, a)
+    }
+
+    switch ac.Type {
+    case "leaf":
+        opts := []agents.Option{
+            agents.WithName(ac.ID),
+            agents.WithInstruction(ac.Instruction),
+            agents.WithDescription(ac.Description),
+        }
+        if ac.Model != "" {
+            opts = append(opts, agents.WithModel(ac.Model))
+        }
+        return agents.NewAgent(opts...), nil
+    case "sequential":
+        seq := agents.NewSequentialAgent(agents.SequentialAgentConfig{
+            Name:        ac.ID,
+            Description: ac.Description,
+            SubAgents:   subs,
+        })
+        return &seq.Agent, nil
+    case "parallel":
+        par := agents.NewParallelAgent(agents.ParallelAgentConfig{
+            Name:        ac.ID,
+            Description: ac.Description,
+            SubAgents:   subs,
+            Workers:     ac.Workers,
+        })
+        return &par.Agent, nil
+    case "router":
+        router := agents.NewRouterAgent(agents.RouterAgentConfig{
+            Name:        ac.ID,
+            Description: ac.Description,
+            Model:       ac.Model,
+            Instruction: ac.Instruction,
+            SubAgents:   subs,
+        })
+        return &router.Agent, nil
+    case "graph":
+        nodes := make([]agents.GraphNode, len(subs))
+        for i, sub := range subs {
+            nodes[i] = agents.GraphNode{
+                Agent:     sub,
+                DependsOn: ac.SubAgents[i].DependsOn,
+                Input:     ac.SubAgents[i].Input,
+            }
+        }
+        graph, err := agents.NewGraphAgent(agents.GraphAgentConfig{
+            Name:        ac.ID,
+            Description: ac.Description,
+            Nodes:       nodes,
+            Workers:     ac.Workers,
+        })
+        if err != nil {
+            return nil, err
+        }
+        return &graph.Agent, nil
+    case "approval":
+        approval := agents.NewApprovalAgent(agents.ApprovalAgentConfig{
+            Name:        ac.ID,
+            Description: ac.Description,
+            Kind:        agents.ApprovalKind(ac.ApprovalKind),
+            Prompt:      ac.Instruction,
+        })
+        return &approval.Agent, nil
+    }
+    return nil, fmt.Errorf("agent %s 的类型 %q 无效", ac.ID, ac.Type)
+}
@@ -1,11 +1,17 @@
 package flow
 
 // 配置结构体定义，直接映射 <flow_name>.json。
-// 使用 go-playground/validator 进行字段校验，但此处仅给出结构体与 Validate() 骨架，
-// 具体实现后续补充。
+// validate tag 仅作文档用途，实际校验由 Validate() 完成。
+// 插件目录中的 .json 文件会被 Loader 解析为 FlowConfig，经 BuildFromConfig 构造为工作流。
 
 import (
+    "errors"
+    "fmt"
+    "strings"
     "time"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+    "github.com/nvcnvn/adk-golang/pkg/schema"
 )
 
 // QueueConfig 定义消息队列配置（Redis / NATS 等）。
@@ -15,31 +21,73 @@ type QueueConfig struct {
     MaxLen int64  `json:"max_len,omitempty"`         // 流最大长度，超过后自动裁剪
 }
 
-// PreGenerateConfig 定义预生成阶段。
+// PreGenerateConfig 定义预生成阶段，在主流程之前执行指定的顶层 Agent，见 pregenerate.go。
 type PreGenerateConfig struct {
-    Enabled   bool   `json:"enabled"`
-    Agent     string `json:"agent,omitempty"`
-    TimeoutMs int    `json:"timeout_ms,omitempty"`
+    Enabled      bool   `json:"enabled"`
+    Agent        string `json:"agent,omitempty"`          // 顶层 agent id，不参与主流程编排
+    TimeoutMs    int    `json:"timeout_ms,omitempty"`     // 默认 10000
+    Fallback     string `json:"fallback,omitempty"`       // 失败 / 超时处理：continue（默认）/ fail
+    PrependInput bool   `json:"prepend_input,omitempty"`  // 将预生成输出拼接到主流程输入之前
+    CacheTTLMs   int    `json:"cache_ttl_ms,omitempty"`   // 预热结果有效期，默认 300000
 }
 
 // AgentConfig 定义单个 Agent（既可以是叶子，也可以是容器）的运行参数，
 // 通过 SubAgents 递归描述层级结构，以支持 cmd/adk/main.go 中的分层智能体配置。
 type AgentConfig struct {
     ID           string                 `json:"id" validate:"required"`                                           // agent 唯一标识
-    Type         string                 `json:"type" validate:"required,oneof=sequential parallel leaf"`         // leaf 表示无子节点
-    Model        string                 `json:"model,omitempty"`                                                  // 叶子 agent 指定模型
-    Instruction  string                 `json:"instruction,omitempty"`                                            // Prompt 指令
+    Type         string                 `json:"type" validate:"required,oneof=sequential parallel router approval graph leaf"`  // leaf 表示无子节点
+    Model        string                 `json:"model,omitempty"`                                                  // 叶子 / router agent 指定模型
+    Instruction  string                 `json:"instruction,omitempty"`                                            // Prompt 指令，支持 {key} 等占位符
     Description  string                 `json:"description,omitempty"`
-    Workers      int                    `json:"workers,omitempty"`                                                // parallel agent 专用
+    Workers      int                    `json:"workers,omitempty"`                                                // parallel / graph agent 专用
+    ApprovalKind string                 `json:"approval_kind,omitempty"`                                          // approval agent 专用：approve（默认）/ input
+    DependsOn    []string               `json:"depends_on,omitempty"`                                             // graph 节点专用：依赖的兄弟节点 id
+    Input        string                 `json:"input,omitempty"`                                                  // graph 节点专用：输入模板，{id} 为依赖的输出，{input} 为图的输入
     StreamOutput bool                   `json:"stream_output,omitempty"`
     Params       map[string]interface{} `json:"params,omitempty"`
     SubAgents    []AgentConfig          `json:"sub_agents,omitempty" validate:"omitempty,dive"`                   // 子 Agent 列表
 }
 
 // RouteConfig 将 HTTP/gRPC 路径映射到 Agent。
+// Agent 为工作流 Agent 树中任意节点的名称，请求直接由该子 Agent 处理。
 type RouteConfig struct {
-    Path  string `json:"path" validate:"required"`
-    Agent string `json:"agent" validate:"required"`
+    Path        string         `json:"path" validate:"required"`
+    Agent       string         `json:"agent" validate:"required"`
+    Method      string         `json:"method,omitempty"`      // GET / POST，默认 POST
+    Description string         `json:"description,omitempty"`
+    Request     *schema.Schema `json:"request,omitempty"`     // 请求体（GET 为查询参数）的 JSON Schema
+}
+
+// reservedRoutePrefixes 为内置接口占用的路径，工作流路由不能使用。
+var reservedRoutePrefixes = []string{"/api/", "/health"}
+
+// HTTPMethod 返回规范化后的请求方法。
+func (rc RouteConfig) HTTPMethod() string {
+    if rc.Method == "" {
+        return "POST"
+    }
+    return strings.ToUpper(rc.Method)
+}
+
+// Validate 校验路由路径与方法。
+func (rc RouteConfig) Validate() error {
+    if !strings.HasPrefix(rc.Path, "/") || rc.Path == "/" {
+        return fmt.Errorf("路由路径必须以 / 开头且不能为根路径: %q", rc.Path)
+    }
+    for _, prefix := range reservedRoutePrefixes {
+        if strings.HasPrefix(rc.Path, prefix) {
+            return fmt.Errorf("路由路径 %s 与内置接口 %s 冲突", rc.Path, prefix)
+        }
+    }
+    if rc.Agent == "" {
+        return fmt.Errorf("路由 %s 未指定 agent", rc.Path)
+    }
+    switch rc.HTTPMethod() {
+    case "GET", "POST":
+    default:
+        return fmt.Errorf("路由 %s 不支持的方法 %s", rc.Path, rc.Method)
+    }
+    return nil
 }
 
 // StorageConfig 定义持久化数据库连接信息（Gorm 方言）。
@@ -60,8 +108,114 @@ type FlowConfig struct {
     UpdatedAt time.Time `json:"-"` // 热更新时间戳，程序内使用
 }
 
-// Validate 对 FlowConfig 进行字段校验；后续补充 validator 逻辑。
+// Validate 对 FlowConfig 进行字段校验，返回全部问题。
+// Queue / Storage 由宿主统一配置，此处不强制要求。
 func (fc *FlowConfig) Validate() error {
-    // TODO: 引入 validator 并实现校验逻辑
-    return nil
+    var errs []error
+    if fc.Name == "" {
+        errs = append(errs, errors.New("name 不能为空"))
+    }
+    if len(fc.Agents) == 0 {
+        errs = append(errs, errors.New("agents 至少包含一个 Agent"))
+    }
+    ids := make(map[string]bool)
+    var walk func(ac AgentConfig, parentType string)
+    walk = func(ac AgentConfig, parentType string) {
+        if ac.ID == "" {
+            errs = append(errs, errors.New("agent id 不能为空"))
+        } else if ids[ac.ID] {
+            errs = append(errs, fmt.Errorf("agent id 重复: %s", ac.ID))
+        }
+        ids[ac.ID] = true
+        if err := agents.ValidateInstruction(ac.Instruction); err != nil {
+            errs = append(errs, fmt.Errorf("agent %s 的 instruction 无效: %w", ac.ID, err))
+        }
+        if parentType != "graph" && (len(ac.DependsOn) > 0 || ac.Input != "") {
+            errs = append(errs, fmt.Errorf("agent %s 的 depends_on / input 仅用于 graph agent 的子节点", ac.ID))
+        }
+        switch ac.Type {
+        case "leaf", "approval":
+            if len(ac.SubAgents) > 0 {
+                errs = append(errs, fmt.Errorf("%s agent %s 不能包含 sub_agents", ac.Type, ac.ID))
+            }
+            switch agents.ApprovalKind(ac.ApprovalKind) {
+            case "", agents.ApprovalKindApprove, agents.ApprovalKindInput:
+            default:
+                errs = append(errs, fmt.Errorf("agent %s 的 approval_kind %q 无效，应为 approve/input", ac.ID, ac.ApprovalKind))
+            }
+        case "sequential", "parallel", "router", "graph":
+            if len(ac.SubAgents) == 0 {
+                errs = append(errs, fmt.Errorf("%s agent %s 至少需要一个子 Agent", ac.Type, ac.ID))
+            }
+        default:
+            errs = append(errs, fmt.Errorf("agent %s 的类型 %q 无效，应为 sequential/parallel/router/approval/graph/leaf", ac.ID, ac.Type))
+        }
+        if ac.Type == "graph" {
+            // 依赖只能指向同一 graph 的节点，环路在构造时由 agents.NewGraphAgent 检测
+            siblings := make(map[string]bool, len(ac.SubAgents))
+            for _, sub := range ac.SubAgents {
+                siblings[sub.ID] = true
+            }
+            for _, sub := range ac.SubAgents {
+                for _, dep := range sub.DependsOn {
+                    if !siblings[dep] {
+                        errs = append(errs, fmt.Errorf("graph agent %s 的节点 %s 依赖的 %s 不是同一 graph 的节点", ac.ID, sub.ID, dep))
+                    }
+                }
+            }
+        }
+        for _, sub := range ac.SubAgents {
+            walk(sub, ac.Type)
+        }
+    }
+    for _, ac := range fc.Agents {
+        walk(ac, "")
+    }
+    // pre_generate.agent 即使未启用也校验，避免写错的 id 被静默忽略
+    if pg := fc.PreGenerate; pg.Enabled || pg.Agent != "" {
+        top := false
+        for _, ac := range fc.Agents {
+            top = top || ac.ID == pg.Agent
+        }
+        switch {
+        case pg.Agent == "":
+            errs = append(errs, errors.New("启用 pre_generate 时 pre_generate.agent 不能为空"))
+        case !ids[pg.Agent]:
+            errs = append(errs, fmt.Errorf("pre_generate.agent %q 不存在", pg.Agent))
+        case !top:
+            errs = append(errs, fmt.Errorf("pre_generate.agent %q 是嵌套 agent，必须为顶层 agent id", pg.Agent))
+        case pg.Enabled && len(fc.Agents) < 2:
+            errs = append(errs, errors.New("启用 pre_generate 时除预生成 Agent 外至少还需一个顶层 Agent"))
+        }
+        switch pg.Fallback {
+        case "", PreGenerateFallbackContinue, PreGenerateFallbackFail:
+        default:
+            errs = append(errs, fmt.Errorf("pre_generate.fallback %q 无效，应为 continue/fail", pg.Fallback))
+        }
+        if pg.TimeoutMs < 0 || pg.CacheTTLMs < 0 {
+            errs = append(errs, errors.New("pre_generate 的 timeout_ms / cache_ttl_ms 不能为负数"))
+        }
+    }
+    if fc.rootName() == fc.Name && ids[fc.Name] {
+        errs = append(errs, fmt.Errorf("工作流会以名称 %s 创建根节点，不能与 agent id 重名", fc.Name))
+    }
+    for _, rc := range fc.Routes {
+        if err := rc.Validate(); err != nil {
+            errs = append(errs, err)
+            continue
+        }
+        if rc.Agent != fc.rootName() && !ids[rc.Agent] {
+            errs = append(errs, fmt.Errorf("路由 %s 指向不存在的 agent %s", rc.Path, rc.Agent))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// rootName 返回构造后根 Agent 的名称：未启用预生成且仅有一个顶层 Agent 时为其 ID，
+// 否则为工作流名称。
+func (fc *FlowConfig) rootName() string {
+    if len(fc.Agents) == 1 && !fc.PreGenerate.Enabled {
+        return fc.Agents[0].ID
+    }
+    return fc.Name
 }
@@ -0,0 +1,231 @@
+package flow
+
+// host.go 定义宿主向插件提供的服务。
+//
+// 插件不应自行创建带硬编码地址的记忆、会话等服务，而应通过 Host 复用宿主按配置
+// 初始化好的实例。Loader 在构建插件时按以下顺序注入 Host：
+//
+//   1. 插件导出 `Init func(flow.Host) error` 符号时，在 Build 之前调用；
+//   2. Plugin 实现 HostedFlowPlugin 时，以 BuildWithHost(host) 代替 Build()。
+//
+// 旧的 `SetLogger func(*zap.Logger)` 符号仍然支持。
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "sync"
+
+    "go.uber.org/zap"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+    "github.com/nvcnvn/adk-golang/pkg/artifacts"
+    "github.com/nvcnvn/adk-golang/pkg/config"
+    "github.com/nvcnvn/adk-golang/pkg/logger"
+    "github.com/nvcnvn/adk-golang/pkg/memory"
+    "github.com/nvcnvn/adk-golang/pkg/models"
+    "github.com/nvcnvn/adk-golang/pkg/sessions"
+    "github.com/nvcnvn/adk-golang/pkg/telemetry"
+)
+
+// ErrServiceNotFound 表示宿主未注册所请求的服务。
+var ErrServiceNotFound = errors.New("host service not found")
+
+// Host 为宿主向插件暴露的服务集合。name 为空时返回该类服务的默认实例。
+type Host interface {
+    Config() *config.Config                                  // 全局配置（只读）
+    Logger() *zap.Logger                                     // 统一日志
+    Model(name string) (models.Model, error)                 // 按名称解析模型，支持 pool:xxx 等模式
+    Memory(name string) (memory.MemoryService, error)        // 命名记忆服务
+    Session(name string) (sessions.SessionService, error)    // 命名会话服务
+    Artifact(name string) (artifacts.ArtifactService, error) // 命名产物服务
+    Service(name string) (interface{}, error)                // 其他命名服务，如 quad 记忆
+    Metrics() *telemetry.MetricsRegistry                     // 指标注册表
+}
+
+// HostedFlowPlugin 为可选接口，Loader 会以 BuildWithHost 代替 Build 构造工作流。
+type HostedFlowPlugin interface {
+    FlowPlugin
+    BuildWithHost(host Host) (*agents.Agent, error)
+}
+
+// 服务类别。
+const (
+    serviceKindMemory   = "memory"
+    serviceKindSession  = "session"
+    serviceKindArtifact = "artifact"
+    serviceKindOther    = "service"
+)
+
+// ServiceHost 为 Host 的默认实现，服务由宿主启动时注册。
+// 每类服务中第一个注册的实例作为默认实例，可用 SetDefault 调整。
+type ServiceHost struct {
+    cfg     *config.Config
+    metrics *telemetry.MetricsRegistry
+
+    mu       sync.RWMutex
+    services map[string]map[string]interface{} // kind -> name -> service
+    defaults map[string]string                 // kind -> 默认服务名
+}
+
+// NewHost 创建 ServiceHost，cfg 可为 nil。
+func NewHost(cfg *config.Config) *ServiceHost {
+    if cfg == nil {
+        cfg = &config.Config{}
+    }
+    return &ServiceHost{
+        cfg:      cfg,
+        metrics:  telemetry.DefaultMetrics(),
+        services: make(map[string]map[string]interface{}),
+        defaults: make(map[string]string),
+    }
+}
+
+// Config 返回全局配置。
+func (h *ServiceHost) Config() *config.Config {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    return h.cfg
+}
+
+// SetConfig 替换全局配置。
+func (h *ServiceHost) SetConfig(cfg *config.Config) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.cfg = cfg
+}
+
+// Logger 返回当前全局 logger。
+func (h *ServiceHost) Logger() *zap.Logger {
+    return logger.L()
+}
+
+// Model 通过模型注册表解析模型。
+func (h *ServiceHost) Model(name string) (models.Model, error) {
+    return models.Lookup(name)
+}
+
+// Metrics 返回指标注册表。
+func (h *ServiceHost) Metrics() *telemetry.MetricsRegistry {
+    return h.metrics
+}
+
+// RegisterMemory 注册命名记忆服务。
+func (h *ServiceHost) RegisterMemory(name string, svc memory.MemoryService) {
+    h.register(serviceKindMemory, name, svc)
+}
+
+// Memory 返回命名记忆服务。
+func (h *ServiceHost) Memory(name string) (memory.MemoryService, error) {
+    svc, err := h.lookup(serviceKindMemory, name)
+    if err != nil {
+        return nil, err
+    }
+    return svc.(memory.MemoryService), nil
+}
+
+// RegisterSession 注册命名会话服务。
+func (h *ServiceHost) RegisterSession(name string, svc sessions.SessionService) {
+    h.register(serviceKindSession, name, svc)
+}
+
+// Session 返回命名会话服务。
+func (h *ServiceHost) Session(name string) (sessions.SessionService, error) {
+    svc, err := h.lookup(serviceKindSession, name)
+    if err != nil {
+        return nil, err
+    }
+    return svc.(sessions.SessionService), nil
+}
+
+// RegisterArtifact 注册命名产物服务。
+func (h *ServiceHost) RegisterArtifact(name string, svc artifacts.ArtifactService) {
+    h.register(serviceKindArtifact, name, svc)
+}
+
+// Artifact 返回命名产物服务。
+func (h *ServiceHost) Artifact(name string) (artifacts.ArtifactService, error) {
+    svc, err := h.lookup(serviceKindArtifact, name)
+    if err != nil {
+        return nil, err
+    }
+    return svc.(artifacts.ArtifactService), nil
+}
+
+// RegisterService 注册其他命名服务。
+func (h *ServiceHost) RegisterService(name string, svc interface{}) {
+    h.register(serviceKindOther, name, svc)
+}
+
+// Service 返回其他命名服务，调用方自行断言类型。
+func (h *ServiceHost) Service(name string) (interface{}, error) {
+    return h.lookup(serviceKindOther, name)
+}
+
+// SetDefault 指定某类服务（memory/session/artifact/service）的默认实例。
+func (h *ServiceHost) SetDefault(kind, name string) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    h.defaults[kind] = name
+}
+
+// Describe 返回各类已注册服务的名称，供日志与管理接口使用。
+func (h *ServiceHost) Describe() map[string][]string {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    out := make(map[string][]string, len(h.services))
+    for kind, items := range h.services {
+        names := make([]string, 0, len(items))
+        for name := range items {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+        out[kind] = names
+    }
+    return out
+}
+
+func (h *ServiceHost) register(kind, name string, svc interface{}) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    items, ok := h.services[kind]
+    if !ok {
+        items = make(map[string]interface{})
+        h.services[kind] = items
+    }
+    items[name] = svc
+    if h.defaults[kind] == "" {
+        h.defaults[kind] = name
+    }
+}
+
+func (h *ServiceHost) lookup(kind, name string) (interface{}, error) {
+    h.mu.RLock()
+    defer h.mu.RUnlock()
+    if name == "" {
+        name = h.defaults[kind]
+    }
+    svc, ok := h.services[kind][name]
+    if !ok {
+        return nil, fmt.Errorf("%w: %s %q", ErrServiceNotFound, kind, name)
+    }
+    return svc, nil
+}
+
+// buildPlugin 按插件实现的接口选择 BuildWithHost 或 Build，并将 panic 转为错误。
+func buildPlugin(fp FlowPlugin, host Host) (agent *agents.Agent, err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("Build() panic: %v", r)
+        }
+    }()
+    if hp, ok := fp.(HostedFlowPlugin); ok && host != nil {
+        agent, err = hp.BuildWithHost(host)
+    } else {
+        agent, err = fp.Build()
+    }
+    if err == nil && agent == nil {
+        err = fmt.Errorf("Build() 返回空 Agent")
+    }
+    return agent, err
+}
@@ -0,0 +1,54 @@
+package flow
+
+import (
+    "errors"
+    "testing"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+    "github.com/nvcnvn/adk-golang/pkg/memory"
+)
+
+// hostedPlugin 通过 BuildWithHost 读取宿主服务
+type hostedPlugin struct {
+    got memory.MemoryService
+}
+
+func (p *hostedPlugin) Name() string { return "hosted" }
+func (p *hostedPlugin) Build() (*agents.Agent, error) {
+    return nil, errors.New("应调用 BuildWithHost")
+}
+func (p *hostedPlugin) BuildWithHost(host Host) (*agents.Agent, error) {
+    mem, err := host.Memory("")
+    if err != nil {
+        return nil, err
+    }
+    p.got = mem
+    return agents.NewAgent(agents.WithName("hosted")), nil
+}
+
+func TestServiceHost(t *testing.T) {
+    host := NewHost(nil)
+    rag := memory.NewCustomRagMemoryService("http://rag.test", 3)
+    host.RegisterMemory("custom_rag", rag)
+    host.RegisterService("quad", "quad-service")
+
+    if _, err := host.Memory("missing"); !errors.Is(err, ErrServiceNotFound) {
+        t.Fatalf("期望 ErrServiceNotFound，实际 %v", err)
+    }
+    if svc, err := host.Service("quad"); err != nil || svc != "quad-service" {
+        t.Fatalf("命名服务查询错误: %v, %v", svc, err)
+    }
+
+    p := &hostedPlugin{}
+    if _, err := buildPlugin(p, host); err != nil {
+        t.Fatalf("BuildWithHost 失败: %v", err)
+    }
+    if p.got != rag {
+        t.Fatal("默认记忆服务应为第一个注册的实例")
+    }
+
+    host.Metrics().Counter("hosted.requests").Inc()
+    if n := host.Metrics().Snapshot().Counters["hosted.requests"]; n < 1 {
+        t.Fatalf("指标未记录: %d", n)
+    }
+}
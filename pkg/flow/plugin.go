@@ -4,52 +4,384 @@ package flow
 // 插件编译示例：
 //   go build -buildmode=plugin -o novel_flow_v1.so ./flows/novel
 // 运行期由 PluginLoader 动态加载 .so，实现热更新。
+//
+// 同名工作流可同时存在多个版本（插件实现 VersionedFlowPlugin 声明版本号），
+// Manager 按配置权重在版本间分流，实现灰度发布；请求也可显式指定版本。
 
 import (
+    "errors"
+    "fmt"
+    "math/rand"
+    "sort"
+    "strings"
     "sync"
+    "time"
 
     "github.com/google/uuid"
 
     "github.com/nvcnvn/adk-golang/pkg/agents"
 )
 
+// DefaultVersion 为未声明版本号的工作流使用的版本名。
+const DefaultVersion = "default"
+
 // FlowPlugin 插件需实现两个方法。
 type FlowPlugin interface {
     Name() string                    // flow 名称 (唯一)
     Build() (*agents.Agent, error)   // 构造顶层 Agent
 }
 
+// VersionedFlowPlugin 为可选接口，插件实现后以 Version() 作为版本号注册，
+// 否则注册为 DefaultVersion。
+type VersionedFlowPlugin interface {
+    FlowPlugin
+    Version() string
+}
+
+// VersionInfo 描述某个工作流版本的注册状态、流量权重与执行统计。
+type VersionInfo struct {
+    Version      string    `json:"version"`
+    Weight       int       `json:"weight"`        // 配置的流量权重
+    Active       bool      `json:"active"`        // 是否会被自动分流选中
+    Requests     int64     `json:"requests"`      // 已服务请求数
+    Errors       int64     `json:"errors"`        // 执行失败数
+    RegisteredAt time.Time `json:"registered_at"`
+}
+
+// RoutedFlowPlugin 为可选接口，插件通过 Routes() 声明自定义 HTTP 路由。
+type RoutedFlowPlugin interface {
+    FlowPlugin
+    Routes() []RouteConfig
+}
+
+// RouteInfo 为已挂载的工作流路由。
+type RouteInfo struct {
+    Flow    string `json:"flow"`
+    Version string `json:"version"` // 声明该路由的版本，路由请求固定在该版本执行
+    RouteConfig
+}
+
+// flowVersion 为单个版本的运行期记录。
+type flowVersion struct {
+    agent        *agents.Agent
+    routes       []RouteConfig
+    registeredAt time.Time
+    requests     int64
+    errors       int64
+}
+
+// flowEntry 维护同名工作流的全部版本。
+type flowEntry struct {
+    versions map[string]*flowVersion
+    latest   string // 最近注册的版本，未配置权重时承接全部流量
+}
+
 // Manager 维护所有已加载的工作流。
 type Manager struct {
-    mu    sync.RWMutex
-    flows map[string]*agents.Agent
+    mu      sync.RWMutex
+    flows   map[string]*flowEntry
+    weights map[string]map[string]int // flowName -> version -> weight
+
+    // 路由表在注册与注销时重建，MatchRoute 直接查表
+    routes     []RouteInfo
+    routeIndex map[string]RouteInfo // "METHOD path" -> RouteInfo
 }
 
 // NewManager 创建 Manager。
 func NewManager() *Manager {
-    return &Manager{flows: make(map[string]*agents.Agent)}
+    return &Manager{
+        flows:   make(map[string]*flowEntry),
+        weights: make(map[string]map[string]int),
+    }
 }
 
-// Register 添加或替换工作流。
+// Register 以 DefaultVersion 添加或替换工作流。
 func (m *Manager) Register(name string, agent *agents.Agent) {
+    m.RegisterVersion(name, DefaultVersion, agent)
+}
+
+// RegisterVersion 添加或替换工作流的指定版本，routes 为该版本声明的 HTTP 路由。
+// 路由随版本注册即挂载、随注销即卸载；调用方应先用 ValidateRoutes 校验。
+func (m *Manager) RegisterVersion(name, version string, agent *agents.Agent, routes ...RouteConfig) {
+    if version == "" {
+        version = DefaultVersion
+    }
     m.mu.Lock()
     defer m.mu.Unlock()
-    m.flows[name] = agent
+    e, ok := m.flows[name]
+    if !ok {
+        e = &flowEntry{versions: make(map[string]*flowVersion)}
+        m.flows[name] = e
+    }
+    if old, ok := e.versions[version]; ok && old.agent != agent {
+        forgetPreGenerator(old.agent)
+    }
+    e.versions[version] = &flowVersion{agent: agent, routes: routes, registeredAt: time.Now()}
+    e.latest = version
+    m.rebuildRoutes()
 }
 
-// Unregister 删除工作流。
+// Unregister 删除工作流的全部版本。
 func (m *Manager) Unregister(name string) {
     m.mu.Lock()
     defer m.mu.Unlock()
+    if e, ok := m.flows[name]; ok {
+        for _, v := range e.versions {
+            forgetPreGenerator(v.agent)
+        }
+    }
     delete(m.flows, name)
+    m.rebuildRoutes()
+}
+
+// UnregisterVersion 删除工作流的指定版本，最后一个版本删除后工作流随之移除。
+func (m *Manager) UnregisterVersion(name, version string) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    e, ok := m.flows[name]
+    if !ok {
+        return
+    }
+    if v, ok := e.versions[version]; ok {
+        forgetPreGenerator(v.agent)
+    }
+    delete(e.versions, version)
+    if len(e.versions) == 0 {
+        delete(m.flows, name)
+    } else if e.latest == version {
+        e.latest = newestVersion(e)
+    }
+    m.rebuildRoutes()
 }
 
-// Get 查询工作流。
+// Get 查询工作流，多版本时按权重选择。
 func (m *Manager) Get(name string) (*agents.Agent, bool) {
+    a, _, ok := m.Resolve(name, "")
+    return a, ok
+}
+
+// Resolve 查询工作流并返回实际选中的版本。
+// version 非空时精确匹配该版本；为空时按权重随机选择，未配置权重则使用最近注册的版本。
+func (m *Manager) Resolve(name, version string) (*agents.Agent, string, bool) {
     m.mu.RLock()
     defer m.mu.RUnlock()
-    a, ok := m.flows[name]
-    return a, ok
+    e, ok := m.flows[name]
+    if !ok {
+        return nil, "", false
+    }
+    if version == "" {
+        version = m.pickVersion(name, e)
+    }
+    v, ok := e.versions[version]
+    if !ok {
+        return nil, "", false
+    }
+    return v.agent, version, true
+}
+
+// pickVersion 在已注册版本中按权重抽样，调用方需持有读锁。
+func (m *Manager) pickVersion(name string, e *flowEntry) string {
+    weights := m.weights[name]
+    total := 0
+    for v := range e.versions {
+        if w := weights[v]; w > 0 {
+            total += w
+        }
+    }
+    if total == 0 {
+        return e.latest
+    }
+    // 固定顺序遍历，保证相同随机数落在相同版本
+    versions := sortedVersions(e)
+    n := rand.Intn(total)
+    for _, v := range versions {
+        w := weights[v]
+        if w <= 0 {
+            continue
+        }
+        if n < w {
+            return v
+        }
+        n -= w
+    }
+    return e.latest
+}
+
+// SetWeights 设置工作流各版本的流量权重，可在版本加载之前配置。
+// 传入空 map 清除权重，恢复为最近注册版本承接全部流量。
+func (m *Manager) SetWeights(name string, weights map[string]int) error {
+    copied := make(map[string]int, len(weights))
+    for v, w := range weights {
+        if w < 0 {
+            return fmt.Errorf("版本 %s 的权重不能为负数: %d", v, w)
+        }
+        copied[v] = w
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    if len(copied) == 0 {
+        delete(m.weights, name)
+        return nil
+    }
+    m.weights[name] = copied
+    return nil
+}
+
+// Weights 返回工作流当前的权重配置副本。
+func (m *Manager) Weights(name string) map[string]int {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    out := make(map[string]int, len(m.weights[name]))
+    for v, w := range m.weights[name] {
+        out[v] = w
+    }
+    return out
+}
+
+// ListVersions 返回工作流全部已注册版本的信息，按版本名排序。
+func (m *Manager) ListVersions(name string) []VersionInfo {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    e, ok := m.flows[name]
+    if !ok {
+        return nil
+    }
+    weights := m.weights[name]
+    weighted := false
+    for v := range e.versions {
+        if weights[v] > 0 {
+            weighted = true
+            break
+        }
+    }
+    infos := make([]VersionInfo, 0, len(e.versions))
+    for _, v := range sortedVersions(e) {
+        fv := e.versions[v]
+        active := weights[v] > 0
+        if !weighted {
+            active = v == e.latest
+        }
+        infos = append(infos, VersionInfo{
+            Version:      v,
+            Weight:       weights[v],
+            Active:       active,
+            Requests:     fv.requests,
+            Errors:       fv.errors,
+            RegisteredAt: fv.registeredAt,
+        })
+    }
+    return infos
+}
+
+// RecordResult 记录某版本的一次执行结果，供版本间对比错误率。
+func (m *Manager) RecordResult(name, version string, err error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    e, ok := m.flows[name]
+    if !ok {
+        return
+    }
+    v, ok := e.versions[version]
+    if !ok {
+        return
+    }
+    v.requests++
+    if err != nil {
+        v.errors++
+    }
+}
+
+// Warmup 为用户推测性地执行工作流的预生成阶段，结果在下一次请求时使用。
+// 工作流未配置预生成时返回 false。
+func (m *Manager) Warmup(name, version, userID, archiveID, input string) bool {
+    agent, _, ok := m.Resolve(name, version)
+    if !ok {
+        return false
+    }
+    pg, ok := LookupPreGenerator(agent)
+    if !ok {
+        return false
+    }
+    pg.Warmup(userID, archiveID, input)
+    return true
+}
+
+// Routes 返回当前挂载的全部路由，按路径排序。
+// 同一方法与路径被多个工作流声明时，按工作流名称排序取第一个；
+// 同一工作流的多个版本声明的路由取并集，优先由最近注册的版本承接。
+func (m *Manager) Routes() []RouteInfo {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return append([]RouteInfo(nil), m.routes...)
+}
+
+// MatchRoute 按方法与路径查找路由，路径末尾的 / 被忽略。
+func (m *Manager) MatchRoute(method, path string) (RouteInfo, bool) {
+    if len(path) > 1 {
+        path = strings.TrimRight(path, "/")
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    ri, ok := m.routeIndex[strings.ToUpper(method)+" "+path]
+    return ri, ok
+}
+
+// rebuildRoutes 重建路由表，调用方需持有写锁。
+func (m *Manager) rebuildRoutes() {
+    names := make([]string, 0, len(m.flows))
+    for n := range m.flows {
+        names = append(names, n)
+    }
+    sort.Strings(names)
+
+    index := make(map[string]RouteInfo)
+    var out []RouteInfo
+    for _, n := range names {
+        e := m.flows[n]
+        versions := append([]string{e.latest}, sortedVersions(e)...)
+        for _, v := range versions {
+            for _, rc := range e.versions[v].routes {
+                key := rc.HTTPMethod() + " " + rc.Path
+                if _, seen := index[key]; seen {
+                    continue
+                }
+                ri := RouteInfo{Flow: n, Version: v, RouteConfig: rc}
+                index[key] = ri
+                out = append(out, ri)
+            }
+        }
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Path != out[j].Path {
+            return out[i].Path < out[j].Path
+        }
+        return out[i].HTTPMethod() < out[j].HTTPMethod()
+    })
+    m.routes, m.routeIndex = out, index
+}
+
+// ValidateRoutes 校验路由配置，并确认路由指向的 Agent 存在于 agent 树中。
+func ValidateRoutes(agent *agents.Agent, routes []RouteConfig) error {
+    var errs []error
+    for _, rc := range routes {
+        if err := rc.Validate(); err != nil {
+            errs = append(errs, err)
+            continue
+        }
+        if FindAgent(agent, rc.Agent) == nil {
+            errs = append(errs, fmt.Errorf("路由 %s 指向的 agent %s 不存在", rc.Path, rc.Agent))
+        }
+    }
+    return errors.Join(errs...)
+}
+
+// FindAgent 在 root 的 Agent 树中按名称查找 Agent，未找到返回 nil。
+func FindAgent(root *agents.Agent, name string) *agents.Agent {
+    if root == nil {
+        return nil
+    }
+    found, _ := root.FindAgent(name).(*agents.Agent)
+    return found
 }
 
 // ListNames 返回已加载工作流名称列表。
@@ -63,6 +395,46 @@ func (m *Manager) ListNames() []string {
     return names
 }
 
+// sortedVersions 返回按名称排序的版本列表。
+func sortedVersions(e *flowEntry) []string {
+    versions := make([]string, 0, len(e.versions))
+    for v := range e.versions {
+        versions = append(versions, v)
+    }
+    sort.Strings(versions)
+    return versions
+}
+
+// newestVersion 返回注册时间最晚的版本。
+func newestVersion(e *flowEntry) string {
+    var (
+        newest string
+        at     time.Time
+    )
+    for v, fv := range e.versions {
+        if newest == "" || fv.registeredAt.After(at) {
+            newest, at = v, fv.registeredAt
+        }
+    }
+    return newest
+}
+
+// pluginVersion 返回插件声明的版本号，未声明时为 DefaultVersion。
+func pluginVersion(fp FlowPlugin) string {
+    if vp, ok := fp.(VersionedFlowPlugin); ok && vp.Version() != "" {
+        return vp.Version()
+    }
+    return DefaultVersion
+}
+
+// pluginRoutes 返回插件声明的路由，未实现 RoutedFlowPlugin 时为 nil。
+func pluginRoutes(fp FlowPlugin) []RouteConfig {
+    if rp, ok := fp.(RoutedFlowPlugin); ok {
+        return rp.Routes()
+    }
+    return nil
+}
+
 // TraceID 生成简单 trace id，供日志使用。
 func TraceID() string {
     return uuid.NewString()
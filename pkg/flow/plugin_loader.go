@@ -2,116 +2,596 @@ package flow
 
 // plugin_loader.go 负责监听插件目录，动态加载 / 卸载工作流。
 // 依赖 Go 原生 plugin 包及 fsnotify 文件系统事件。
+// 支持三种插件：.so（Go 原生插件，进程内）、.flow（可执行文件，进程外，见 subprocess.go）
+// 与 .json（FlowConfig 配置化工作流，见 builder.go）。
+// 插件声明的路由（RoutedFlowPlugin / DescribeResult.Routes / FlowConfig.Routes）随工作流
+// 一同注册到 Manager，由 HTTP 服务动态挂载；路由校验失败视为加载失败。
+//
+// 文件事件按路径去抖：复制过程中的连续 Write 只会在文件静止 debounce 时长后触发一次加载；
+// 隐藏文件与常见临时文件（.tmp/.part/~ 等）被忽略，因此「先写临时文件再 rename」
+// 的发布方式只会加载最终文件。内容未变化（sha256 相同）时跳过重新加载。
+// 新插件构建成功后才替换旧版本，失败时旧版本继续服务，失败原因可通过 Status 查询。
+//
+// 被替换（superseded）的文件在替换它的文件删除后重新加载，继续提供该 flow@version。
+//
+// 注意：Go 原生插件同一路径只能打开一次，发布新版本时请使用新的文件名
+// （如 novel_flow_v2.so），或改用进程外插件。原地覆盖已打开的 .so 会被标记为 failed
+// 并提示需要重启，旧代码继续服务。
 
 import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
     "log"
+    "os"
     "path/filepath"
     "plugin"
+    "sort"
     "strings"
     "sync"
-    "io/fs"
+    "time"
 
     "github.com/fsnotify/fsnotify"
+    "github.com/nvcnvn/adk-golang/pkg/agents"
     "github.com/nvcnvn/adk-golang/pkg/logger"
     "go.uber.org/zap"
 )
 
+// DefaultDebounce 为文件事件的默认去抖时长。
+const DefaultDebounce = 500 * time.Millisecond
+
+// 插件文件后缀。
+const (
+    soPluginExt         = ".so"
+    subprocessPluginExt = ".flow"
+    configPluginExt     = ".json"
+)
+
+// 插件种类。
+const (
+    PluginKindSO         = "so"
+    PluginKindSubprocess = "subprocess"
+    PluginKindConfig     = "config"
+)
+
+// PluginState 为插件文件的加载状态。
+type PluginState string
+
+const (
+    PluginLoaded     PluginState = "loaded"     // 已加载并提供服务
+    PluginFailed     PluginState = "failed"     // 最近一次加载失败，见 Error
+    PluginSuperseded PluginState = "superseded" // 同名同版本已被其他文件替换
+    PluginUnloaded   PluginState = "unloaded"   // 文件已删除，工作流已注销
+)
+
+// PluginStatus 描述单个插件文件的状态。
+type PluginStatus struct {
+    Path      string      `json:"path"`
+    Kind      string      `json:"kind"`
+    State     PluginState `json:"state"`
+    Flow      string      `json:"flow,omitempty"`
+    Version   string      `json:"version,omitempty"`
+    Checksum  string      `json:"checksum,omitempty"`
+    Error     string      `json:"error,omitempty"`
+    UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// LoaderOption 配置 Loader。
+type LoaderOption func(*Loader)
+
+// WithHost 设置注入插件的宿主服务，未设置时使用空的 ServiceHost。
+func WithHost(host Host) LoaderOption {
+    return func(l *Loader) {
+        l.host = host
+    }
+}
+
+// WithDebounce 设置文件事件去抖时长，<=0 时不去抖。
+func WithDebounce(d time.Duration) LoaderOption {
+    return func(l *Loader) {
+        l.debounce = d
+    }
+}
+
 // Loader 监听插件目录并管理工作流插件的生命周期。
 type Loader struct {
-    Dir     string   // 插件目录
-    manager *Manager // 全局 Manager
-    watcher *fsnotify.Watcher
-    mu      sync.Mutex
-    loaded  map[string]string // flowName -> soPath
+    Dir      string   // 插件目录
+    manager  *Manager // 全局 Manager
+    watcher  *fsnotify.Watcher
+    debounce time.Duration
+    host     Host
+
+    loadMu sync.Mutex // 串行化加载 / 卸载
+
+    mu     sync.Mutex
+    loaded map[string]string            // flowName@version -> 插件路径
+    procs  map[string]*SubprocessPlugin // .flow 路径 -> 进程外插件
+    status map[string]*PluginStatus     // 插件路径 -> 状态
+    timers map[string]*time.Timer       // 插件路径 -> 去抖定时器
+    opened map[string]string            // 已 plugin.Open 的 .so 路径 -> 打开时的 sha256
+
+    done     chan struct{}
+    wg       sync.WaitGroup
+    stopOnce sync.Once
 }
 
-// NewLoader 创建 Loader。
-func NewLoader(dir string, m *Manager) (*Loader, error) {
+// NewLoader 创建 Loader，并同步加载目录中已有的插件。
+func NewLoader(dir string, m *Manager, opts ...LoaderOption) (*Loader, error) {
     w, err := fsnotify.NewWatcher()
     if err != nil {
         return nil, err
     }
     if err = w.Add(dir); err != nil {
+        w.Close()
         return nil, err
     }
     l := &Loader{
-        Dir:     dir,
-        manager: m,
-        watcher: w,
-        loaded:  make(map[string]string),
+        Dir:      dir,
+        manager:  m,
+        watcher:  w,
+        debounce: DefaultDebounce,
+        loaded:   make(map[string]string),
+        procs:    make(map[string]*SubprocessPlugin),
+        status:   make(map[string]*PluginStatus),
+        timers:   make(map[string]*time.Timer),
+        opened:   make(map[string]string),
+        done:     make(chan struct{}),
+    }
+    for _, opt := range opts {
+        opt(l)
     }
-    // 初始加载目录中已有的 .so
+    if l.host == nil {
+        l.host = NewHost(nil)
+    }
+    // 初始加载目录中已有的插件
     filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-        if err == nil && !d.IsDir() && strings.HasSuffix(path, ".so") {
-            l.loadPlugin(path)
+        if err == nil && !d.IsDir() && isPluginFile(path) {
+            l.sync(path)
         }
         return nil
     })
     return l, nil
 }
 
-// Start 在 goroutine 中运行监听循环。
+// Start 在 goroutine 中运行监听循环，Stop 后退出。
 func (l *Loader) Start() {
+    l.wg.Add(1)
     go func() {
-        for ev := range l.watcher.Events {
-            if ev.Op&(fsnotify.Create|fsnotify.Write) != 0 {
-                if strings.HasSuffix(ev.Name, ".so") {
-                    l.loadPlugin(ev.Name)
+        defer l.wg.Done()
+        for {
+            select {
+            case <-l.done:
+                return
+            case ev, ok := <-l.watcher.Events:
+                if !ok {
+                    return
                 }
-            }
-            if ev.Op&fsnotify.Remove != 0 {
-                if strings.HasSuffix(ev.Name, ".so") {
-                    l.unloadPluginByPath(ev.Name)
+                if isPluginFile(ev.Name) {
+                    // Create/Write/Rename/Remove 统一去抖后按文件当前状态处理：
+                    // 文件存在则加载，不存在（删除或被 rename 走）则卸载
+                    l.schedule(ev.Name)
                 }
+            case err, ok := <-l.watcher.Errors:
+                if !ok {
+                    return
+                }
+                log.Printf("[plugin_loader] 监听错误: %v", err)
             }
         }
     }()
 }
 
-func (l *Loader) loadPlugin(path string) {
+// Stop 停止监听，取消待处理的加载并终止全部进程外插件。已注册的工作流保持不变。
+func (l *Loader) Stop() error {
+    var err error
+    l.stopOnce.Do(func() {
+        close(l.done)
+        err = l.watcher.Close()
+        l.wg.Wait()
+
+        l.mu.Lock()
+        for path, t := range l.timers {
+            t.Stop()
+            delete(l.timers, path)
+        }
+        procs := l.procs
+        l.procs = make(map[string]*SubprocessPlugin)
+        l.mu.Unlock()
+
+        // 等待已触发的加载结束
+        l.loadMu.Lock()
+        l.loadMu.Unlock()
+        for _, sp := range procs {
+            sp.Close()
+        }
+    })
+    return err
+}
+
+// Host 返回注入插件的宿主服务。
+func (l *Loader) Host() Host {
+    return l.host
+}
+
+// Status 返回所有插件文件的状态，按路径排序。
+func (l *Loader) Status() []PluginStatus {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    out := make([]PluginStatus, 0, len(l.status))
+    for _, st := range l.status {
+        out = append(out, *st)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+    return out
+}
+
+// StatusOf 返回指定插件文件的状态。
+func (l *Loader) StatusOf(path string) (PluginStatus, bool) {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    st, ok := l.status[path]
+    if !ok {
+        return PluginStatus{}, false
+    }
+    return *st, true
+}
+
+// schedule 为路径设置（或重置）去抖定时器。
+func (l *Loader) schedule(path string) {
+    if l.debounce <= 0 {
+        l.sync(path)
+        return
+    }
+    l.mu.Lock()
+    defer l.mu.Unlock()
+    if t, ok := l.timers[path]; ok {
+        t.Reset(l.debounce)
+        return
+    }
+    l.timers[path] = time.AfterFunc(l.debounce, func() {
+        l.mu.Lock()
+        delete(l.timers, path)
+        l.mu.Unlock()
+        select {
+        case <-l.done:
+            return
+        default:
+        }
+        l.sync(path)
+    })
+}
+
+// isPluginFile 判断文件是否为可加载的插件，忽略隐藏文件与临时文件。
+func isPluginFile(path string) bool {
+    base := filepath.Base(path)
+    if strings.HasPrefix(base, ".") || strings.HasSuffix(base, "~") {
+        return false
+    }
+    return pluginKind(base) != ""
+}
+
+// pluginKind 按后缀判断插件种类，非插件文件返回空字符串。
+func pluginKind(path string) string {
+    switch filepath.Ext(path) {
+    case soPluginExt:
+        return PluginKindSO
+    case subprocessPluginExt:
+        return PluginKindSubprocess
+    case configPluginExt:
+        return PluginKindConfig
+    }
+    return ""
+}
+
+// sync 按文件当前状态加载或卸载插件。
+func (l *Loader) sync(path string) {
+    l.loadMu.Lock()
+    defer l.loadMu.Unlock()
+
+    if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+        l.unloadPluginByPath(path)
+        return
+    }
+    sum, err := fileChecksum(path)
+    if err != nil {
+        l.setFailed(path, err)
+        return
+    }
+    if st, ok := l.StatusOf(path); ok && st.State == PluginLoaded && st.Checksum == sum {
+        log.Printf("[plugin_loader] 插件 %s 内容未变化，跳过", filepath.Base(path))
+        return
+    }
+    l.load(path, sum)
+}
+
+// load 按插件种类加载文件，调用方需持有 loadMu。
+func (l *Loader) load(path, sum string) {
+    switch pluginKind(path) {
+    case PluginKindSubprocess:
+        l.loadSubprocess(path, sum)
+    case PluginKindConfig:
+        l.loadConfigFlow(path, sum)
+    default:
+        l.loadPlugin(path, sum)
+    }
+}
+
+// loadConfigFlow 解析 FlowConfig 并构造工作流。
+func (l *Loader) loadConfigFlow(path, sum string) {
+    fc, err := LoadFlowConfig(path)
+    if err != nil {
+        l.setFailed(path, err)
+        return
+    }
+    agent, err := BuildFromConfig(fc)
+    if err != nil {
+        l.setFailed(path, err)
+        return
+    }
+    version := fc.Version
+    if version == "" {
+        version = DefaultVersion
+    }
+    l.swap(path, PluginKindConfig, sum, fc.Name, version, agent, nil, fc.Routes)
+}
+
+// loadSubprocess 启动进程外插件，握手成功后替换同一路径上的旧进程。
+func (l *Loader) loadSubprocess(path, sum string) {
+    info, err := os.Stat(path)
+    if err != nil {
+        l.setFailed(path, err)
+        return
+    }
+    if info.Mode()&0o111 == 0 {
+        l.setFailed(path, errors.New("文件不可执行"))
+        return
+    }
+
+    sp := NewSubprocessPlugin(path)
+    ctx, cancel := context.WithTimeout(context.Background(), subprocessDescribeTimeout)
+    defer cancel()
+    if err := sp.Start(ctx); err != nil {
+        l.setFailed(path, fmt.Errorf("启动进程外插件失败: %w", err))
+        return
+    }
+    desc := sp.Info()
+    version := desc.Version
+    if version == "" {
+        version = DefaultVersion
+    }
+    if !l.swap(path, PluginKindSubprocess, sum, desc.Name, version, sp.Agent(), sp, desc.Routes) {
+        sp.Close()
+    }
+}
+
+func (l *Loader) loadPlugin(path, sum string) {
+    // plugin.Open 按路径缓存，原地覆盖的文件再次打开仍得到旧代码
+    l.mu.Lock()
+    openedSum, opened := l.opened[path]
+    l.mu.Unlock()
+    if opened && openedSum != sum {
+        l.setFailed(path, errors.New("Go 原生插件已在进程中打开，原地覆盖的新内容需重启进程才能生效（restart required），请改用新的文件名发布"))
+        return
+    }
     p, err := plugin.Open(path)
     if err != nil {
-        log.Printf("[plugin_loader] 打开插件 %s 失败: %v", path, err)
+        l.setFailed(path, fmt.Errorf("打开插件失败: %w", err))
         return
     }
+    l.mu.Lock()
+    l.opened[path] = sum
+    l.mu.Unlock()
     // 尝试向插件注入统一的 *zap.Logger
     if sym, err := p.Lookup("SetLogger"); err == nil {
         if fn, ok := sym.(func(*zap.Logger)); ok {
             fn(logger.L())
         }
     }
+    // 可选的 Init(Host)，在 Build 之前注入宿主服务
+    if sym, err := p.Lookup("Init"); err == nil {
+        fn, ok := sym.(func(Host) error)
+        if !ok {
+            l.setFailed(path, fmt.Errorf("Init 符号必须为 func(flow.Host) error，实际: %T", sym))
+            return
+        }
+        if err := fn(l.host); err != nil {
+            l.setFailed(path, fmt.Errorf("Init() 失败: %w", err))
+            return
+        }
+    }
     sym, err := p.Lookup("Plugin")
     if err != nil {
-        log.Printf("[plugin_loader] 找不到 Plugin 符号: %v", err)
+        l.setFailed(path, fmt.Errorf("找不到 Plugin 符号: %w", err))
         return
     }
     vptr, ok := sym.(*FlowPlugin)
     if !ok {
-        log.Printf("[plugin_loader] Plugin 符号必须为 *flow.FlowPlugin 指针，实际: %T", sym)
+        l.setFailed(path, fmt.Errorf("Plugin 符号必须为 *flow.FlowPlugin 指针，实际: %T", sym))
         return
     }
     fp := *vptr
-    agent, err := fp.Build()
+    agent, err := buildPlugin(fp, l.host)
     if err != nil {
-        log.Printf("[plugin_loader] Build() 失败: %v", err)
+        l.setFailed(path, err)
         return
     }
-    l.manager.Register(fp.Name(), agent)
+    l.swap(path, PluginKindSO, sum, fp.Name(), pluginVersion(fp), agent, nil, pluginRoutes(fp))
+}
+
+// swap 注册新加载的工作流，并清理该路径此前的注册与被替换的文件。
+// 路由校验失败时不注册，旧版本继续服务，返回 false。
+func (l *Loader) swap(path, kind, sum, name, version string, agent *agents.Agent, sp *SubprocessPlugin, routes []RouteConfig) bool {
+    if err := ValidateRoutes(agent, routes); err != nil {
+        l.setFailed(path, fmt.Errorf("路由校验失败: %w", err))
+        return false
+    }
+    key := flowKey(name, version)
+
+    // 先注册新版本，保证替换期间始终有可用的工作流
+    l.manager.RegisterVersion(name, version, agent, routes...)
+
+    var (
+        staleKeys []string
+        closeProc []*SubprocessPlugin
+    )
     l.mu.Lock()
-    l.loaded[fp.Name()] = path
+    // 该路径此前注册的其他 flow@version（插件改名或改版本）
+    for k, p := range l.loaded {
+        if p == path && k != key {
+            staleKeys = append(staleKeys, k)
+            delete(l.loaded, k)
+        }
+    }
+    // 同一 flow@version 此前由其他文件提供
+    if prev, ok := l.loaded[key]; ok && prev != path {
+        if st, ok := l.status[prev]; ok {
+            st.State = PluginSuperseded
+            st.UpdatedAt = time.Now()
+        }
+        if old, ok := l.procs[prev]; ok {
+            closeProc = append(closeProc, old)
+            delete(l.procs, prev)
+        }
+        log.Printf("[plugin_loader] %s 被 %s 替换", filepath.Base(prev), filepath.Base(path))
+    }
+    l.loaded[key] = path
+    if old, ok := l.procs[path]; ok && old != sp {
+        closeProc = append(closeProc, old)
+    }
+    if sp != nil {
+        l.procs[path] = sp
+    } else {
+        delete(l.procs, path)
+    }
+    l.status[path] = &PluginStatus{
+        Path:      path,
+        Kind:      kind,
+        State:     PluginLoaded,
+        Flow:      name,
+        Version:   version,
+        Checksum:  sum,
+        UpdatedAt: time.Now(),
+    }
     l.mu.Unlock()
-    log.Printf("[plugin_loader] 已加载工作流 %s (%s)", fp.Name(), filepath.Base(path))
+
+    for _, k := range staleKeys {
+        n, v := splitFlowKey(k)
+        l.manager.UnregisterVersion(n, v)
+    }
+    for _, old := range closeProc {
+        old.Close()
+    }
+    log.Printf("[plugin_loader] 已加载工作流 %s 版本 %s (%s)，路由 %d 个", name, version, filepath.Base(path), len(routes))
+    return true
 }
 
-func (l *Loader) unloadPluginByPath(path string) {
+// setFailed 记录加载失败，之前成功加载的版本继续服务。
+func (l *Loader) setFailed(path string, err error) {
+    log.Printf("[plugin_loader] 加载插件 %s 失败: %v", filepath.Base(path), err)
+    kind := pluginKind(path)
     l.mu.Lock()
     defer l.mu.Unlock()
-    for name, p := range l.loaded {
+    st, ok := l.status[path]
+    if !ok {
+        st = &PluginStatus{Path: path, Kind: kind}
+        l.status[path] = st
+    }
+    st.State = PluginFailed
+    st.Error = err.Error()
+    st.UpdatedAt = time.Now()
+}
+
+func (l *Loader) unloadPluginByPath(path string) {
+    l.mu.Lock()
+    var keys []string
+    for key, p := range l.loaded {
         if p == path {
-            l.manager.Unregister(name)
-            delete(l.loaded, name)
-            log.Printf("[plugin_loader] 已卸载工作流 %s", name)
-            return
+            keys = append(keys, key)
+            delete(l.loaded, key)
+        }
+    }
+    sp := l.procs[path]
+    delete(l.procs, path)
+    if st, ok := l.status[path]; ok {
+        st.State = PluginUnloaded
+        st.Error = ""
+        st.UpdatedAt = time.Now()
+    }
+    l.mu.Unlock()
+
+    for _, key := range keys {
+        // 由被替换的文件重新提供该 flow@version，成功时新版本直接覆盖注册
+        if l.promoteSuperseded(key) {
+            continue
         }
+        name, version := splitFlowKey(key)
+        l.manager.UnregisterVersion(name, version)
+        log.Printf("[plugin_loader] 已卸载工作流 %s 版本 %s", name, version)
+    }
+    if sp != nil {
+        sp.Close()
+    }
+}
+
+// promoteSuperseded 重新加载提供 key（flow@version）且仍在磁盘上的被替换文件，
+// 多个候选时取最近被替换的文件。加载成功返回 true，调用方需持有 loadMu。
+func (l *Loader) promoteSuperseded(key string) bool {
+    name, version := splitFlowKey(key)
+    var candidates []PluginStatus
+    l.mu.Lock()
+    for _, st := range l.status {
+        if st.State == PluginSuperseded && st.Flow == name && st.Version == version {
+            candidates = append(candidates, *st)
+        }
+    }
+    l.mu.Unlock()
+    sort.Slice(candidates, func(i, j int) bool { return candidates[i].UpdatedAt.After(candidates[j].UpdatedAt) })
+
+    for _, st := range candidates {
+        sum, err := fileChecksum(st.Path)
+        if err != nil {
+            continue
+        }
+        log.Printf("[plugin_loader] 重新加载被替换的插件 %s", filepath.Base(st.Path))
+        l.load(st.Path, sum)
+        l.mu.Lock()
+        promoted := l.loaded[key] == st.Path
+        l.mu.Unlock()
+        if promoted {
+            return true
+        }
+    }
+    return false
+}
+
+// fileChecksum 计算文件 sha256。
+func fileChecksum(path string) (string, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer f.Close()
+    h := sha256.New()
+    if _, err := io.Copy(h, f); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// flowKey 组合工作流名称与版本作为 loaded 的键。
+func flowKey(name, version string) string {
+    return name + "@" + version
+}
+
+// splitFlowKey 为 flowKey 的逆操作。
+func splitFlowKey(key string) (name, version string) {
+    i := strings.LastIndex(key, "@")
+    if i < 0 {
+        return key, DefaultVersion
     }
+    return key[:i], key[i+1:]
 }
@@ -0,0 +1,187 @@
+package flow
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+    "time"
+)
+
+// waitStatus 轮询直到插件文件达到期望状态
+func waitStatus(t *testing.T, l *Loader, path string, want PluginState) PluginStatus {
+    t.Helper()
+    deadline := time.Now().Add(10 * time.Second)
+    for time.Now().Before(deadline) {
+        if st, ok := l.StatusOf(path); ok && st.State == want {
+            return st
+        }
+        time.Sleep(20 * time.Millisecond)
+    }
+    st, _ := l.StatusOf(path)
+    t.Fatalf("%s 未达到状态 %s，当前: %+v", filepath.Base(path), want, st)
+    return st
+}
+
+func TestLoaderLifecycle(t *testing.T) {
+    t.Setenv(subprocessHelperEnv, "1")
+    bin, err := os.ReadFile(os.Args[0])
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    dir := t.TempDir()
+    m := NewManager()
+    l, err := NewLoader(dir, m, WithDebounce(100*time.Millisecond))
+    if err != nil {
+        t.Fatal(err)
+    }
+    l.Start()
+    defer l.Stop()
+
+    // 先写隐藏临时文件再 rename，只有最终文件会被加载
+    tmp := filepath.Join(dir, ".helper.flow.tmp")
+    final := filepath.Join(dir, "helper.flow")
+    if err := os.WriteFile(tmp, bin, 0o755); err != nil {
+        t.Fatal(err)
+    }
+    if err := os.Rename(tmp, final); err != nil {
+        t.Fatal(err)
+    }
+    st := waitStatus(t, l, final, PluginLoaded)
+    if st.Flow != "helper_flow" || st.Version != "v2" || st.Checksum == "" {
+        t.Fatalf("状态错误: %+v", st)
+    }
+    if _, _, ok := m.Resolve("helper_flow", "v2"); !ok {
+        t.Fatal("工作流未注册")
+    }
+    if _, ok := l.StatusOf(tmp); ok {
+        t.Fatal("临时文件不应被记录")
+    }
+
+    // 进程外插件的路由可指向插件中的子 Agent
+    ri, ok := m.MatchRoute("POST", "/helper/echo")
+    if !ok || ri.Flow != "helper_flow" || ri.Agent != "helper_echo" {
+        t.Fatalf("子 Agent 路由未挂载: %+v", ri)
+    }
+    root, _, _ := m.Resolve("helper_flow", "v2")
+    if out, err := FindAgent(root, ri.Agent).Process(context.Background(), "hi"); err != nil || out != "echo:hi" {
+        t.Fatalf("子 Agent 调用结果错误: %q, %v", out, err)
+    }
+
+    // 不可执行的 .flow 加载失败，错误可查询
+    bad := filepath.Join(dir, "bad.flow")
+    if err := os.WriteFile(bad, []byte("not a binary"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if st := waitStatus(t, l, bad, PluginFailed); st.Error == "" {
+        t.Fatalf("失败状态缺少错误信息: %+v", st)
+    }
+
+    // .json 配置化工作流连同路由一起注册
+    cfgPath := filepath.Join(dir, "outline.json")
+    cfg := `{"name":"outline_flow","version":"v1",
+        "agents":[{"id":"planner","type":"sequential","sub_agents":[{"id":"outliner","type":"leaf"}]}],
+        "routes":[{"path":"/novel/outline","agent":"outliner"}]}`
+    if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if st := waitStatus(t, l, cfgPath, PluginLoaded); st.Kind != PluginKindConfig {
+        t.Fatalf("状态错误: %+v", st)
+    }
+    if ri, ok := m.MatchRoute("POST", "/novel/outline/"); !ok || ri.Flow != "outline_flow" || ri.Agent != "outliner" {
+        t.Fatalf("路由未挂载: %+v", ri)
+    }
+
+    // 删除后注销
+    if err := os.Remove(cfgPath); err != nil {
+        t.Fatal(err)
+    }
+    waitStatus(t, l, cfgPath, PluginUnloaded)
+    if _, ok := m.MatchRoute("POST", "/novel/outline"); ok {
+        t.Fatal("路由应随工作流卸载")
+    }
+    if err := os.Remove(final); err != nil {
+        t.Fatal(err)
+    }
+    waitStatus(t, l, final, PluginUnloaded)
+    if _, _, ok := m.Resolve("helper_flow", "v2"); ok {
+        t.Fatal("工作流应已注销")
+    }
+
+    if err := l.Stop(); err != nil {
+        t.Fatalf("Stop 失败: %v", err)
+    }
+    // 重复 Stop 安全
+    l.Stop()
+}
+
+// TestLoaderPromotesSuperseded 验证替换同一 flow@version 的文件删除后，被替换的文件重新提供服务
+func TestLoaderPromotesSuperseded(t *testing.T) {
+    dir := t.TempDir()
+    m := NewManager()
+    l, err := NewLoader(dir, m, WithDebounce(0))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer l.Stop()
+
+    write := func(name, agentID string) string {
+        path := filepath.Join(dir, name)
+        cfg := `{"name":"outline_flow","version":"v1","agents":[{"id":"` + agentID + `","type":"leaf"}]}`
+        if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+            t.Fatal(err)
+        }
+        l.sync(path)
+        return path
+    }
+    a := write("a.json", "outliner_a")
+    b := write("b.json", "outliner_b")
+    if st, _ := l.StatusOf(a); st.State != PluginSuperseded {
+        t.Fatalf("a.json 应被替换: %+v", st)
+    }
+    if agent, _, _ := m.Resolve("outline_flow", "v1"); agent.Name() != "outliner_b" {
+        t.Fatalf("应由 b.json 提供: %s", agent.Name())
+    }
+
+    if err := os.Remove(b); err != nil {
+        t.Fatal(err)
+    }
+    l.sync(b)
+    if st, _ := l.StatusOf(a); st.State != PluginLoaded {
+        t.Fatalf("a.json 应重新加载: %+v", st)
+    }
+    if agent, _, ok := m.Resolve("outline_flow", "v1"); !ok || agent.Name() != "outliner_a" {
+        t.Fatal("删除 b.json 后应由 a.json 提供 outline_flow@v1")
+    }
+
+    if err := os.Remove(a); err != nil {
+        t.Fatal(err)
+    }
+    l.sync(a)
+    if _, _, ok := m.Resolve("outline_flow", "v1"); ok {
+        t.Fatal("全部文件删除后工作流应注销")
+    }
+}
+
+// TestLoaderRejectsRewrittenSO 验证原地覆盖已打开的 .so 时报告需要重启，而不是声称加载成功
+func TestLoaderRejectsRewrittenSO(t *testing.T) {
+    dir := t.TempDir()
+    l, err := NewLoader(dir, NewManager(), WithDebounce(0))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer l.Stop()
+
+    path := filepath.Join(dir, "novel.so")
+    if err := os.WriteFile(path, []byte("rewritten"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    // 模拟该路径此前已以其他内容打开
+    l.opened[path] = "previous-checksum"
+    l.sync(path)
+    if st, _ := l.StatusOf(path); st.State != PluginFailed || !strings.Contains(st.Error, "restart required") {
+        t.Fatalf("期望 failed 及重启提示: %+v", st)
+    }
+}
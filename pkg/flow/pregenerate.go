@@ -0,0 +1,254 @@
+package flow
+
+// pregenerate.go 实现 FlowConfig.PreGenerate 预生成阶段。
+//
+// 预生成 Agent 在主流程之前执行（如检索记忆上下文、起草大纲），输出通过 context
+// 传递给主流程，Agent 内可用 PreGenerateOutput(ctx) 读取；开启 prepend_input 时
+// 同时拼接到主流程输入之前，便于仅依赖输入文本的叶子 Agent 使用。
+//
+// 预生成超时或失败时按 fallback 处理：continue（默认）记录日志后以空结果继续主流程，
+// fail 则直接返回错误。
+//
+// 会话开始时可调用 Warmup 推测性地提前执行预生成，结果按 user_id + archive_id 缓存，
+// 在 TTL 内被该用户输入相同的下一次请求消费，从而把预生成耗时从请求路径中移除；
+// 输入不同时预热结果被丢弃，请求照常执行预生成。
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "sync"
+    "time"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+// PreGenerateContextKey 为预生成输出在 context 中的键，与 "user_id" 等键风格一致。
+const PreGenerateContextKey = "pre_generate"
+
+// 预生成失败时的处理方式。
+const (
+    PreGenerateFallbackContinue = "continue" // 以空结果继续主流程
+    PreGenerateFallbackFail     = "fail"     // 返回错误
+)
+
+// 预生成默认参数。
+const (
+    DefaultPreGenerateTimeout  = 10 * time.Second
+    DefaultPreGenerateCacheTTL = 5 * time.Minute
+)
+
+// ErrPreGenerateTimeout 表示预生成阶段超时。
+var ErrPreGenerateTimeout = errors.New("pre-generate timeout")
+
+// PreGenerateOutput 返回 context 中的预生成输出，未执行或失败时为空字符串。
+func PreGenerateOutput(ctx context.Context) string {
+    out, _ := ctx.Value(PreGenerateContextKey).(string)
+    return out
+}
+
+// PreGenerator 执行预生成 Agent 并管理预热缓存。
+type PreGenerator struct {
+    agent        *agents.Agent
+    timeout      time.Duration
+    fallback     string
+    prependInput bool
+    ttl          time.Duration
+
+    mu    sync.Mutex
+    cache map[string]*warmEntry // user_id/archive_id -> 预热结果
+}
+
+// warmEntry 为一次预热的结果，done 关闭后 output / err 可读。
+type warmEntry struct {
+    input   string
+    done    chan struct{}
+    output  string
+    err     error
+    expires time.Time
+}
+
+// NewPreGenerator 根据配置创建 PreGenerator，agent 为预生成使用的 Agent。
+func NewPreGenerator(cfg PreGenerateConfig, agent *agents.Agent) (*PreGenerator, error) {
+    if agent == nil {
+        return nil, fmt.Errorf("预生成 agent %s 不存在", cfg.Agent)
+    }
+    p := &PreGenerator{
+        agent:        agent,
+        timeout:      time.Duration(cfg.TimeoutMs) * time.Millisecond,
+        fallback:     cfg.Fallback,
+        prependInput: cfg.PrependInput,
+        ttl:          time.Duration(cfg.CacheTTLMs) * time.Millisecond,
+        cache:        make(map[string]*warmEntry),
+    }
+    if p.timeout <= 0 {
+        p.timeout = DefaultPreGenerateTimeout
+    }
+    if p.ttl <= 0 {
+        p.ttl = DefaultPreGenerateCacheTTL
+    }
+    switch p.fallback {
+    case "":
+        p.fallback = PreGenerateFallbackContinue
+    case PreGenerateFallbackContinue, PreGenerateFallbackFail:
+    default:
+        return nil, fmt.Errorf("预生成 fallback %q 无效，应为 continue/fail", cfg.Fallback)
+    }
+    return p, nil
+}
+
+// Agent 返回预生成 Agent。
+func (p *PreGenerator) Agent() *agents.Agent {
+    return p.agent
+}
+
+// Run 执行预生成：优先消费该用户相同输入的预热结果，否则同步执行。等待预热与
+// 同步执行共用一个超时。fallback 为 continue 时失败返回空字符串与 nil。
+func (p *PreGenerator) Run(ctx context.Context, input string) (string, error) {
+    ctx, cancel := context.WithTimeout(ctx, p.timeout)
+    defer cancel()
+    if out, ok := p.takeWarm(ctx, input); ok {
+        return out, nil
+    }
+    out, err := p.run(ctx, input)
+    if err != nil {
+        if p.fallback == PreGenerateFallbackFail {
+            return "", fmt.Errorf("预生成失败: %w", err)
+        }
+        log.Printf("[pre_generate] %s 失败，继续主流程: %v", p.agent.Name(), err)
+        return "", nil
+    }
+    return out, nil
+}
+
+// run 在超时约束下执行预生成 Agent，ctx 的截止时间更早时以 ctx 为准。
+func (p *PreGenerator) run(ctx context.Context, input string) (string, error) {
+    ctx, cancel := context.WithTimeout(ctx, p.timeout)
+    defer cancel()
+
+    type result struct {
+        out string
+        err error
+    }
+    ch := make(chan result, 1)
+    go func() {
+        out, err := p.agent.Process(ctx, input)
+        ch <- result{out, err}
+    }()
+    select {
+    case r := <-ch:
+        return r.out, r.err
+    case <-ctx.Done():
+        if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+            return "", ErrPreGenerateTimeout
+        }
+        return "", ctx.Err()
+    }
+}
+
+// Warmup 为用户异步执行一次预生成并缓存结果，同一用户进行中的预热不会重复执行。
+func (p *PreGenerator) Warmup(userID, archiveID, input string) {
+    key := warmKey(userID, archiveID)
+    p.mu.Lock()
+    p.evictExpired()
+    if e, ok := p.cache[key]; ok && time.Now().Before(e.expires) {
+        p.mu.Unlock()
+        return
+    }
+    e := &warmEntry{input: input, done: make(chan struct{}), expires: time.Now().Add(p.ttl)}
+    p.cache[key] = e
+    p.mu.Unlock()
+
+    go func() {
+        defer close(e.done)
+        ctx := context.WithValue(context.Background(), "user_id", userID)
+        ctx = context.WithValue(ctx, "archive_id", archiveID)
+        e.output, e.err = p.run(ctx, input)
+        if e.err != nil {
+            log.Printf("[pre_generate] 用户 %s 预热失败: %v", userID, e.err)
+        }
+    }()
+}
+
+// takeWarm 取出并移除当前用户的预热结果，预热输入与 input 不同时丢弃。
+// 预热仍在进行时最多等待到 ctx 结束。
+func (p *PreGenerator) takeWarm(ctx context.Context, input string) (string, bool) {
+    userID, _ := ctx.Value("user_id").(string)
+    archiveID, _ := ctx.Value("archive_id").(string)
+    key := warmKey(userID, archiveID)
+
+    p.mu.Lock()
+    e, ok := p.cache[key]
+    if ok {
+        delete(p.cache, key)
+    }
+    p.mu.Unlock()
+    if !ok || e.input != input || time.Now().After(e.expires) {
+        return "", false
+    }
+
+    select {
+    case <-e.done:
+    case <-ctx.Done():
+        return "", false
+    }
+    if e.err != nil {
+        return "", false
+    }
+    return e.output, true
+}
+
+// evictExpired 清理过期的预热结果，调用方需持有锁。
+func (p *PreGenerator) evictExpired() {
+    now := time.Now()
+    for k, e := range p.cache {
+        if now.After(e.expires) {
+            delete(p.cache, k)
+        }
+    }
+}
+
+// Wrap 返回先执行预生成、再执行 main 的 Agent，名称为 name。
+// 预生成输出写入 context（PreGenerateContextKey），开启 prepend_input 时同时拼接到输入前。
+func (p *PreGenerator) Wrap(name string, main *agents.Agent) *agents.Agent {
+    wrapped := agents.NewAgent(
+        agents.WithName(name),
+        agents.WithDescription(main.Description()),
+        agents.WithSubAgents(p.agent, main),
+        agents.WithProcessFunc(func(ctx context.Context, message string) (string, error) {
+            pre, err := p.Run(ctx, message)
+            if err != nil {
+                return "", err
+            }
+            ctx = context.WithValue(ctx, PreGenerateContextKey, pre)
+            if p.prependInput && pre != "" {
+                message = fmt.Sprintf("[预生成上下文]\n%s\n\n[用户输入]\n%s", pre, message)
+            }
+            return main.Process(ctx, message)
+        }),
+    )
+    preGenerators.Store(wrapped, p)
+    return wrapped
+}
+
+// preGenerators 记录 Wrap 创建的 Agent 对应的 PreGenerator，供 Warmup 查找。
+var preGenerators sync.Map // *agents.Agent -> *PreGenerator
+
+// LookupPreGenerator 返回工作流根 Agent 绑定的 PreGenerator。
+func LookupPreGenerator(agent *agents.Agent) (*PreGenerator, bool) {
+    v, ok := preGenerators.Load(agent)
+    if !ok {
+        return nil, false
+    }
+    return v.(*PreGenerator), true
+}
+
+// forgetPreGenerator 在工作流注销后释放 PreGenerator。
+func forgetPreGenerator(agent *agents.Agent) {
+    preGenerators.Delete(agent)
+}
+
+func warmKey(userID, archiveID string) string {
+    return userID + "/" + archiveID
+}
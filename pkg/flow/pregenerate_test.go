@@ -0,0 +1,120 @@
+package flow
+
+import (
+    "context"
+    "strings"
+    "sync/atomic"
+    "testing"
+    "time"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+func TestPreGenerator(t *testing.T) {
+    var calls int32
+    delay := int64(0)
+    pre := agents.NewAgent(
+        agents.WithName("memory_fetch"),
+        agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+            atomic.AddInt32(&calls, 1)
+            select {
+            case <-time.After(time.Duration(atomic.LoadInt64(&delay))):
+                return "ctx:" + msg, nil
+            case <-ctx.Done():
+                return "", ctx.Err()
+            }
+        }),
+    )
+    main := agents.NewAgent(
+        agents.WithName("writer"),
+        agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+            return PreGenerateOutput(ctx) + "|" + msg, nil
+        }),
+    )
+
+    pg, err := NewPreGenerator(PreGenerateConfig{Enabled: true, Agent: "memory_fetch", TimeoutMs: 50}, pre)
+    if err != nil {
+        t.Fatal(err)
+    }
+    root := pg.Wrap("novel", main)
+    if got, ok := LookupPreGenerator(root); !ok || got != pg {
+        t.Fatal("未找到绑定的 PreGenerator")
+    }
+
+    out, err := root.Process(context.Background(), "hi")
+    if err != nil || out != "ctx:hi|hi" {
+        t.Fatalf("输出错误: %q %v", out, err)
+    }
+
+    // 超时后以空结果继续主流程
+    atomic.StoreInt64(&delay, int64(time.Second))
+    if out, err = root.Process(context.Background(), "hi"); err != nil || out != "|hi" {
+        t.Fatalf("continue 降级错误: %q %v", out, err)
+    }
+
+    // fail 模式返回超时错误
+    failPG, _ := NewPreGenerator(PreGenerateConfig{Agent: "memory_fetch", TimeoutMs: 50, Fallback: PreGenerateFallbackFail}, pre)
+    if _, err = failPG.Run(context.Background(), "hi"); err == nil {
+        t.Fatal("fail 模式应返回错误")
+    }
+
+    // 预热结果被同一用户的下一次请求消费
+    atomic.StoreInt64(&delay, 0)
+    before := atomic.LoadInt32(&calls)
+    pg.Warmup("u1", "a1", "hi")
+    ctx := context.WithValue(context.Background(), "user_id", "u1")
+    ctx = context.WithValue(ctx, "archive_id", "a1")
+    time.Sleep(20 * time.Millisecond)
+    if out, err = root.Process(ctx, "hi"); err != nil || out != "ctx:hi|hi" {
+        t.Fatalf("预热结果未被使用: %q %v", out, err)
+    }
+    if atomic.LoadInt32(&calls) != before+1 {
+        t.Fatal("预热后请求时不应再次执行预生成")
+    }
+
+    // 输入不同的请求不使用预热结果
+    pg.Warmup("u1", "a1", "warm")
+    time.Sleep(20 * time.Millisecond)
+    if out, err = root.Process(ctx, "other"); err != nil || out != "ctx:other|other" {
+        t.Fatalf("不应使用其他输入的预热结果: %q %v", out, err)
+    }
+
+    // 等待预热与同步执行共用一个超时
+    atomic.StoreInt64(&delay, int64(time.Second))
+    slowPG, _ := NewPreGenerator(PreGenerateConfig{Agent: "memory_fetch", TimeoutMs: 200}, pre)
+    slowPG.Warmup("u1", "a1", "hi")
+    start := time.Now()
+    if out, err := slowPG.Run(ctx, "hi"); err != nil || out != "" {
+        t.Fatalf("超时降级错误: %q %v", out, err)
+    }
+    if elapsed := time.Since(start); elapsed > 350*time.Millisecond {
+        t.Fatalf("预生成耗时 %s 超过超时", elapsed)
+    }
+}
+
+// TestPreGenerateConfigValidate 验证 pre_generate.agent 指向嵌套或不存在的 Agent 时配置校验失败
+func TestPreGenerateConfigValidate(t *testing.T) {
+    agentsCfg := []AgentConfig{
+        {ID: "memory_fetch", Type: "leaf"},
+        {ID: "writer", Type: "sequential", SubAgents: []AgentConfig{{ID: "draft", Type: "leaf"}}},
+    }
+    cases := map[string]struct {
+        pg   PreGenerateConfig
+        want string
+    }{
+        "嵌套":   {PreGenerateConfig{Enabled: true, Agent: "draft"}, "嵌套"},
+        "不存在":  {PreGenerateConfig{Enabled: true, Agent: "missing"}, "不存在"},
+        "未启用":  {PreGenerateConfig{Agent: "missing"}, "不存在"},
+        "缺少 id": {PreGenerateConfig{Enabled: true}, "不能为空"},
+    }
+    for name, tc := range cases {
+        fc := &FlowConfig{Name: "novel", Agents: agentsCfg, PreGenerate: tc.pg}
+        if err := fc.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+            t.Errorf("%s: err = %v，期望包含 %q", name, err, tc.want)
+        }
+    }
+    fc := &FlowConfig{Name: "novel", Agents: agentsCfg, PreGenerate: PreGenerateConfig{Enabled: true, Agent: "memory_fetch"}}
+    if err := fc.Validate(); err != nil {
+        t.Errorf("顶层预生成 Agent 应校验通过: %v", err)
+    }
+}
@@ -0,0 +1,526 @@
+package flow
+
+// subprocess.go 实现进程外插件模式。
+//
+// 与 Go 原生 .so 插件相比，进程外插件是插件目录中的可执行文件（后缀 .flow），
+// 宿主启动该进程，通过 stdin/stdout 以 JSON-RPC 2.0 通信，每行一条 JSON 消息：
+//
+//   -> {"jsonrpc":"2.0","id":1,"method":"describe"}
+//   <- {"jsonrpc":"2.0","id":1,"result":{"name":"novel","version":"v2","agents":["outliner"]}}
+//   -> {"jsonrpc":"2.0","id":2,"method":"process","params":{"input":"...","user_id":"u1"}}
+//   <- {"jsonrpc":"2.0","id":2,"result":{"output":"..."}}
+//   -> {"jsonrpc":"2.0","id":3,"method":"stream","params":{"input":"..."}}
+//   <- {"jsonrpc":"2.0","method":"stream.chunk","params":{"id":3,"data":"..."}}
+//   <- {"jsonrpc":"2.0","id":3,"result":{"output":"..."}}
+//   -> {"jsonrpc":"2.0","method":"cancel","params":{"id":3}}
+//
+// describe 返回的 agents 为插件 Agent 树中根以外的 Agent 名称，process / stream 的
+// agent 参数指定其中之一时由该 Agent 处理请求，插件路由借此指向子 Agent。
+//
+// stderr 作为插件日志转发到宿主日志。插件进程崩溃后由宿主按退避策略自动重启，
+// 文件删除时进程被终止。Go 编写的插件可直接使用 ServeSubprocess 实现服务端。
+
+import (
+    "bufio"
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "os"
+    "os/exec"
+    "path/filepath"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+// 协议方法名。
+const (
+    MethodDescribe    = "describe"
+    MethodProcess     = "process"
+    MethodStream      = "stream"
+    MethodCancel      = "cancel"
+    MethodStreamChunk = "stream.chunk"
+)
+
+// JSON-RPC 错误码。
+const (
+    RPCParseError     = -32700
+    RPCMethodNotFound = -32601
+    RPCInvalidParams  = -32602
+    RPCInternalError  = -32603
+    RPCProcessError   = -32000 // 工作流执行失败（含 panic）
+)
+
+const (
+    subprocessMaxLine         = 16 << 20 // 单条消息上限
+    subprocessDescribeTimeout = 10 * time.Second
+    subprocessStopGrace       = 2 * time.Second
+    subprocessMinBackoff      = 500 * time.Millisecond
+    subprocessMaxBackoff      = 30 * time.Second
+    subprocessStableAfter     = time.Minute // 存活超过该时长视为稳定，重置退避
+)
+
+var (
+    // ErrSubprocessUnavailable 插件进程未运行（正在重启或已关闭）。
+    ErrSubprocessUnavailable = errors.New("subprocess plugin is not running")
+    // ErrSubprocessExited 调用过程中插件进程退出。
+    ErrSubprocessExited = errors.New("subprocess plugin exited")
+)
+
+// RPCError 为 JSON-RPC 错误对象。
+type RPCError struct {
+    Code    int    `json:"code"`
+    Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+    return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// rpcMessage 为请求、响应与通知的统一编码。
+type rpcMessage struct {
+    JSONRPC string          `json:"jsonrpc"`
+    ID      *int64          `json:"id,omitempty"`
+    Method  string          `json:"method,omitempty"`
+    Params  json.RawMessage `json:"params,omitempty"`
+    Result  json.RawMessage `json:"result,omitempty"`
+    Error   *RPCError       `json:"error,omitempty"`
+}
+
+// DescribeResult 为 describe 方法的返回值。
+type DescribeResult struct {
+    Name        string        `json:"name"`
+    Version     string        `json:"version,omitempty"`
+    Description string        `json:"description,omitempty"`
+    Routes      []RouteConfig `json:"routes,omitempty"` // 插件声明的 HTTP 路由
+    Agents      []string      `json:"agents,omitempty"` // 根以外可单独调用的 Agent 名称
+}
+
+// ProcessParams 为 process / stream 方法的参数。
+type ProcessParams struct {
+    Input     string `json:"input"`
+    UserID    string `json:"user_id,omitempty"`
+    ArchiveID string `json:"archive_id,omitempty"`
+    Agent     string `json:"agent,omitempty"` // 处理请求的 Agent，为空时为插件根 Agent
+}
+
+// ProcessResult 为 process / stream 方法的返回值。
+type ProcessResult struct {
+    Output string `json:"output"`
+}
+
+// ChunkParams 为 stream.chunk 通知的参数，ID 对应 stream 请求。
+type ChunkParams struct {
+    ID   int64  `json:"id"`
+    Data string `json:"data"`
+}
+
+// CancelParams 为 cancel 通知的参数。
+type CancelParams struct {
+    ID int64 `json:"id"`
+}
+
+// SubprocessPlugin 管理一个进程外插件：启动、调用、崩溃重启与关闭。
+type SubprocessPlugin struct {
+    path string
+
+    mu     sync.Mutex
+    proc   *subprocess // 当前进程，重启期间为 nil
+    info   DescribeResult
+    closed bool
+
+    nextID   int64
+    restarts int64
+    closeCh  chan struct{}
+    wg       sync.WaitGroup
+}
+
+// subprocess 为一次进程实例。
+type subprocess struct {
+    cmd     *exec.Cmd
+    stdin   io.WriteCloser
+    writeMu sync.Mutex
+
+    mu      sync.Mutex
+    pending map[int64]*pendingCall
+
+    started time.Time
+    done    chan struct{} // 进程退出后关闭
+    err     error         // 退出原因
+}
+
+type pendingCall struct {
+    result  chan rpcMessage
+    onChunk func(string)
+}
+
+// NewSubprocessPlugin 创建进程外插件，调用 Start 后才会启动进程。
+func NewSubprocessPlugin(path string) *SubprocessPlugin {
+    return &SubprocessPlugin{
+        path:    path,
+        closeCh: make(chan struct{}),
+    }
+}
+
+// Start 启动插件进程并获取描述信息，成功后在后台监护进程，崩溃时自动重启。
+func (p *SubprocessPlugin) Start(ctx context.Context) error {
+    proc, info, err := p.spawnAndDescribe(ctx)
+    if err != nil {
+        return err
+    }
+    p.mu.Lock()
+    p.proc = proc
+    p.info = info
+    p.mu.Unlock()
+
+    p.wg.Add(1)
+    go p.supervise(proc)
+    return nil
+}
+
+// Info 返回插件描述信息。
+func (p *SubprocessPlugin) Info() DescribeResult {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.info
+}
+
+// Restarts 返回进程崩溃后被重启的次数。
+func (p *SubprocessPlugin) Restarts() int64 {
+    return atomic.LoadInt64(&p.restarts)
+}
+
+// Process 调用插件的 process 方法，user_id / archive_id 取自 ctx。
+func (p *SubprocessPlugin) Process(ctx context.Context, input string) (string, error) {
+    return p.invoke(ctx, MethodProcess, "", input, nil)
+}
+
+// Stream 调用插件的 stream 方法，每个输出片段回调 onChunk，返回完整输出。
+func (p *SubprocessPlugin) Stream(ctx context.Context, input string, onChunk func(string)) (string, error) {
+    return p.invoke(ctx, MethodStream, "", input, onChunk)
+}
+
+// Agent 将插件包装为 *agents.Agent，以便注册到 Manager。describe 声明的每个
+// Agent 对应一个子 Agent，调用时由插件中的同名 Agent 处理，路由可指向它们。
+func (p *SubprocessPlugin) Agent() *agents.Agent {
+    info := p.Info()
+    subAgents := make([]*agents.Agent, 0, len(info.Agents))
+    for _, name := range info.Agents {
+        name := name
+        subAgents = append(subAgents, agents.NewAgent(
+            agents.WithName(name),
+            agents.WithProcessFunc(func(ctx context.Context, input string) (string, error) {
+                return p.invoke(ctx, MethodProcess, name, input, nil)
+            }),
+        ))
+    }
+    return agents.NewAgent(
+        agents.WithName(info.Name),
+        agents.WithDescription(info.Description),
+        agents.WithProcessFunc(p.Process),
+        agents.WithSubAgents(subAgents...),
+    )
+}
+
+// Close 停止监护并终止插件进程。
+func (p *SubprocessPlugin) Close() error {
+    p.mu.Lock()
+    if p.closed {
+        p.mu.Unlock()
+        return nil
+    }
+    p.closed = true
+    close(p.closeCh)
+    p.mu.Unlock()
+    p.wg.Wait()
+    return nil
+}
+
+func (p *SubprocessPlugin) invoke(ctx context.Context, method, agent, input string, onChunk func(string)) (string, error) {
+    params := ProcessParams{Input: input, Agent: agent}
+    if v, ok := ctx.Value("user_id").(string); ok {
+        params.UserID = v
+    }
+    if v, ok := ctx.Value("archive_id").(string); ok {
+        params.ArchiveID = v
+    }
+    raw, err := p.call(ctx, method, params, onChunk)
+    if err != nil {
+        return "", err
+    }
+    var res ProcessResult
+    if err := json.Unmarshal(raw, &res); err != nil {
+        return "", fmt.Errorf("解析插件返回失败: %w", err)
+    }
+    return res.Output, nil
+}
+
+func (p *SubprocessPlugin) current() *subprocess {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    return p.proc
+}
+
+func (p *SubprocessPlugin) call(ctx context.Context, method string, params interface{}, onChunk func(string)) (json.RawMessage, error) {
+    proc := p.current()
+    if proc == nil {
+        return nil, ErrSubprocessUnavailable
+    }
+    return proc.call(ctx, atomic.AddInt64(&p.nextID, 1), method, params, onChunk)
+}
+
+// spawnAndDescribe 启动新进程并完成 describe 握手。
+func (p *SubprocessPlugin) spawnAndDescribe(ctx context.Context) (*subprocess, DescribeResult, error) {
+    var info DescribeResult
+    proc, err := spawnSubprocess(p.path)
+    if err != nil {
+        return nil, info, err
+    }
+    dctx, cancel := context.WithTimeout(ctx, subprocessDescribeTimeout)
+    defer cancel()
+    raw, err := proc.call(dctx, atomic.AddInt64(&p.nextID, 1), MethodDescribe, nil, nil)
+    if err == nil {
+        err = json.Unmarshal(raw, &info)
+    }
+    if err == nil && info.Name == "" {
+        err = errors.New("describe 未返回工作流名称")
+    }
+    if err != nil {
+        proc.stop()
+        return nil, info, fmt.Errorf("插件 %s 握手失败: %w", filepath.Base(p.path), err)
+    }
+    return proc, info, nil
+}
+
+// supervise 等待进程退出，未关闭时按指数退避重启。
+func (p *SubprocessPlugin) supervise(proc *subprocess) {
+    defer p.wg.Done()
+    backoff := subprocessMinBackoff
+    for {
+        select {
+        case <-p.closeCh:
+            proc.stop()
+            p.mu.Lock()
+            p.proc = nil
+            p.mu.Unlock()
+            return
+        case <-proc.done:
+        }
+
+        p.mu.Lock()
+        p.proc = nil
+        p.mu.Unlock()
+        if time.Since(proc.started) > subprocessStableAfter {
+            backoff = subprocessMinBackoff
+        }
+        log.Printf("[plugin_loader] 插件进程 %s 退出: %v，%s 后重启", filepath.Base(p.path), proc.err, backoff)
+
+        for {
+            select {
+            case <-p.closeCh:
+                return
+            case <-time.After(backoff):
+            }
+            if backoff *= 2; backoff > subprocessMaxBackoff {
+                backoff = subprocessMaxBackoff
+            }
+            next, info, err := p.spawnAndDescribe(context.Background())
+            if err != nil {
+                log.Printf("[plugin_loader] 重启插件 %s 失败: %v，%s 后重试", filepath.Base(p.path), err, backoff)
+                continue
+            }
+            p.mu.Lock()
+            if info.Name != p.info.Name {
+                log.Printf("[plugin_loader] 插件 %s 重启后名称由 %s 变为 %s，继续以原名称提供服务", filepath.Base(p.path), p.info.Name, info.Name)
+            }
+            p.proc = next
+            p.mu.Unlock()
+            atomic.AddInt64(&p.restarts, 1)
+            proc = next
+            break
+        }
+    }
+}
+
+// spawnSubprocess 启动进程并开始读取输出。
+func spawnSubprocess(path string) (*subprocess, error) {
+    cmd := exec.Command(path)
+    cmd.Dir = filepath.Dir(path)
+    cmd.Env = os.Environ()
+    stdin, err := cmd.StdinPipe()
+    if err != nil {
+        return nil, err
+    }
+    stdout, err := cmd.StdoutPipe()
+    if err != nil {
+        return nil, err
+    }
+    cmd.Stderr = &prefixWriter{prefix: "[plugin:" + filepath.Base(path) + "] "}
+    if err := cmd.Start(); err != nil {
+        return nil, fmt.Errorf("启动插件进程 %s 失败: %w", path, err)
+    }
+    s := &subprocess{
+        cmd:     cmd,
+        stdin:   stdin,
+        pending: make(map[int64]*pendingCall),
+        started: time.Now(),
+        done:    make(chan struct{}),
+    }
+    go s.readLoop(stdout)
+    return s, nil
+}
+
+func (s *subprocess) readLoop(stdout io.Reader) {
+    sc := bufio.NewScanner(stdout)
+    sc.Buffer(make([]byte, 64*1024), subprocessMaxLine)
+    for sc.Scan() {
+        var msg rpcMessage
+        if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+            log.Printf("[plugin_loader] 忽略无法解析的插件输出: %s", sc.Text())
+            continue
+        }
+        if msg.Method == MethodStreamChunk {
+            var chunk ChunkParams
+            if json.Unmarshal(msg.Params, &chunk) == nil {
+                s.mu.Lock()
+                pc := s.pending[chunk.ID]
+                s.mu.Unlock()
+                if pc != nil && pc.onChunk != nil {
+                    pc.onChunk(chunk.Data)
+                }
+            }
+            continue
+        }
+        if msg.ID == nil {
+            continue
+        }
+        s.mu.Lock()
+        pc := s.pending[*msg.ID]
+        delete(s.pending, *msg.ID)
+        s.mu.Unlock()
+        if pc != nil {
+            pc.result <- msg
+        }
+    }
+    // 读取出错（如单行超过 subprocessMaxLine）时进程可能仍在运行，
+    // 先终止进程，否则 Wait 一直阻塞，挂起的调用无法结束
+    scanErr := sc.Err()
+    if scanErr != nil {
+        log.Printf("[plugin_loader] 读取插件输出失败，终止插件进程: %v", scanErr)
+        s.cmd.Process.Kill()
+    }
+    err := s.cmd.Wait()
+    if scanErr != nil {
+        err = scanErr
+    }
+    if err == nil {
+        err = ErrSubprocessExited
+    }
+    s.err = err
+    // 关闭 done 使全部挂起的调用以 ErrSubprocessExited 失败
+    s.mu.Lock()
+    s.pending = make(map[int64]*pendingCall)
+    s.mu.Unlock()
+    close(s.done)
+}
+
+func (s *subprocess) call(ctx context.Context, id int64, method string, params interface{}, onChunk func(string)) (json.RawMessage, error) {
+    pc := &pendingCall{result: make(chan rpcMessage, 1), onChunk: onChunk}
+    s.mu.Lock()
+    s.pending[id] = pc
+    s.mu.Unlock()
+    defer func() {
+        s.mu.Lock()
+        delete(s.pending, id)
+        s.mu.Unlock()
+    }()
+
+    if err := s.send(rpcMessage{ID: &id, Method: method}, params); err != nil {
+        return nil, err
+    }
+    select {
+    case msg := <-pc.result:
+        if msg.Error != nil {
+            return nil, msg.Error
+        }
+        return msg.Result, nil
+    case <-ctx.Done():
+        // 通知插件取消，忽略写入失败
+        s.send(rpcMessage{Method: MethodCancel}, CancelParams{ID: id})
+        return nil, ctx.Err()
+    case <-s.done:
+        // 进程退出前可能已写回结果
+        select {
+        case msg := <-pc.result:
+            if msg.Error != nil {
+                return nil, msg.Error
+            }
+            return msg.Result, nil
+        default:
+        }
+        return nil, fmt.Errorf("%w: %v", ErrSubprocessExited, s.err)
+    }
+}
+
+func (s *subprocess) send(msg rpcMessage, params interface{}) error {
+    msg.JSONRPC = "2.0"
+    if params != nil {
+        raw, err := json.Marshal(params)
+        if err != nil {
+            return err
+        }
+        msg.Params = raw
+    }
+    line, err := json.Marshal(msg)
+    if err != nil {
+        return err
+    }
+    s.writeMu.Lock()
+    defer s.writeMu.Unlock()
+    if _, err := s.stdin.Write(append(line, '\n')); err != nil {
+        return fmt.Errorf("%w: %v", ErrSubprocessExited, err)
+    }
+    return nil
+}
+
+// stop 关闭 stdin 请求插件退出，超过宽限期后强制终止。
+func (s *subprocess) stop() {
+    s.stdin.Close()
+    select {
+    case <-s.done:
+        return
+    case <-time.After(subprocessStopGrace):
+    }
+    if s.cmd.Process != nil {
+        s.cmd.Process.Kill()
+    }
+    <-s.done
+}
+
+// prefixWriter 将插件 stderr 按行转发到宿主日志。
+type prefixWriter struct {
+    prefix string
+    mu     sync.Mutex
+    buf    []byte
+}
+
+func (w *prefixWriter) Write(b []byte) (int, error) {
+    w.mu.Lock()
+    defer w.mu.Unlock()
+    w.buf = append(w.buf, b...)
+    for {
+        i := bytes.IndexByte(w.buf, '\n')
+        if i < 0 {
+            break
+        }
+        log.Print(w.prefix + string(w.buf[:i]))
+        w.buf = w.buf[i+1:]
+    }
+    return len(b), nil
+}
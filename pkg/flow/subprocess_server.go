@@ -0,0 +1,213 @@
+package flow
+
+// subprocess_server.go 为 Go 编写的进程外插件提供协议服务端。
+// 插件 main 包示例：
+//
+//   func main() {
+//       if err := flow.ServeSubprocess(&pluginImpl{}); err != nil {
+//           log.Fatal(err)
+//       }
+//   }
+//
+// 编译为可执行文件并以 .flow 为后缀放入插件目录即可被 Loader 加载：
+//   go build -o plugins/novel_v2.flow ./flows/novel_subprocess
+//
+// stdout 专用于协议消息，插件日志请写 stderr（标准库 log 默认即为 stderr）。
+
+import (
+    "bufio"
+    "context"
+    "encoding/json"
+    "fmt"
+    "io"
+    "os"
+    "runtime/debug"
+    "sync"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+// ServeSubprocess 构造插件工作流并在 stdin/stdout 上提供服务，直至 stdin 关闭。
+// Build 失败或路由指向的 Agent 不在插件 Agent 树中时返回错误，调用方应以非零
+// 状态退出，由宿主按退避策略重试。
+func ServeSubprocess(fp FlowPlugin) error {
+    return serveSubprocess(fp, nil, os.Stdin, os.Stdout)
+}
+
+// ServeSubprocessWithHost 与 ServeSubprocess 相同，插件实现 HostedFlowPlugin 时
+// 以 BuildWithHost(host) 构造工作流。子进程无法共享宿主进程内的服务实例，
+// 通常由插件 main 按同一份配置自行创建 ServiceHost。
+func ServeSubprocessWithHost(fp FlowPlugin, host Host) error {
+    return serveSubprocess(fp, host, os.Stdin, os.Stdout)
+}
+
+func serveSubprocess(fp FlowPlugin, host Host, r io.Reader, w io.Writer) error {
+    agent, err := buildPlugin(fp, host)
+    if err != nil {
+        return err
+    }
+    routes := pluginRoutes(fp)
+    if err := ValidateRoutes(agent, routes); err != nil {
+        return fmt.Errorf("路由校验失败: %w", err)
+    }
+    desc := DescribeResult{
+        Name:        fp.Name(),
+        Version:     pluginVersion(fp),
+        Description: agent.Description(),
+        Routes:      routes,
+        Agents:      subAgentNames(agent),
+    }
+    srv := &subprocessServer{
+        agent:   agent,
+        desc:    desc,
+        w:       w,
+        cancels: make(map[int64]context.CancelFunc),
+    }
+    return srv.serve(r)
+}
+
+// subAgentNames 返回 agent 树中根以外的 Agent 名称，按深度优先顺序。
+func subAgentNames(agent *agents.Agent) []string {
+    var names []string
+    for _, sub := range agent.SubAgents() {
+        names = append(names, sub.Name())
+        names = append(names, subAgentNames(sub)...)
+    }
+    return names
+}
+
+type subprocessServer struct {
+    agent *agents.Agent
+    desc  DescribeResult
+
+    writeMu sync.Mutex
+    w       io.Writer
+
+    mu      sync.Mutex
+    cancels map[int64]context.CancelFunc
+    wg      sync.WaitGroup
+}
+
+func (s *subprocessServer) serve(r io.Reader) error {
+    sc := bufio.NewScanner(r)
+    sc.Buffer(make([]byte, 64*1024), subprocessMaxLine)
+    for sc.Scan() {
+        var msg rpcMessage
+        if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+            s.reply(nil, nil, &RPCError{Code: RPCParseError, Message: err.Error()})
+            continue
+        }
+        switch msg.Method {
+        case MethodDescribe:
+            s.reply(msg.ID, s.desc, nil)
+        case MethodProcess, MethodStream:
+            var params ProcessParams
+            if err := json.Unmarshal(msg.Params, &params); err != nil || msg.ID == nil {
+                s.reply(msg.ID, nil, &RPCError{Code: RPCInvalidParams, Message: "invalid params"})
+                continue
+            }
+            // 在读取循环中登记取消函数，紧随其后到达的 $/cancel 不会丢失
+            ctx, cancel := context.WithCancel(context.Background())
+            s.mu.Lock()
+            s.cancels[*msg.ID] = cancel
+            s.mu.Unlock()
+            s.wg.Add(1)
+            go s.process(ctx, *msg.ID, msg.Method == MethodStream, params)
+        case MethodCancel:
+            var params CancelParams
+            if json.Unmarshal(msg.Params, &params) == nil {
+                s.mu.Lock()
+                if cancel, ok := s.cancels[params.ID]; ok {
+                    cancel()
+                }
+                s.mu.Unlock()
+            }
+        default:
+            if msg.ID != nil {
+                s.reply(msg.ID, nil, &RPCError{Code: RPCMethodNotFound, Message: "method not found: " + msg.Method})
+            }
+        }
+    }
+    // stdin 关闭：取消进行中的请求并等待其返回
+    s.mu.Lock()
+    for _, cancel := range s.cancels {
+        cancel()
+    }
+    s.mu.Unlock()
+    s.wg.Wait()
+    return sc.Err()
+}
+
+// process 执行一次请求，panic 被转换为错误响应而不会终止进程。
+// ctx 由 serve 创建，其取消函数已登记在 s.cancels 中。
+func (s *subprocessServer) process(ctx context.Context, id int64, stream bool, params ProcessParams) {
+    defer s.wg.Done()
+    defer func() {
+        s.mu.Lock()
+        if cancel, ok := s.cancels[id]; ok {
+            cancel()
+            delete(s.cancels, id)
+        }
+        s.mu.Unlock()
+    }()
+    defer func() {
+        if r := recover(); r != nil {
+            fmt.Fprintf(os.Stderr, "process panic: %v\n%s", r, debug.Stack())
+            s.reply(&id, nil, &RPCError{Code: RPCProcessError, Message: fmt.Sprintf("panic: %v", r)})
+        }
+    }()
+
+    // 与宿主一致，将用户标识与归档标识注入 context
+    ctx = context.WithValue(ctx, "user_id", params.UserID)
+    ctx = context.WithValue(ctx, "archive_id", params.ArchiveID)
+
+    target := s.agent
+    if params.Agent != "" {
+        if target = FindAgent(s.agent, params.Agent); target == nil {
+            s.reply(&id, nil, &RPCError{Code: RPCInvalidParams, Message: "agent not found: " + params.Agent})
+            return
+        }
+    }
+    output, err := target.Process(ctx, params.Input)
+    if err != nil {
+        s.reply(&id, nil, &RPCError{Code: RPCProcessError, Message: err.Error()})
+        return
+    }
+    if stream && output != "" {
+        // Agent 暂无增量输出，整体作为一个片段下发
+        s.notify(MethodStreamChunk, ChunkParams{ID: id, Data: output})
+    }
+    s.reply(&id, ProcessResult{Output: output}, nil)
+}
+
+func (s *subprocessServer) reply(id *int64, result interface{}, rpcErr *RPCError) {
+    msg := rpcMessage{ID: id, Error: rpcErr}
+    if result != nil {
+        raw, err := json.Marshal(result)
+        if err != nil {
+            msg.Error = &RPCError{Code: RPCInternalError, Message: err.Error()}
+        } else {
+            msg.Result = raw
+        }
+    }
+    s.write(msg)
+}
+
+func (s *subprocessServer) notify(method string, params interface{}) {
+    raw, err := json.Marshal(params)
+    if err != nil {
+        return
+    }
+    s.write(rpcMessage{Method: method, Params: raw})
+}
+
+func (s *subprocessServer) write(msg rpcMessage) {
+    msg.JSONRPC = "2.0"
+    line, err := json.Marshal(msg)
+    if err != nil {
+        return
+    }
+    s.writeMu.Lock()
+    defer s.writeMu.Unlock()
+    s.w.Write(append(line, '\n'))
+}
@@ -0,0 +1,167 @@
+package flow
+
+import (
+    "bytes"
+    "context"
+    "errors"
+    "io"
+    "log"
+    "os"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/nvcnvn/adk-golang/pkg/agents"
+)
+
+const subprocessHelperEnv = "ADK_FLOW_SUBPROCESS_HELPER"
+
+// helperPlugin 为测试用的进程外插件，按输入触发不同行为
+type helperPlugin struct{}
+
+func (helperPlugin) Name() string    { return "helper_flow" }
+func (helperPlugin) Version() string { return "v2" }
+func (helperPlugin) Build() (*agents.Agent, error) {
+    return agents.NewAgent(
+        agents.WithName("helper_flow"),
+        agents.WithDescription("subprocess helper"),
+        agents.WithBeforeAgentCallback(func(ctx context.Context, msg string) (string, bool) {
+            switch msg {
+            case "panic":
+                panic("boom")
+            case "crash":
+                os.Exit(3)
+            case "wait":
+                <-ctx.Done()
+                return "cancelled", true
+            case "flood":
+                // 超过单条消息上限的输出行，之后进程继续运行
+                os.Stdout.Write(bytes.Repeat([]byte("x"), subprocessMaxLine+1))
+                time.Sleep(time.Hour)
+            }
+            user, _ := ctx.Value("user_id").(string)
+            return user + ":" + strings.ToUpper(msg), true
+        }),
+        agents.WithSubAgents(agents.NewAgent(
+            agents.WithName("helper_echo"),
+            agents.WithProcessFunc(func(ctx context.Context, msg string) (string, error) {
+                return "echo:" + msg, nil
+            }),
+        )),
+    ), nil
+}
+
+// Routes 声明指向子 Agent 的路由
+func (helperPlugin) Routes() []RouteConfig {
+    return []RouteConfig{{Path: "/helper/echo", Agent: "helper_echo"}}
+}
+
+// TestMain 在设置了环境变量时让测试二进制作为插件进程运行
+func TestMain(m *testing.M) {
+    if os.Getenv(subprocessHelperEnv) == "1" {
+        if err := ServeSubprocess(helperPlugin{}); err != nil {
+            log.Fatal(err)
+        }
+        os.Exit(0)
+    }
+    os.Exit(m.Run())
+}
+
+func TestSubprocessPlugin(t *testing.T) {
+    t.Setenv(subprocessHelperEnv, "1")
+    sp := NewSubprocessPlugin(os.Args[0])
+    if err := sp.Start(context.Background()); err != nil {
+        t.Fatalf("启动失败: %v", err)
+    }
+    defer sp.Close()
+
+    if info := sp.Info(); info.Name != "helper_flow" || info.Version != "v2" {
+        t.Fatalf("describe 结果错误: %+v", info)
+    }
+
+    ctx := context.WithValue(context.Background(), "user_id", "u1")
+    out, err := sp.Agent().Process(ctx, "hello")
+    if err != nil || out != "u1:HELLO" {
+        t.Fatalf("process 结果错误: %q, %v", out, err)
+    }
+
+    var chunks []string
+    out, err = sp.Stream(ctx, "hi", func(s string) { chunks = append(chunks, s) })
+    if err != nil || out != "u1:HI" || len(chunks) != 1 {
+        t.Fatalf("stream 结果错误: %q, %v, %v", out, chunks, err)
+    }
+
+    // panic 只影响当前请求
+    var rpcErr *RPCError
+    if _, err := sp.Process(ctx, "panic"); !errors.As(err, &rpcErr) {
+        t.Fatalf("期望 RPCError，实际 %v", err)
+    }
+    if out, err := sp.Process(ctx, "again"); err != nil || out != "u1:AGAIN" {
+        t.Fatalf("panic 后进程应继续服务: %q, %v", out, err)
+    }
+
+    // 进程崩溃后自动重启
+    if _, err := sp.Process(ctx, "crash"); !errors.Is(err, ErrSubprocessExited) {
+        t.Fatalf("期望 ErrSubprocessExited，实际 %v", err)
+    }
+    deadline := time.Now().Add(5 * time.Second)
+    for sp.Restarts() == 0 && time.Now().Before(deadline) {
+        time.Sleep(50 * time.Millisecond)
+    }
+    if out, err := sp.Process(ctx, "back"); err != nil || out != "u1:BACK" {
+        t.Fatalf("重启后调用失败: %q, %v", out, err)
+    }
+
+    // 超长输出行使宿主终止进程，挂起的调用立即失败
+    floodCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+    defer cancel()
+    if _, err := sp.Process(floodCtx, "flood"); !errors.Is(err, ErrSubprocessExited) {
+        t.Fatalf("期望 ErrSubprocessExited，实际 %v", err)
+    }
+
+    sp.Close()
+    if _, err := sp.Process(ctx, "closed"); !errors.Is(err, ErrSubprocessUnavailable) && !errors.Is(err, ErrSubprocessExited) {
+        t.Fatalf("关闭后应不可用，实际 %v", err)
+    }
+}
+
+// TestSubprocessServerCancelBeforeStart 验证紧随请求到达的取消通知不会在处理协程启动前丢失
+func TestSubprocessServerCancelBeforeStart(t *testing.T) {
+    r, w := io.Pipe()
+    out := &lockedBuffer{}
+    done := make(chan error, 1)
+    go func() { done <- serveSubprocess(helperPlugin{}, nil, r, out) }()
+
+    w.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"process","params":{"input":"wait"}}` + "\n" +
+        `{"jsonrpc":"2.0","method":"cancel","params":{"id":1}}` + "\n"))
+    deadline := time.Now().Add(5 * time.Second)
+    for !strings.Contains(out.String(), `"cancelled"`) {
+        if time.Now().After(deadline) {
+            t.Fatalf("取消通知丢失，请求未结束: %s", out.String())
+        }
+        time.Sleep(10 * time.Millisecond)
+    }
+    w.Close()
+    if err := <-done; err != nil {
+        t.Fatal(err)
+    }
+}
+
+// lockedBuffer 为并发安全的 bytes.Buffer
+type lockedBuffer struct {
+    mu  sync.Mutex
+    buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    return b.buf.String()
+}
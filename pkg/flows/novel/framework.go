@@ -5,9 +5,6 @@ package novel
 
 import (
     "context"
-    "fmt"
-    "strings"
-    "sync"
 
     "github.com/nvcnvn/adk-golang/pkg/agents"
     "github.com/nvcnvn/adk-golang/pkg/logger"
@@ -49,14 +46,23 @@ func Build() *agents.Agent {
     formatter := agents.NewAgent(
         agents.WithName("formatter_agent"),
         agents.WithModel("deepseek-chat"),
-        agents.WithInstruction("你将接收来自其他 Agent 的 JSON 片段（可能为按行输出或数组）。请整合并输出最终统一的 JSON，结构固定为:{\"worldview\":...,\"characters\":...,\"plot\":...,\"dialogues\":...,\"background\":...}。输出要求：1) 仅输出该 JSON 对象，不要任何额外文本；2) 保持 UTF-8 编码，无转义换行；3) 字段顺序与示例完全一致；4) 确保可被标准 JSON 解析器解析。"),
+        agents.WithInstruction("你将接收一个以 Agent 名称（worldview_agent、character_agent 等）为键、以该 Agent 输出的 JSON 片段为值的 JSON 对象。请整合并输出最终统一的 JSON，结构固定为:{\"worldview\":...,\"characters\":...,\"plot\":...,\"dialogues\":...,\"background\":...}。输出要求：1) 仅输出该 JSON 对象，不要任何额外文本；2) 保持 UTF-8 编码，无转义换行；3) 字段顺序与示例完全一致；4) 确保可被标准 JSON 解析器解析。"),
         agents.WithDescription("格式化Agent"),
     )
 
-    executionLayer := agents.NewParallelAgent(agents.ParallelAgentConfig{
+    // 执行层：创作类 Agent 并行产出 JSON 片段，按 Agent 名称汇总后由格式化 Agent 整合；
+    // 单个创作 Agent 失败时记录日志并跳过，其余输出仍交给格式化 Agent
+    creationLayer := agents.NewParallelAgent(agents.ParallelAgentConfig{
+        Name:         "creation_layer",
+        Description:  "执行层并行创作",
+        SubAgents:    []*agents.Agent{worldview, character, plot, dialogue, background},
+        Aggregation:  agents.AggregateJSON,
+        AllowPartial: true,
+    })
+    executionLayer := agents.NewSequentialAgent(agents.SequentialAgentConfig{
         Name:        "execution_layer",
-        Description: "执行层并行汇总",
-        SubAgents:   []*agents.Agent{worldview, character, plot, dialogue, background, formatter},
+        Description: "执行层并行创作后格式化汇总",
+        Agents:      []agents.Runnable{creationLayer, formatter},
     })
 
     // 决策层
@@ -88,118 +94,29 @@ func Build() *agents.Agent {
     root := agents.NewSequentialAgent(agents.SequentialAgentConfig{
         Name:        "adk",
         Description: "NovelAI 分层智能体 (DeepSeek)",
-        SubAgents:   []*agents.Agent{&decisionLayer.Agent, &executionLayer.Agent},
+        Agents:      []agents.Runnable{decisionLayer, executionLayer},
     })
 
-    // 设置子代理处理逻辑
-    // 注意：避免在回调中调用自身Process方法，防止无限递归
-    
-    // 决策层处理逻辑
-    decisionLayer.Agent.SetBeforeAgentCallback(func(ctx context.Context, msg string) (string, bool) {
-        logger.S().Infof("[决策层] 处理输入：%s", truncateString(msg, 30))
-        // 依次调用子代理，而非recursively调用整个决策层Process
-        currentMessage := msg
-        
-        // 策略分析
-        strategyMsg, err := strategy.Process(ctx, currentMessage)
-        if err != nil {
-            return fmt.Sprintf("[策略层错误]: %v", err), true
-        }
-        currentMessage = strategyMsg
-        
-        // 规划执行
-        planMsg, err := planner.Process(ctx, currentMessage)
-        if err != nil {
-            return fmt.Sprintf("[规划层错误]: %v", err), true
-        }
-        currentMessage = planMsg
-        
-        // 评估结果
-        evalMsg, err := evaluator.Process(ctx, currentMessage)
-        if err != nil {
-            return fmt.Sprintf("[评估层错误]: %v", err), true
-        }
-        
-        logger.S().Info("[决策层] 完成处理")
-        return evalMsg, true
-    })
-    
-    // 执行层处理逻辑
-    executionLayer.Agent.SetBeforeAgentCallback(func(ctx context.Context, msg string) (string, bool) {
-        logger.S().Infof("[执行层] 处理输入：%s", truncateString(msg, 30))
-        
-        // 收集所有子代理的结果
-        type agentResult struct {
-            agent string
-            output string
-            err error
-        }
-        
-        resultsCh := make(chan agentResult, len(executionLayer.SubAgents()))
-        var wg sync.WaitGroup
-        
-        // 并行调用所有子代理
-        for _, subAgent := range executionLayer.SubAgents() {
-            if subAgent == formatter { // 格式化代理最后处理
-                continue
-            }
-            
-            wg.Add(1)
-            go func(agent *agents.Agent) {
-                defer wg.Done()
-                output, err := agent.Process(ctx, msg)
-                resultsCh <- agentResult{agent.Name(), output, err}
-            }(subAgent)
-        }
-        
-        // 等待所有子代理完成
-        wg.Wait()
-        close(resultsCh)
-        
-        // 收集结果
-        results := make(map[string]string)
-        for result := range resultsCh {
-            if result.err != nil {
-                logger.S().Errorf("[执行层] 子代理 %s 错误: %v", result.agent, result.err)
-                continue
-            }
-            results[result.agent] = result.output
-        }
-        
-        // 合并结果供formatter使用
-        formatterInput := fmt.Sprintf("子代理结果:\n%s", formatResults(results))
-        formatterOutput, err := formatter.Process(ctx, formatterInput)
-        if err != nil {
-            return fmt.Sprintf("[格式化错误]: %v", err), true
-        }
-        
-        logger.S().Info("[执行层] 完成处理")
-        return formatterOutput, true
-    })
-    
-    // 根代理处理逻辑
-    root.Agent.SetBeforeAgentCallback(func(ctx context.Context, msg string) (string, bool) {
-        logger.S().Infof("[根代理] 处理输入：%s", truncateString(msg, 30))
-        
-        // 决策层处理
-        decisionOutput, err := decisionLayer.Agent.Process(ctx, msg)
-        if err != nil {
-            return fmt.Sprintf("[决策层错误]: %v", err), true
-        }
-        
-        // 执行层处理
-        executionOutput, err := executionLayer.Agent.Process(ctx, decisionOutput)
-        if err != nil {
-            return fmt.Sprintf("[执行层错误]: %v", err), true
-        }
-        
-        logger.S().Info("[根代理] 完成处理")
-        return executionOutput, true
-    })
+    // 编排由组合 Agent 完成，回调仅记录各层进度
+    logLayer(&root.Agent, "根代理")
+    logLayer(&decisionLayer.Agent, "决策层")
+    logLayer(&executionLayer.Agent, "执行层")
 
     return &root.Agent
 }
 
+// logLayer 在层级 Agent 处理前后记录日志。
+func logLayer(layer *agents.Agent, label string) {
+    layer.SetBeforeAgentCallback(func(ctx context.Context, msg string) (string, bool) {
+        logger.S().Infof("[%s] 处理输入：%s", label, truncateString(msg, 30))
+        return msg, false
+    })
+    layer.SetAfterAgentCallback(func(ctx context.Context, response string) string {
+        logger.S().Infof("[%s] 完成处理", label)
+        return response
+    })
+}
+
 // truncateString 截断字符串到指定长度，并添加省略号
 func truncateString(s string, maxLen int) string {
     if len(s) <= maxLen {
@@ -207,12 +124,3 @@ func truncateString(s string, maxLen int) string {
     }
     return s[:maxLen] + "..."
 }
-
-// formatResults 将子代理结果格式化为字符串
-func formatResults(results map[string]string) string {
-    var sb strings.Builder
-    for agent, output := range results {
-        sb.WriteString(fmt.Sprintf("%s: %s\n", agent, truncateString(output, 100)))
-    }
-    return sb.String()
-}
@@ -3,12 +3,14 @@ package save_novel_rag_data_workflow
 import (
 	"context"
 
+	"github.com/nvcnvn/adk-golang/pkg/logger"
 	"github.com/nvcnvn/adk-golang/pkg/memory"
 )
 
 // SaveNovelRagDataOptions 构建选项
 type SaveNovelRagDataOptions struct {
 	MemoryService memory.MemoryService
+	Backend       string // 记忆后端名称，从 memory 注册表解析，优先于 RAGBaseURL / RAGTopK
 	LLMModel      string
 	UserID        string
 	ArchiveID     string
@@ -16,10 +18,15 @@ type SaveNovelRagDataOptions struct {
 	RAGTopK       int
 }
 
-// NewSaveNovelRagDataServiceWithDefaults 使用默认RAG服务创建实例
-// 这是最常用的构造函数，自动创建CustomRagMemoryService
+// NewSaveNovelRagDataServiceWithDefaults 使用默认记忆后端创建实例
+// 这是最常用的构造函数，从 memory 注册表解析默认后端（配置文件的 memory.default），
+// 解析失败时创建默认的 CustomRagMemoryService
 func NewSaveNovelRagDataServiceWithDefaults(userID, archiveID string) *SaveNovelRagDataService {
-	ragService := memory.NewCustomRagMemoryServiceWithDefaults()
+	ragService, err := memory.Lookup("")
+	if err != nil {
+		logger.S().Warnw("解析默认记忆后端失败，使用默认 RAG 服务", "err", err)
+		ragService = memory.NewCustomRagMemoryServiceWithDefaults()
+	}
 	
 	config := SaveNovelRagDataConfig{
 		MemoryService: ragService,
@@ -33,6 +40,15 @@ func NewSaveNovelRagDataServiceWithDefaults(userID, archiveID string) *SaveNovel
 
 // NewSaveNovelRagDataServiceWithOptions 使用选项创建实例
 func NewSaveNovelRagDataServiceWithOptions(options SaveNovelRagDataOptions) *SaveNovelRagDataService {
+	// 指定了后端名称，或未指定 RAG 地址与 TopK 时从注册表解析（名称为空时为默认后端）
+	if options.MemoryService == nil && (options.Backend != "" || (options.RAGBaseURL == "" && options.RAGTopK <= 0)) {
+		if mem, err := memory.Lookup(options.Backend); err == nil {
+			options.MemoryService = mem
+		} else {
+			logger.S().Warnw("解析记忆后端失败，使用默认 RAG 服务", "backend", options.Backend, "err", err)
+		}
+	}
+
 	// 如果没有提供MemoryService，创建默认的
 	if options.MemoryService == nil {
 		if options.RAGBaseURL != "" {
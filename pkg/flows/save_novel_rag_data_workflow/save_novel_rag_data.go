@@ -2,7 +2,7 @@ package save_novel_rag_data_workflow
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -11,6 +11,7 @@ import (
 	"github.com/nvcnvn/adk-golang/pkg/events"
 	"github.com/nvcnvn/adk-golang/pkg/memory"
 	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/schema"
 	"github.com/nvcnvn/adk-golang/pkg/sessions"
 )
 
@@ -57,6 +58,30 @@ func NewSaveNovelRagDataService(config SaveNovelRagDataConfig) *SaveNovelRagData
 	}
 }
 
+// segmentationSchema 分段结果的输出约束，不符合时智能体会带着校验错误重新生成
+func segmentationSchema() *schema.Schema {
+	one := 1
+	return &schema.Schema{
+		Type:     "object",
+		Required: []string{"segments"},
+		Properties: map[string]*schema.Schema{
+			"segments": {
+				Type:     "array",
+				MinItems: &one,
+				Items: &schema.Schema{
+					Type:     "object",
+					Required: []string{"content"},
+					Properties: map[string]*schema.Schema{
+						"content": {Type: "string", MinLength: &one},
+						"type":    {Type: "string"},
+						"summary": {Type: "string"},
+					},
+				},
+			},
+		},
+	}
+}
+
 // createSegmentAgent 创建用于分段的智能体
 func createSegmentAgent(model string) *agents.Agent {
 	return agents.NewAgent(
@@ -82,6 +107,7 @@ func createSegmentAgent(model string) *agents.Agent {
 
 注意：输出必须是有效的JSON格式，不要包含任何其他文本。`),
 		agents.WithDescription("内容分段智能体"),
+		agents.WithOutputSchema(segmentationSchema()),
 	)
 }
 
@@ -104,21 +130,14 @@ func (s *SaveNovelRagDataService) SaveNovelData(ctx context.Context, content str
 		log.Printf("[SaveNovelRagData] 重点提取类型: %s", contentType[0])
 	}
 
-	// 调用LLM进行分段
-	response, err := s.segmentAgent.Process(ctx, prompt)
-	if err != nil {
-		return 0, fmt.Errorf("LLM分段处理失败: %w", err)
-	}
-
-	// 解析JSON结果
+	// 调用LLM进行分段，输出按 schema 校验并自动修复
 	var result SegmentationResult
-	if err := json.Unmarshal([]byte(response), &result); err != nil {
-		log.Printf("[SaveNovelRagData] JSON解析失败，尝试提取JSON部分")
-		// 尝试从响应中提取JSON部分
-		cleaned := extractJSONFromResponse(response)
-		if err := json.Unmarshal([]byte(cleaned), &result); err != nil {
-			return 0, fmt.Errorf("JSON解析失败: %w，原始响应: %s", err, response)
+	if err := s.segmentAgent.ProcessJSON(ctx, prompt, &result); err != nil {
+		var outErr *agents.OutputError
+		if errors.As(err, &outErr) {
+			return 0, fmt.Errorf("JSON解析失败: %w，原始响应: %s", outErr.Err, outErr.Raw)
 		}
+		return 0, fmt.Errorf("LLM分段处理失败: %w", err)
 	}
 
 	if len(result.Segments) == 0 {
@@ -171,25 +190,6 @@ func (s *SaveNovelRagDataService) saveSegmentToRAG(ctx context.Context, segment
 	return s.config.MemoryService.AddSessionToMemory(ctx, session)
 }
 
-// extractJSONFromResponse 从LLM响应中提取JSON部分
-func extractJSONFromResponse(response string) string {
-	response = strings.TrimSpace(response)
-	
-	// 查找JSON开始和结束标记
-	start := strings.Index(response, "{")
-	if start == -1 {
-		return response
-	}
-	
-	// 从最后一个}开始查找
-	end := strings.LastIndex(response, "}")
-	if end == -1 || end <= start {
-		return response
-	}
-	
-	return response[start : end+1]
-}
-
 // GetConfig 获取当前配置（用于测试等场景）
 func (s *SaveNovelRagDataService) GetConfig() SaveNovelRagDataConfig {
 	return s.config
@@ -81,41 +81,21 @@ func TestNewSaveNovelRagDataServiceWithDefaults(t *testing.T) {
 	}
 }
 
-func TestExtractJSONFromResponse(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "纯JSON",
-			input:    `{"segments": [{"content": "测试"}]}`,
-			expected: `{"segments": [{"content": "测试"}]}`,
-		},
-		{
-			name:     "带前后文本的JSON",
-			input:    `这是一段说明文字 {"segments": [{"content": "测试"}]} 还有后续文字`,
-			expected: `{"segments": [{"content": "测试"}]}`,
-		},
-		{
-			name:     "无JSON的文本",
-			input:    `这里没有JSON内容`,
-			expected: `这里没有JSON内容`,
-		},
-		{
-			name:     "嵌套JSON",
-			input:    `{"outer": {"inner": {"segments": [{"content": "测试"}]}}}`,
-			expected: `{"outer": {"inner": {"segments": [{"content": "测试"}]}}}`,
-		},
-	}
+func TestSaveNovelRagDataServiceUsesRegistry(t *testing.T) {
+	prev := memory.DefaultRegistry()
+	t.Cleanup(func() { memory.SetDefaultRegistry(prev) })
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := extractJSONFromResponse(tt.input)
-			if result != tt.expected {
-				t.Errorf("期望结果: %s，实际结果: %s", tt.expected, result)
-			}
-		})
+	local := memory.NewInMemoryMemoryService()
+	reg := memory.NewRegistry()
+	reg.Register("local", local)
+	reg.SetDefault("local")
+	memory.SetDefaultRegistry(reg)
+
+	if got := NewSaveNovelRagDataServiceWithDefaults("user123", "archive456").GetConfig().MemoryService; got != local {
+		t.Errorf("期望使用注册表的默认后端，实际为 %T", got)
+	}
+	if got := NewSaveNovelRagDataServiceWithOptions(SaveNovelRagDataOptions{UserID: "user123"}).GetConfig().MemoryService; got != local {
+		t.Errorf("未指定 RAG 地址时期望使用注册表的默认后端，实际为 %T", got)
 	}
 }
 
@@ -245,13 +225,3 @@ func TestNewSaveNovelRagDataServiceWithOptions(t *testing.T) {
 		})
 	}
 }
-
-// BenchmarkExtractJSONFromResponse 性能基准测试
-func BenchmarkExtractJSONFromResponse(b *testing.B) {
-	input := `这是一段很长的说明文字，包含很多细节。{"segments": [{"content": "测试内容", "type": "test", "summary": "测试摘要"}]} 还有更多的后续文字和说明。`
-	
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		extractJSONFromResponse(input)
-	}
-}
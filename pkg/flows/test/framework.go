@@ -15,6 +15,7 @@ import (
 
 // TestConfig 测试配置
 type TestConfig struct {
+	MemoryBackend  string `json:"memory_backend"` // quad 记忆后端名称，从 memory 注册表解析
 	GraphDBBaseURL string `json:"graphdb_base_url"`
 	RepositoryID   string `json:"repository_id"`
 	TestUserID     string `json:"test_user_id"`
@@ -40,14 +41,7 @@ type AgentTestResult struct {
 
 // BuildTestFramework 构建集成测试框架，结合 Novel 工作流和 Quad Memory 服务
 func BuildTestFramework(config TestConfig) *agents.Agent {
-	// 创建 Quad Memory 服务
-	memoryConfig := memory.QuadMemoryConfig{
-		BaseURL:      config.GraphDBBaseURL,
-		RepositoryID: config.RepositoryID,
-		MaxRetries:   3,
-	}
-
-	memoryService := memory.NewQuadMemoryService(memoryConfig)
+	memoryService := resolveQuadMemory(config)
 
 	// 创建增强版的 Novel Agents，集成 Memory 操作
 	worldviewAgent := createMemoryEnabledAgent(
@@ -127,6 +121,23 @@ func BuildTestFramework(config TestConfig) *agents.Agent {
 }
 
 // createMemoryEnabledAgent 创建具有记忆能力的Agent
+// resolveQuadMemory 从 memory 注册表解析 quad 后端；指定了 GraphDBBaseURL 且未指定后端名称，
+// 或注册表中没有可用的 quad 后端时，按 GraphDBBaseURL / RepositoryID 创建
+func resolveQuadMemory(config TestConfig) *memory.QuadMemoryService {
+	if config.MemoryBackend != "" || config.GraphDBBaseURL == "" {
+		svc, err := memory.DefaultRegistry().Quad(config.MemoryBackend)
+		if err == nil {
+			return svc
+		}
+		log.Printf("解析 quad 记忆后端失败，按 GraphDBBaseURL 创建: %v", err)
+	}
+	return memory.NewQuadMemoryService(memory.QuadMemoryConfig{
+		BaseURL:      config.GraphDBBaseURL,
+		RepositoryID: config.RepositoryID,
+		MaxRetries:   3,
+	})
+}
+
 func createMemoryEnabledAgent(name, description, instruction string, memoryService *memory.QuadMemoryService, agentType string) *agents.Agent {
 	agent := agents.NewAgent(
 		agents.WithName(name),
@@ -52,17 +52,25 @@ type LongTermPlanningConfig struct {
 type LongTermPlanningService struct {
     config         LongTermPlanningConfig
     planningAgents *agents.Agent
+    planningLoop   *agents.LoopAgent
 }
 
 const defaultMaxIterations = 10
 
+// 规划循环的自定义终止原因
+const (
+    stopReasonInvalidJSON   = "invalid_json"
+    stopReasonNoNextRequest = "no_next_request"
+)
+
 // NewLongTermPlanningService 创建新的服务实例
 func NewLongTermPlanningService(config LongTermPlanningConfig) *LongTermPlanningService {
-
-	return &LongTermPlanningService{
-		config:         config,
-		planningAgents: createPlanningAgent(config.LLMModel),
-	}
+    planningAgent := createPlanningAgent(config.LLMModel)
+    return &LongTermPlanningService{
+        config:         config,
+        planningAgents: planningAgent,
+        planningLoop:   createPlanningLoop(planningAgent),
+    }
 }
 
 func createPlanningAgent(model string) *agents.Agent {
@@ -76,44 +84,61 @@ func createPlanningAgent(model string) *agents.Agent {
     )
 }
 
+// createPlanningLoop 循环调用规划 Agent：
+// "finish" 为 true、响应不是 JSON 或缺少 next_request 时终止，否则以 next_request 作为下一轮输入。
+func createPlanningLoop(planningAgent *agents.Agent) *agents.LoopAgent {
+    return agents.NewLoopAgent(agents.LoopAgentConfig{
+        Name:          agentName + "_loop",
+        Description:   agentDescription,
+        SubAgents:     []*agents.Agent{planningAgent},
+        MaxIterations: defaultMaxIterations,
+        StopConditions: []agents.StopCondition{
+            agents.StopOnJSONField("finish", true),
+            agents.StopWhen(stopReasonInvalidJSON, func(output string) bool {
+                return parsePlan(output) == nil
+            }),
+            agents.StopWhen(stopReasonNoNextRequest, func(output string) bool {
+                next, _ := parsePlan(output)["next_request"].(string)
+                return next == ""
+            }),
+        },
+        NextInput: func(it agents.LoopIteration) string {
+            next, _ := parsePlan(it.Output)["next_request"].(string)
+            return next
+        },
+    })
+}
+
+// parsePlan 解析规划 JSON，失败时返回 nil
+func parsePlan(resp string) map[string]interface{} {
+    var payload map[string]interface{}
+    if err := json.Unmarshal([]byte(resp), &payload); err != nil {
+        return nil
+    }
+    return payload
+}
+
 // GeneratePlan 根据给定前提/提示生成长期规划。
 // 会循环调用 planningAgents，直到 "finish" 字段为 true，或达到最大迭代次数。
 func (s *LongTermPlanningService) GeneratePlan(ctx context.Context, premise string) (string, error) {
-    if s.planningAgents == nil {
+    if s.planningAgents == nil || s.planningLoop == nil {
         return "", fmt.Errorf("planning agent not initialized")
     }
 
-    currentRequest := premise
-    for i := 0; i < defaultMaxIterations; i++ {
-        // 1. 调用规划 Agent
-        resp, err := s.planningAgents.Process(ctx, currentRequest)
-        if err != nil {
-            return "", err
-        }
-
-        // 2. 解析 JSON 响应
-        var payload map[string]interface{}
-        if err := json.Unmarshal([]byte(resp), &payload); err != nil {
-            log.Printf("[LongTermPlanning] 无效的 JSON 响应: %v, raw=%s", err, resp)
-            return "", fmt.Errorf("invalid JSON response: %w", err)
-        }
-
-        // 3. 检查是否完成
-        if finish, ok := payload["finish"].(bool); ok && finish {
-            // TODO: 将最终规划保存到 MemoryService 或其他持久化工具
-            return resp, nil
-        }
-
-        // 4. 获取下一步请求
-        nextReq, ok := payload["next_request"].(string)
-        if !ok || nextReq == "" {
-            return resp, nil // 找不到 next_request，则视为终止
-        }
-
-        // TODO: 根据需要调用工具（检索 / 保存等），此处预留
+    result, err := s.planningLoop.RunLoop(ctx, premise)
+    if err != nil {
+        return "", err
+    }
 
-        currentRequest = nextReq
+    switch result.Reason {
+    case stopReasonInvalidJSON:
+        log.Printf("[LongTermPlanning] 无效的 JSON 响应, raw=%s", result.Output)
+        return "", fmt.Errorf("invalid JSON response after %d iterations", result.Iterations)
+    case agents.StopReasonMaxIterations:
+        return "", fmt.Errorf("max iterations(%d) reached without finish", defaultMaxIterations)
     }
 
-    return "", fmt.Errorf("max iterations(%d) reached without finish", defaultMaxIterations)
+    // finish 为 true，或找不到 next_request 视为终止
+    // TODO: 将最终规划保存到 MemoryService 或其他持久化工具
+    return result.Output, nil
 }
@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"sync"
 
 	"go.uber.org/zap"
@@ -10,6 +11,9 @@ import (
 var (
 	global *zap.Logger
 	once   sync.Once
+
+	// atomicLevel 为全局 logger 的动态级别，SetLevel 原子调整，无需重建 logger
+	atomicLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
 )
 
 // Init 初始化全局 logger。
@@ -21,8 +25,10 @@ func Init(level string, dev bool) (*zap.Logger, error) {
 		lv = zap.InfoLevel
 	}
 
+	// 始终使用同一个 atomicLevel，SetLevel / Level 无需与 Init 同步
+	atomicLevel.SetLevel(lv)
 	cfg := zap.Config{
-		Level:            zap.NewAtomicLevelAt(lv),
+		Level:            atomicLevel,
 		Development:      dev,
 		Encoding:         "json",
 		OutputPaths:      []string{"stdout"},
@@ -80,6 +86,22 @@ func Set(l *zap.Logger) {
 	_ = zap.RedirectStdLog(l)
 }
 
+// SetLevel 原子地调整全局 logger 的级别，对已派生的子 logger 同样生效。
+// 通过 Set 注入的外部 logger 不受影响。
+func SetLevel(lv string) error {
+	var parsed zapcore.Level
+	if err := parsed.UnmarshalText([]byte(lv)); err != nil {
+		return fmt.Errorf("无效的日志级别 %q: %w", lv, err)
+	}
+	atomicLevel.SetLevel(parsed)
+	return nil
+}
+
+// Level 返回全局 logger 当前的级别。
+func Level() string {
+	return atomicLevel.Level().String()
+}
+
 // Sync 刷新日志缓冲区，通常在程序退出时调用
 func Sync() {
 	_ = L().Sync()
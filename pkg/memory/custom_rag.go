@@ -31,26 +31,57 @@ type CustomRagMemoryService struct {
 	// SimilarityTopK is the default top-k for SearchMemory if not overridden
 	SimilarityTopK int
 
+	// MaxRetries is the number of attempts for network errors and 5xx responses
+	MaxRetries int
+
+	// RetryBackoff is the linear backoff unit between attempts
+	RetryBackoff time.Duration
+
 	// HTTP client reused across requests
 	httpClient *http.Client
 
 	mu sync.RWMutex
 }
 
+// CustomRagConfig configures a CustomRagMemoryService. Zero values use defaults.
+type CustomRagConfig struct {
+	BaseURL        string        // 默认 http://host.docker.internal:18000
+	SimilarityTopK int           // 默认 10
+	Timeout        time.Duration // 单次请求超时，默认 20s
+	MaxRetries     int           // 网络错误或 5xx 时的最大尝试次数，默认 3
+	RetryBackoff   time.Duration // 线性退避基数，默认 500ms
+}
+
 // NewCustomRagMemoryService creates a new service instance.
 func NewCustomRagMemoryService(baseURL string, similarityTopK int) *CustomRagMemoryService {
-	if similarityTopK <= 0 {
-		similarityTopK = 10
+	return NewCustomRagMemoryServiceWithConfig(CustomRagConfig{BaseURL: baseURL, SimilarityTopK: similarityTopK})
+}
+
+// NewCustomRagMemoryServiceWithConfig creates a service instance from cfg.
+func NewCustomRagMemoryServiceWithConfig(cfg CustomRagConfig) *CustomRagMemoryService {
+	if cfg.SimilarityTopK <= 0 {
+		cfg.SimilarityTopK = 10
 	}
-	if baseURL == "" {
+	if cfg.BaseURL == "" {
 		// to be inside docker container
-		baseURL = "http://host.docker.internal:18000"
+		cfg.BaseURL = "http://host.docker.internal:18000"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 20 * time.Second
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = 500 * time.Millisecond
 	}
 	return &CustomRagMemoryService{
-		BaseURL:        strings.TrimRight(baseURL, "/"),
-		SimilarityTopK: similarityTopK,
+		BaseURL:        strings.TrimRight(cfg.BaseURL, "/"),
+		SimilarityTopK: cfg.SimilarityTopK,
+		MaxRetries:     cfg.MaxRetries,
+		RetryBackoff:   cfg.RetryBackoff,
 		httpClient: &http.Client{
-			Timeout: 20 * time.Second,
+			Timeout: cfg.Timeout,
 		},
 	}
 }
@@ -119,7 +150,6 @@ type healthCheckResponse struct {
 func (c *CustomRagMemoryService) AddSessionToMemory(ctx context.Context, session *sessions.Session) error {
 	c.mu.RLock()
 	baseURL := c.BaseURL
-	client := c.httpClient
 	c.mu.RUnlock()
 
 	// 使用 AppName(userID) + ID(archiveID) 拼接作为 tenant_id，确保archive级别隔离
@@ -161,20 +191,34 @@ func (c *CustomRagMemoryService) AddSessionToMemory(ctx context.Context, session
 	// ------------------------------------------------------------------
 	// FastAPI + Milvus 在首次写入新 tenant 时，如果对应集合尚未创建，
 	// 会先抛出 500（Milvus CollectionNotExists），随后自动去创建集合。
-	// 为了让客户端“写一次就成功”，这里增加一次簡單的重试逻輯：
+	// 为了让客户端“写一次就成功”，这里按 MaxRetries 重试（见 postWithRetry）：
 	//   1. 仅当网络错误或 HTTP >= 500 时才重试；
-	//   2. 最多 3 次，线性退避 0.5s、1s；
+	//   2. 线性退避 RetryBackoff、2*RetryBackoff ...；
 	//   3. 若遇到 4xx（参数错误、鉴权失败等）立即返回，不做重试。
 	// 这样即可避免第一次 500 导致工作流失败，同时不会给正常错误造成无限重试。
 	// ------------------------------------------------------------------
-	const maxRetries = 3
-	var lastErr error
+	_, err := c.postWithRetry(ctx, baseURL+"/add_session", body, "add_session")
+	return err
+}
+
+// postWithRetry 发送 JSON POST 请求，网络错误或 5xx 时按 MaxRetries 线性退避重试，
+// 4xx 立即返回。成功时返回响应体。
+func (c *CustomRagMemoryService) postWithRetry(ctx context.Context, url string, body []byte, op string) ([]byte, error) {
+	c.mu.RLock()
+	client := c.httpClient
+	maxRetries := c.MaxRetries
+	backoff := c.RetryBackoff
+	c.mu.RUnlock()
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
 
+	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		// 每次循环都重新创建 *http.Request，避免 body 在前一次已被读取。
-		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/add_session", bytes.NewReader(body))
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 		if err != nil {
-			return fmt.Errorf("create request failed: %w", err)
+			return nil, fmt.Errorf("create request failed: %w", err)
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
 
@@ -182,34 +226,31 @@ func (c *CustomRagMemoryService) AddSessionToMemory(ctx context.Context, session
 		if err != nil {
 			lastErr = fmt.Errorf("http request error: %w", err)
 		} else {
-			defer resp.Body.Close()
+			respBody, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
 			if resp.StatusCode < 300 {
-				return nil // 成功写入
+				return respBody, nil
 			}
-
-			respBody, _ := io.ReadAll(resp.Body)
 			lastErr = fmt.Errorf("rag service returned %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
 
 			// 遇到 4xx 直接返回，不做重试
 			if resp.StatusCode < 500 {
-				break
+				return nil, lastErr
 			}
 		}
 
 		// 若未达到最大次数，则等待后重试
 		if attempt < maxRetries {
-			wait := time.Duration(500*attempt) * time.Millisecond
-			logger.S().Warnw("CustomRAG add_session failed, will retry", "attempt", attempt, "max", maxRetries, "err", lastErr, "wait", wait)
+			wait := time.Duration(attempt) * backoff
+			logger.S().Warnw("CustomRAG request failed, will retry", "op", op, "attempt", attempt, "max", maxRetries, "err", lastErr, "wait", wait)
 			select {
 			case <-time.After(wait):
 			case <-ctx.Done():
-				return ctx.Err()
+				return nil, ctx.Err()
 			}
-			continue
 		}
 	}
-
-	return lastErr
+	return nil, lastErr
 }
 
 // SearchMemory queries the external RAG service for related contents.
@@ -217,7 +258,6 @@ func (c *CustomRagMemoryService) AddSessionToMemory(ctx context.Context, session
 func (c *CustomRagMemoryService) SearchMemory(ctx context.Context, appName, userID, archiveID, query string) (*SearchMemoryResponse, error) {
 	c.mu.RLock()
 	baseURL := c.BaseURL
-	client := c.httpClient
 	topK := c.SimilarityTopK
 	c.mu.RUnlock()
 
@@ -230,25 +270,13 @@ func (c *CustomRagMemoryService) SearchMemory(ctx context.Context, appName, user
 	}
 
 	body, _ := json.Marshal(reqPayload)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/search_memory", bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("create request failed: %w", err)
-	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := client.Do(httpReq)
+	respBody, err := c.postWithRetry(ctx, baseURL+"/search_memory", body, "search_memory")
 	if err != nil {
-		return nil, fmt.Errorf("http request error: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 300 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("rag service returned %d: %s", resp.StatusCode, string(respBody))
+		return nil, err
 	}
 
 	var ragResp searchMemoryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&ragResp); err != nil {
+	if err := json.Unmarshal(respBody, &ragResp); err != nil {
 		return nil, fmt.Errorf("decode response failed: %w", err)
 	}
 
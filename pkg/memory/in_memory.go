@@ -58,7 +58,9 @@ func (s *InMemoryMemoryService) AddSessionToMemory(ctx context.Context, session
 }
 
 // SearchMemory implements MemoryService.SearchMemory.
-func (s *InMemoryMemoryService) SearchMemory(ctx context.Context, appName, userID, query string) (*SearchMemoryResponse, error) {
+// A non-empty archiveID restricts the search to the session with that ID,
+// matching the archive isolation of the remote services.
+func (s *InMemoryMemoryService) SearchMemory(ctx context.Context, appName, userID, archiveID, query string) (*SearchMemoryResponse, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -74,6 +76,9 @@ func (s *InMemoryMemoryService) SearchMemory(ctx context.Context, appName, userI
 		if !strings.HasPrefix(key, prefix) {
 			continue
 		}
+		if archiveID != "" && extractSessionID(key) != archiveID {
+			continue
+		}
 
 		var matchedEvents []*events.Event
 		for _, event := range sessionEvents {
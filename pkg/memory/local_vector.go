@@ -0,0 +1,213 @@
+package memory
+
+// local_vector.go 实现无需外部服务的进程内向量记忆。
+//
+// 文本通过特征哈希（英文按词、中文按单字与相邻双字）映射为固定维度向量，
+// 检索时按余弦相似度排序取 top-k。适用于本地开发、单机部署与测试，
+// 数据不持久化，进程重启后丢失。租户隔离方式与 CustomRag 一致：userID_archiveID。
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+// 本地向量记忆默认参数。
+const (
+	DefaultLocalVectorDimensions = 512
+	DefaultLocalVectorTopK       = 10
+)
+
+// LocalVectorMemoryService 为进程内向量检索记忆服务。
+type LocalVectorMemoryService struct {
+	dimensions int
+	topK       int
+
+	mu      sync.RWMutex
+	entries map[string][]*vectorEntry // tenant_id -> 条目
+}
+
+// vectorEntry 为一条已向量化的事件。
+type vectorEntry struct {
+	sessionID string
+	event     *events.Event
+	vector    []float64
+}
+
+// NewLocalVectorMemoryService 创建服务实例，dimensions / topK 不大于 0 时使用默认值。
+func NewLocalVectorMemoryService(dimensions, topK int) *LocalVectorMemoryService {
+	if dimensions <= 0 {
+		dimensions = DefaultLocalVectorDimensions
+	}
+	if topK <= 0 {
+		topK = DefaultLocalVectorTopK
+	}
+	return &LocalVectorMemoryService{
+		dimensions: dimensions,
+		topK:       topK,
+		entries:    make(map[string][]*vectorEntry),
+	}
+}
+
+// AddSessionToMemory 将会话中的文本事件向量化后写入，重复写入同一会话会替换旧内容。
+func (s *LocalVectorMemoryService) AddSessionToMemory(ctx context.Context, session *sessions.Session) error {
+	if session == nil {
+		return fmt.Errorf("session is nil")
+	}
+	// 与 CustomRag 相同：AppName(userID) + ID(archiveID) 作为 tenant_id
+	tenantID := fmt.Sprintf("%s_%s", session.AppName, session.ID)
+
+	var added []*vectorEntry
+	for _, event := range session.Events {
+		text := eventText(event)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		added = append(added, &vectorEntry{
+			sessionID: session.ID,
+			event:     event,
+			vector:    s.embed(text),
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.entries[tenantID][:0:0]
+	for _, e := range s.entries[tenantID] {
+		if e.sessionID != session.ID {
+			kept = append(kept, e)
+		}
+	}
+	s.entries[tenantID] = append(kept, added...)
+	return nil
+}
+
+// SearchMemory 返回与 query 最相似的 top-k 条事件，按会话分组。
+func (s *LocalVectorMemoryService) SearchMemory(ctx context.Context, appName, userID, archiveID, query string) (*SearchMemoryResponse, error) {
+	tenantID := fmt.Sprintf("%s_%s", userID, archiveID)
+	qv := s.embed(query)
+
+	type scored struct {
+		entry *vectorEntry
+		score float64
+	}
+	s.mu.RLock()
+	var hits []scored
+	for _, e := range s.entries[tenantID] {
+		if score := cosine(qv, e.vector); score > 0 {
+			hits = append(hits, scored{e, score})
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+	if len(hits) > s.topK {
+		hits = hits[:s.topK]
+	}
+
+	response := &SearchMemoryResponse{Memories: []*MemoryResult{}}
+	bySession := make(map[string]*MemoryResult)
+	for _, h := range hits {
+		r, ok := bySession[h.entry.sessionID]
+		if !ok {
+			r = &MemoryResult{SessionID: h.entry.sessionID}
+			bySession[h.entry.sessionID] = r
+			response.Memories = append(response.Memories, r)
+		}
+		r.Events = append(r.Events, h.entry.event)
+	}
+	return response, nil
+}
+
+// embed 将文本哈希为 L2 归一化的词袋向量。
+func (s *LocalVectorMemoryService) embed(text string) []float64 {
+	vec := make([]float64, s.dimensions)
+	for _, tok := range vectorTokens(text) {
+		h := fnv.New32a()
+		h.Write([]byte(tok))
+		sum := h.Sum32()
+		// 最高位决定符号，降低哈希碰撞带来的偏差
+		if sum&0x80000000 != 0 {
+			vec[int(sum%uint32(s.dimensions))] -= 1
+		} else {
+			vec[int(sum%uint32(s.dimensions))] += 1
+		}
+	}
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	if norm > 0 {
+		norm = math.Sqrt(norm)
+		for i := range vec {
+			vec[i] /= norm
+		}
+	}
+	return vec
+}
+
+// vectorTokens 切分文本：字母数字按词，中日韩文字按单字与相邻双字。
+func vectorTokens(text string) []string {
+	var tokens []string
+	var word []rune
+	var prevHan rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			flush()
+			tokens = append(tokens, string(r))
+			if prevHan != 0 {
+				tokens = append(tokens, string([]rune{prevHan, r}))
+			}
+			prevHan = r
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prevHan = 0
+	}
+	flush()
+	return tokens
+}
+
+// cosine 计算两个已归一化向量的余弦相似度。
+func cosine(a, b []float64) float64 {
+	var dot float64
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
+
+// eventText 拼接事件中的全部文本片段。
+func eventText(event *events.Event) string {
+	if event == nil || event.Content == nil {
+		return ""
+	}
+	var text strings.Builder
+	for _, part := range event.Content.Parts {
+		if part.Text != "" {
+			if text.Len() > 0 {
+				text.WriteString("\n")
+			}
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String()
+}
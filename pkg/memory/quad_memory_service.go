@@ -22,6 +22,7 @@ type QuadMemoryConfig struct {
 	Password     string        // GraphDB 认证密码
 	MaxRetries   int           // 最大重试次数
 	RetryBackoff time.Duration // 重试间隔时间
+	Timeout      time.Duration // 单次请求超时，默认 20s
 }
 
 // QuadMemoryService 与远程四元组内存服务通信的客户端
@@ -43,11 +44,14 @@ func NewQuadMemoryService(config QuadMemoryConfig) *QuadMemoryService {
 	if config.RepositoryID == "" {
 		config.RepositoryID = "main" // 默认仓库ID
 	}
+	if config.Timeout <= 0 {
+		config.Timeout = 20 * time.Second // 默认超时时间
+	}
 
 	return &QuadMemoryService{
 		config: config,
 		httpClient: &http.Client{
-			Timeout: 20 * time.Second, // 默认超时时间
+			Timeout: config.Timeout,
 		},
 		logger: logger.S(),
 	}
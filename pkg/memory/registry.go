@@ -0,0 +1,224 @@
+package memory
+
+// registry.go 管理按名称配置的记忆后端。
+//
+// config.yaml 的 memory 段定义命名后端，宿主启动时以 NewRegistryFromConfig 构造
+// Registry 并通过 SetDefaultRegistry 设为全局实例；流程与工具以 Lookup(name) 解析后端，
+// name 为空时使用 memory.default。未设置全局实例时，默认仅包含与早期版本一致的
+// custom_rag 后端。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nvcnvn/adk-golang/pkg/config"
+)
+
+// ErrBackendNotFound 表示记忆后端未注册。
+var ErrBackendNotFound = errors.New("memory backend not found")
+
+// HealthChecker 由支持健康检查的后端实现（custom_rag / quad）。
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Registry 保存命名记忆后端。后端可能是 MemoryService，也可能是
+// *QuadMemoryService 等仅提供专用接口的服务。
+type Registry struct {
+	mu          sync.RWMutex
+	backends    map[string]interface{}
+	defaultName string
+}
+
+// NewRegistry 创建空的 Registry。
+func NewRegistry() *Registry {
+	return &Registry{backends: make(map[string]interface{})}
+}
+
+// NewRegistryFromConfig 按配置构造全部后端，返回遇到的所有构造错误。
+func NewRegistryFromConfig(cfg config.MemoryConfig) (*Registry, error) {
+	r := NewRegistry()
+	var errs []error
+	for _, name := range sortedBackendNames(cfg.Backends) {
+		svc, err := NewBackend(cfg.Backends[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("memory backend %s: %w", name, err))
+			continue
+		}
+		r.Register(name, svc)
+	}
+	if cfg.Default != "" {
+		r.SetDefault(cfg.Default)
+	}
+	return r, errors.Join(errs...)
+}
+
+// NewBackend 按单个后端配置创建服务实例。
+func NewBackend(cfg config.MemoryBackendConfig) (interface{}, error) {
+	timeout := time.Duration(cfg.TimeoutMs) * time.Millisecond
+	backoff := time.Duration(cfg.RetryBackoffMs) * time.Millisecond
+	switch cfg.Type {
+	case config.MemoryTypeCustomRag:
+		return NewCustomRagMemoryServiceWithConfig(CustomRagConfig{
+			BaseURL:        cfg.URL,
+			SimilarityTopK: cfg.TopK,
+			Timeout:        timeout,
+			MaxRetries:     cfg.MaxRetries,
+			RetryBackoff:   backoff,
+		}), nil
+	case config.MemoryTypeQuad:
+		return NewQuadMemoryService(QuadMemoryConfig{
+			BaseURL:      cfg.URL,
+			RepositoryID: cfg.Repository,
+			Username:     cfg.Username,
+			Password:     cfg.Password,
+			MaxRetries:   cfg.MaxRetries,
+			RetryBackoff: backoff,
+			Timeout:      timeout,
+		}), nil
+	case config.MemoryTypeInMemory:
+		return NewInMemoryMemoryService(), nil
+	case config.MemoryTypeLocalVector:
+		return NewLocalVectorMemoryService(cfg.Dimensions, cfg.TopK), nil
+	default:
+		return nil, fmt.Errorf("unknown memory type %q", cfg.Type)
+	}
+}
+
+// Register 注册命名后端。第一个注册的后端作为默认后端，可用 SetDefault 调整。
+func (r *Registry) Register(name string, svc interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[name] = svc
+	if r.defaultName == "" {
+		r.defaultName = name
+	}
+}
+
+// SetDefault 设置默认后端名称。
+func (r *Registry) SetDefault(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.defaultName = name
+}
+
+// Default 返回默认后端名称。
+func (r *Registry) Default() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.defaultName
+}
+
+// Get 返回命名后端，name 为空时返回默认后端。
+func (r *Registry) Get(name string) (interface{}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if name == "" {
+		name = r.defaultName
+	}
+	svc, ok := r.backends[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrBackendNotFound, name)
+	}
+	return svc, nil
+}
+
+// Memory 返回实现 MemoryService 的命名后端。
+func (r *Registry) Memory(name string) (MemoryService, error) {
+	svc, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	ms, ok := svc.(MemoryService)
+	if !ok {
+		return nil, fmt.Errorf("memory backend %q (%T) does not implement MemoryService", name, svc)
+	}
+	return ms, nil
+}
+
+// Quad 返回 quad 类型的命名后端。
+func (r *Registry) Quad(name string) (*QuadMemoryService, error) {
+	svc, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	q, ok := svc.(*QuadMemoryService)
+	if !ok {
+		return nil, fmt.Errorf("memory backend %q (%T) is not a quad backend", name, svc)
+	}
+	return q, nil
+}
+
+// Names 返回已注册的后端名称（已排序）。
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HealthCheck 对支持健康检查的后端逐一检查，返回失败的后端及原因。
+func (r *Registry) HealthCheck(ctx context.Context) map[string]error {
+	failed := make(map[string]error)
+	for _, name := range r.Names() {
+		svc, _ := r.Get(name)
+		if hc, ok := svc.(HealthChecker); ok {
+			if err := hc.HealthCheck(ctx); err != nil {
+				failed[name] = err
+			}
+		}
+	}
+	return failed
+}
+
+var (
+	defaultRegistryMu sync.RWMutex
+	defaultRegistry   *Registry
+)
+
+// DefaultRegistry 返回全局 Registry。
+func DefaultRegistry() *Registry {
+	defaultRegistryMu.RLock()
+	r := defaultRegistry
+	defaultRegistryMu.RUnlock()
+	if r != nil {
+		return r
+	}
+
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	if defaultRegistry == nil {
+		defaultRegistry = NewRegistry()
+		defaultRegistry.Register(config.MemoryTypeCustomRag, NewCustomRagMemoryServiceWithDefaults())
+	}
+	return defaultRegistry
+}
+
+// SetDefaultRegistry 替换全局 Registry，通常在宿主按配置构造后调用。
+func SetDefaultRegistry(r *Registry) {
+	defaultRegistryMu.Lock()
+	defer defaultRegistryMu.Unlock()
+	defaultRegistry = r
+}
+
+// Lookup 从全局 Registry 解析实现 MemoryService 的命名后端，name 为空时返回默认后端。
+func Lookup(name string) (MemoryService, error) {
+	return DefaultRegistry().Memory(name)
+}
+
+func sortedBackendNames(m map[string]config.MemoryBackendConfig) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
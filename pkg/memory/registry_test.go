@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/config"
+	"github.com/nvcnvn/adk-golang/pkg/events"
+	"github.com/nvcnvn/adk-golang/pkg/models"
+	"github.com/nvcnvn/adk-golang/pkg/sessions"
+)
+
+func TestRegistryFromConfig(t *testing.T) {
+	reg, err := NewRegistryFromConfig(config.MemoryConfig{
+		Default: "local",
+		Backends: map[string]config.MemoryBackendConfig{
+			"local": {Type: config.MemoryTypeLocalVector, TopK: 2},
+			"quad":  {Type: config.MemoryTypeQuad, URL: "http://graphdb:7200"},
+			"rag":   {Type: config.MemoryTypeCustomRag, URL: "http://rag:18000", TimeoutMs: 1500, MaxRetries: 5},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem, err := reg.Memory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mem.(*LocalVectorMemoryService); !ok {
+		t.Fatalf("default backend = %T, want *LocalVectorMemoryService", mem)
+	}
+	rag, err := reg.Memory("rag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := rag.(*CustomRagMemoryService); c.BaseURL != "http://rag:18000" || c.MaxRetries != 5 || c.httpClient.Timeout.Milliseconds() != 1500 {
+		t.Errorf("rag backend not configured from config: %+v", c)
+	}
+	if _, err := reg.Quad("quad"); err != nil {
+		t.Error(err)
+	}
+	if _, err := reg.Memory("quad"); err == nil {
+		t.Error("quad backend should not resolve as MemoryService")
+	}
+	if _, err := reg.Memory("missing"); !errors.Is(err, ErrBackendNotFound) {
+		t.Errorf("missing backend err = %v", err)
+	}
+}
+
+func TestLocalVectorMemoryService(t *testing.T) {
+	ctx := context.Background()
+	svc := NewLocalVectorMemoryService(0, 1)
+	text := func(s string) *events.Event {
+		return &events.Event{Author: "user", Content: &models.Content{Parts: []*models.Part{{Text: s}}}}
+	}
+	if err := svc.AddSessionToMemory(ctx, &sessions.Session{AppName: "u1", UserID: "u1", ID: "a1", Events: []*events.Event{
+		text("林远在雪山脚下的小镇长大"),
+		text("The dragon guards the northern pass"),
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := svc.SearchMemory(ctx, "app", "u1", "a1", "雪山小镇")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Memories) != 1 || len(res.Memories[0].Events) != 1 || eventText(res.Memories[0].Events[0]) != "林远在雪山脚下的小镇长大" {
+		t.Fatalf("unexpected result: %+v", res.Memories)
+	}
+
+	// 其他 archive 不可见
+	res, err = svc.SearchMemory(ctx, "app", "u1", "a2", "雪山小镇")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Memories) != 0 {
+		t.Fatalf("archive isolation broken: %+v", res.Memories)
+	}
+}
@@ -40,14 +40,17 @@ type CustomModel struct {
 
 // customChatMessage 表示聊天消息格式（兼容OpenAI格式）
 type customChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
 }
 
 // customChatRequest 表示聊天完成请求结构
 type customChatRequest struct {
 	Model    string              `json:"model"`
 	Messages []customChatMessage `json:"messages"`
+	Tools    []chatTool          `json:"tools,omitempty"`
 }
 
 // customChatResponse 表示聊天完成响应结构
@@ -55,6 +58,7 @@ type customChatResponse struct {
 	Choices []struct {
 		Message customChatMessage `json:"message"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
@@ -102,24 +106,49 @@ func NewCustomModelWithActualName(instanceName, actualModelName, apiKey, endpoin
 
 // Generate 实现 Model 接口的生成方法
 func (m *CustomModel) Generate(ctx context.Context, messages []Message) (string, error) {
+	msg, err := m.complete(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return msg.Content, nil
+}
+
+// GenerateWithTools 实现 ToolCallingModel，通过 tools 字段发送工具定义，
+// 并按顺序返回响应中的 tool_calls（包括并行调用）
+func (m *CustomModel) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolResponse, error) {
+	msg, err := m.complete(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolResponse{Content: msg.Content, ToolCalls: fromChatToolCalls(msg.ToolCalls)}, nil
+}
+
+// complete 发送聊天完成请求并返回第一个选择项
+func (m *CustomModel) complete(ctx context.Context, messages []Message, tools []Tool) (*customChatMessage, error) {
 	// 转换为自定义聊天消息格式
 	chatMsgs := make([]customChatMessage, len(messages))
 	for i, msg := range messages {
-		chatMsgs[i] = customChatMessage{Role: msg.Role, Content: msg.Content}
+		chatMsgs[i] = customChatMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toChatToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
 	}
 
 	reqBody, err := json.Marshal(customChatRequest{
 		Model:    m.actualModel, // 使用实际模型名称，而不是实例名称
 		Messages: chatMsgs,
+		Tools:    toChatTools(tools),
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	url := fmt.Sprintf("%s/v1/chat/completions", m.endpoint)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -127,33 +156,34 @@ func (m *CustomModel) Generate(ctx context.Context, messages []Message) (string,
 
 	resp, err := m.client.Do(httpReq)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("自定义模型API错误: %s - %s", resp.Status, string(body))
+		return nil, fmt.Errorf("自定义模型API错误: %s - %s", resp.Status, string(body))
 	}
 
 	var result customChatResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(result.Error.Message) > 0 {
-		return "", errors.New(result.Error.Message)
+		return nil, errors.New(result.Error.Message)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", errors.New("自定义模型响应中没有选择项")
+		return nil, errors.New("自定义模型响应中没有选择项")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	ReportUsage(ctx, result.Usage)
+	return &result.Choices[0].Message, nil
 }
 
 // GenerateStream 实现 Model 接口的流式生成方法
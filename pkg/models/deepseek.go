@@ -43,14 +43,17 @@ type DeepSeekModel struct {
 
 // deepSeekChatMessage mirrors the OpenAI/DeepSeek chat message format.
 type deepSeekChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
 }
 
 // deepSeekChatRequest represents a chat completion request.
 type deepSeekChatRequest struct {
 	Model    string                `json:"model"`
 	Messages []deepSeekChatMessage `json:"messages"`
+	Tools    []chatTool            `json:"tools,omitempty"`
 }
 
 // deepSeekChatResponse mirrors the expected response structure.
@@ -58,6 +61,7 @@ type deepSeekChatResponse struct {
 	Choices []struct {
 		Message deepSeekChatMessage `json:"message"`
 	} `json:"choices"`
+	Usage *Usage `json:"usage,omitempty"`
 	Error struct {
 		Message string `json:"message"`
 	} `json:"error"`
@@ -85,24 +89,50 @@ func NewDeepSeekModel(modelName string) (Model, error) {
 
 // Generate implements the Model interface (non-streaming).
 func (m *DeepSeekModel) Generate(ctx context.Context, messages []Message) (string, error) {
+	msg, err := m.complete(ctx, messages, nil)
+	if err != nil {
+		return "", err
+	}
+	return msg.Content, nil
+}
+
+// GenerateWithTools implements ToolCallingModel. The tools are sent through
+// the tools field of the request and the tool_calls of the reply, including
+// parallel calls, are returned in order.
+func (m *DeepSeekModel) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolResponse, error) {
+	msg, err := m.complete(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolResponse{Content: msg.Content, ToolCalls: fromChatToolCalls(msg.ToolCalls)}, nil
+}
+
+// complete sends a chat completion request and returns the first choice.
+func (m *DeepSeekModel) complete(ctx context.Context, messages []Message, tools []Tool) (*deepSeekChatMessage, error) {
 	// Convert to DeepSeek chat messages format.
 	chatMsgs := make([]deepSeekChatMessage, len(messages))
 	for i, msg := range messages {
-		chatMsgs[i] = deepSeekChatMessage{Role: msg.Role, Content: msg.Content}
+		chatMsgs[i] = deepSeekChatMessage{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toChatToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
+		}
 	}
 
 	reqBody, err := json.Marshal(deepSeekChatRequest{
 		Model:    m.name,
 		Messages: chatMsgs,
+		Tools:    toChatTools(tools),
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	url := fmt.Sprintf("%s/chat/completions", m.endpoint)
 	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	httpReq.Header.Set("Content-Type", "application/json")
@@ -110,33 +140,34 @@ func (m *DeepSeekModel) Generate(ctx context.Context, messages []Message) (strin
 
 	resp, err := m.client.Do(httpReq)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("DeepSeek API error: %s - %s", resp.Status, string(body))
+		return nil, fmt.Errorf("DeepSeek API error: %s - %s", resp.Status, string(body))
 	}
 
 	var result deepSeekChatResponse
 	if err := json.Unmarshal(body, &result); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(result.Error.Message) > 0 {
-		return "", errors.New(result.Error.Message)
+		return nil, errors.New(result.Error.Message)
 	}
 
 	if len(result.Choices) == 0 {
-		return "", errors.New("no choices in DeepSeek response")
+		return nil, errors.New("no choices in DeepSeek response")
 	}
 
-	return result.Choices[0].Message.Content, nil
+	ReportUsage(ctx, result.Usage)
+	return &result.Choices[0].Message, nil
 }
 
 // GenerateStream returns not implemented.
@@ -41,21 +41,37 @@ type GeminiLLM struct {
 // geminiRequest represents a request to the Gemini API
 type geminiRequest struct {
 	Contents          []geminiContent        `json:"contents"`
-	SystemInstruction string                 `json:"systemInstruction,omitempty"`
+	SystemInstruction *geminiContent         `json:"systemInstruction,omitempty"`
 	Tools             []geminiTool           `json:"tools,omitempty"`
 	GenerationConfig  geminiGenerationConfig `json:"generationConfig,omitempty"`
 }
 
 // geminiContent represents a message with role and parts in the Gemini API format
 type geminiContent struct {
-	Role  string       `json:"role"`
+	Role  string       `json:"role,omitempty"`
 	Parts []geminiPart `json:"parts"`
 }
 
 // geminiPart represents a part of content in the Gemini API format
 type geminiPart struct {
-	Text       string      `json:"text,omitempty"`
-	InlineData *inlineData `json:"inlineData,omitempty"`
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *inlineData             `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// geminiFunctionCall represents a function call requested by the model
+type geminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// geminiFunctionResponse represents the result of a function call sent back to the model
+type geminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
 }
 
 // inlineData represents inline binary data with MIME type
@@ -292,32 +308,28 @@ func (g *GeminiLLM) createGeminiRequest(request *LlmRequest) (*geminiRequest, er
 	// Create geminiContent array from existing message structure
 	var contents []geminiContent
 
-	// Handle system instructions if specified
-	if request.SystemInstructions != "" {
-		// Add system instructions as a user message
-		contents = append(contents, geminiContent{
-			Role: "user",
-			Parts: []geminiPart{{
-				Text: request.SystemInstructions,
-			}},
-		})
-	}
-
-	// Convert contents from message parts
+	// Convert contents from message parts; consecutive parts of the same role
+	// form one turn, so parallel function calls and their responses stay together
 	if request.Contents != nil && len(request.Contents.Parts) > 0 {
 		for _, part := range request.Contents.Parts {
-			var role string
-			if part.Role == "" {
+			role := part.Role
+			switch {
+			case role == "assistant":
+				role = "model"
+			case role == "" && part.FunctionCall != nil:
+				role = "model"
+			case role == "":
 				role = "user" // Default role
-			} else {
-				role = part.Role
 			}
 
-			geminiParts := []geminiPart{{Text: part.Text}}
-
+			gp := toGeminiPart(part)
+			if n := len(contents); n > 0 && contents[n-1].Role == role {
+				contents[n-1].Parts = append(contents[n-1].Parts, gp)
+				continue
+			}
 			contents = append(contents, geminiContent{
 				Role:  role,
-				Parts: geminiParts,
+				Parts: []geminiPart{gp},
 			})
 		}
 	}
@@ -344,24 +356,26 @@ func (g *GeminiLLM) createGeminiRequest(request *LlmRequest) (*geminiRequest, er
 
 	// Convert tools if present
 	var tools []geminiTool
-	if request.Tools != nil && len(request.Tools) > 0 {
+	if len(request.Tools) > 0 {
+		declarations := make([]geminiFunctionDeclaration, 0, len(request.Tools))
 		for _, tool := range request.Tools {
-			geminiTool := geminiTool{
-				FunctionDeclarations: []geminiFunctionDeclaration{
-					{
-						Name:        tool.Name,
-						Description: tool.Description,
-						Parameters:  tool.InputSchema,
-					},
-				},
-			}
-			tools = append(tools, geminiTool)
+			declarations = append(declarations, geminiFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.InputSchema,
+			})
 		}
+		tools = []geminiTool{{FunctionDeclarations: declarations}}
+	}
+
+	var systemInstruction *geminiContent
+	if request.SystemInstructions != "" {
+		systemInstruction = &geminiContent{Parts: []geminiPart{{Text: request.SystemInstructions}}}
 	}
 
 	return &geminiRequest{
 		Contents:          contents,
-		SystemInstruction: request.SystemInstructions,
+		SystemInstruction: systemInstruction,
 		Tools:             tools,
 		GenerationConfig: geminiGenerationConfig{
 			Temperature:     request.Temperature,
@@ -387,6 +401,13 @@ func (g *GeminiLLM) createResponse(geminiResp *geminiResponse) *LlmResponse {
 				Text: part.Text,
 				Role: candidate.Content.Role,
 			}
+			if fc := part.FunctionCall; fc != nil {
+				args, _ := json.Marshal(fc.Args)
+				if fc.Args == nil {
+					args = []byte("{}")
+				}
+				content.Parts[i].FunctionCall = &FunctionCall{Name: fc.Name, Arguments: string(args), ID: fc.ID}
+			}
 		}
 
 		response.Content = content
@@ -407,6 +428,27 @@ func (g *GeminiLLM) createResponse(geminiResp *geminiResponse) *LlmResponse {
 	return response
 }
 
+// toGeminiPart converts a content part to the Gemini API format. Function
+// call arguments and function responses are sent as JSON objects; a response
+// that is not an object is wrapped as {"content": ...}.
+func toGeminiPart(part *Part) geminiPart {
+	switch {
+	case part.FunctionCall != nil:
+		fc := &geminiFunctionCall{ID: part.FunctionCall.ID, Name: part.FunctionCall.Name}
+		if part.FunctionCall.Arguments != "" {
+			_ = json.Unmarshal([]byte(part.FunctionCall.Arguments), &fc.Args)
+		}
+		return geminiPart{FunctionCall: fc}
+	case part.FunctionResponse != nil:
+		fr := &geminiFunctionResponse{ID: part.FunctionResponse.ID, Name: part.FunctionResponse.Name}
+		if json.Unmarshal([]byte(part.FunctionResponse.Content), &fr.Response) != nil || fr.Response == nil {
+			fr.Response = map[string]interface{}{"content": part.FunctionResponse.Content}
+		}
+		return geminiPart{FunctionResponse: fr}
+	}
+	return geminiPart{Text: part.Text}
+}
+
 // extractTextFromResponse gets text from LlmResponse
 func (g *GeminiLLM) extractTextFromResponse(resp *LlmResponse) string {
 	if resp.Content != nil && len(resp.Content.Parts) > 0 {
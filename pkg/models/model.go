@@ -26,6 +26,11 @@ type Message struct {
 	Role    string            // Role can be "user", "system", or "assistant"
 	Content string            // The text content of the message
 	Attrs   map[string]string // Additional attributes for the message
+
+	// ToolCalls holds the native tool calls requested by an assistant message
+	ToolCalls []*FunctionCall
+	// ToolCallID links a "tool" message to the call it answers
+	ToolCallID string
 }
 
 // Model is the interface for language models.
@@ -83,6 +88,16 @@ func (r *ModelRegistry) Get(name string) (Model, bool) {
 	return model, ok
 }
 
+// Unregister removes a model from the registry. It reports whether the model
+// was registered.
+func (r *ModelRegistry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.models[name]
+	delete(r.models, name)
+	return ok
+}
+
 // List returns all registered models.
 func (r *ModelRegistry) List() []string {
 	r.mu.RLock()
@@ -20,6 +20,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
 	"sync/atomic"
 
 	"github.com/nvcnvn/adk-golang/pkg/config"
@@ -96,6 +99,35 @@ func (m *PoolModel) Generate(ctx context.Context, messages []Message) (string, e
 	return model.Generate(ctx, messages)
 }
 
+// GenerateWithTools 实现 ToolCallingModel，带负载均衡功能
+func (m *PoolModel) GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolResponse, error) {
+	if len(m.models) == 0 {
+		return nil, errors.New("模型池为空")
+	}
+
+	nextIndex := atomic.AddUint32(&m.next, 1) % uint32(len(m.models))
+	model, ok := m.models[nextIndex].(ToolCallingModel)
+	if !ok {
+		return nil, ErrToolCallingUnsupported
+	}
+
+	log.Printf("池 %s 选择端点 %d 生成带工具的响应", m.name, nextIndex)
+	return model.GenerateWithTools(ctx, messages, tools)
+}
+
+// SupportsToolCalling 仅当池中所有模型都支持原生函数调用时返回 true
+func (m *PoolModel) SupportsToolCalling() bool {
+	if len(m.models) == 0 {
+		return false
+	}
+	for _, model := range m.models {
+		if !SupportsToolCalling(model) {
+			return false
+		}
+	}
+	return true
+}
+
 // GenerateStream 实现 Model 接口的流式生成方法，带负载均衡功能
 func (m *PoolModel) GenerateStream(ctx context.Context, messages []Message) (chan StreamedResponse, error) {
 	if len(m.models) == 0 {
@@ -117,25 +149,98 @@ func RegisterModelPools(cfg *config.Config) error {
 		return nil
 	}
 
-	registry := GetEnhancedRegistry()
-
 	for name, poolCfg := range cfg.ModelAPIPools {
-		poolName := "pool:" + name
+		if err := registerPool(name, poolCfg); err != nil {
+			log.Printf("注册模型池 %s 失败: %v", poolPattern(name), err)
+			continue
+		}
+		log.Printf("成功注册模型池 pool:%s (基于 %s, %d 个端点)", name, poolCfg.Base, len(poolCfg.Endpoints))
+	}
+
+	return nil
+}
+
+// PoolReloadResult 记录一次模型池热重载的变更。
+type PoolReloadResult struct {
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
 
-		// 为每个池创建模型并注册工厂函数
-		pattern := fmt.Sprintf("^%s$", poolName)
+// Changed 表示是否有模型池发生变化。
+func (r PoolReloadResult) Changed() bool {
+	return len(r.Added)+len(r.Updated)+len(r.Removed) > 0
+}
 
-		err := registry.RegisterPattern(pattern, func(modelName string) (Model, error) {
-			return NewPoolModel(poolName, poolCfg.Base, poolCfg.Endpoints)
-		})
+// ReloadModelPools 对比新旧配置，重新注册新增或变更的模型池并注销已删除的模型池。
+// 已缓存的 pool: 模型会被清除，下一次查找时按新配置创建；
+// 正在执行的请求仍持有旧模型实例，不会被中断。
+func ReloadModelPools(oldCfg, newCfg *config.Config) (PoolReloadResult, error) {
+	var (
+		result PoolReloadResult
+		errs   []error
+	)
+	oldPools := map[string]config.ModelPoolConfig{}
+	if oldCfg != nil && oldCfg.ModelAPIPools != nil {
+		oldPools = oldCfg.ModelAPIPools
+	}
+	newPools := map[string]config.ModelPoolConfig{}
+	if newCfg != nil && newCfg.ModelAPIPools != nil {
+		newPools = newCfg.ModelAPIPools
+	}
 
-		if err != nil {
-			log.Printf("注册模型池 %s 失败: %v", poolName, err)
+	for _, name := range sortedPoolNames(newPools) {
+		poolCfg := newPools[name]
+		prev, existed := oldPools[name]
+		if existed && reflect.DeepEqual(prev, poolCfg) {
 			continue
 		}
+		if err := registerPool(name, poolCfg); err != nil {
+			errs = append(errs, fmt.Errorf("模型池 %s: %w", name, err))
+			continue
+		}
+		if existed {
+			result.Updated = append(result.Updated, name)
+		} else {
+			result.Added = append(result.Added, name)
+		}
+	}
+	for _, name := range sortedPoolNames(oldPools) {
+		if _, ok := newPools[name]; ok {
+			continue
+		}
+		GetEnhancedRegistry().UnregisterPattern(poolPattern(name))
+		result.Removed = append(result.Removed, name)
+	}
 
-		log.Printf("成功注册模型池 %s (基于 %s, %d 个端点)", poolName, poolCfg.Base, len(poolCfg.Endpoints))
+	if result.Changed() {
+		log.Printf("模型池热重载: 新增 %v, 更新 %v, 删除 %v", result.Added, result.Updated, result.Removed)
 	}
+	return result, errors.Join(errs...)
+}
 
-	return nil
+// registerPool 以 pool:<name> 注册（或替换）模型池工厂。
+// 先构造一次模型池以尽早发现配置错误，失败时保留旧的注册。
+func registerPool(name string, poolCfg config.ModelPoolConfig) error {
+	poolName := "pool:" + name
+	if _, err := NewPoolModel(poolName, poolCfg.Base, poolCfg.Endpoints); err != nil {
+		return err
+	}
+	return GetEnhancedRegistry().ReplacePattern(poolPattern(name), func(modelName string) (Model, error) {
+		return NewPoolModel(poolName, poolCfg.Base, poolCfg.Endpoints)
+	})
+}
+
+// poolPattern 返回模型池在 EnhancedRegistry 中的匹配模式。
+func poolPattern(name string) string {
+	return "^" + regexp.QuoteMeta("pool:"+name) + "$"
+}
+
+func sortedPoolNames(pools map[string]config.ModelPoolConfig) []string {
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
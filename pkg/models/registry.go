@@ -109,6 +109,89 @@ func (r *EnhancedRegistry) GetModel(name string) (Model, error) {
 	return nil, fmt.Errorf("no model factory found for model name: %s", name)
 }
 
+// ReplacePattern registers factory for pattern, replacing any existing entry
+// with the same pattern in place so lookup order is preserved. Cached models
+// matching the pattern are evicted from this registry and from the basic
+// ModelRegistry, so the next lookup builds a fresh model. Models already held
+// by callers keep working until they are released.
+func (r *EnhancedRegistry) ReplacePattern(pattern string, factory EnhancedModelFactory) error {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex pattern %s: %w", pattern, err)
+	}
+
+	r.mu.Lock()
+	replaced := false
+	for i, entry := range r.entries {
+		if entry.Pattern.String() == pattern {
+			r.entries[i].Factory = factory
+			replaced = true
+		}
+	}
+	if !replaced {
+		r.entries = append(r.entries, RegistryEntry{Pattern: regex, Factory: factory})
+	}
+	evicted := r.evictLocked(regex)
+	r.mu.Unlock()
+
+	evicted += evictBasic(regex)
+	log.Printf("Replaced model pattern: %s (evicted %d cached models)", pattern, evicted)
+	return nil
+}
+
+// UnregisterPattern removes the entry registered with pattern and evicts the
+// models it created. It reports whether the pattern was registered.
+func (r *EnhancedRegistry) UnregisterPattern(pattern string) bool {
+	r.mu.Lock()
+	var (
+		kept    = r.entries[:0]
+		removed *regexp.Regexp
+	)
+	for _, entry := range r.entries {
+		if entry.Pattern.String() == pattern {
+			removed = entry.Pattern
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	r.entries = kept
+	if removed != nil {
+		r.evictLocked(removed)
+	}
+	r.mu.Unlock()
+
+	if removed != nil {
+		evictBasic(removed)
+	}
+	return removed != nil
+}
+
+// evictLocked drops cached models whose name matches regex and returns how
+// many were dropped. The caller must hold r.mu.
+func (r *EnhancedRegistry) evictLocked(regex *regexp.Regexp) int {
+	evicted := 0
+	for name := range r.models {
+		if regex.MatchString(name) {
+			delete(r.models, name)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// evictBasic removes models matching regex that Lookup cached into the basic
+// registry and returns how many were removed.
+func evictBasic(regex *regexp.Regexp) int {
+	registry := GetRegistry()
+	evicted := 0
+	for _, name := range registry.List() {
+		if regex.MatchString(name) && registry.Unregister(name) {
+			evicted++
+		}
+	}
+	return evicted
+}
+
 // ListPatterns returns all registered patterns.
 func (r *EnhancedRegistry) ListPatterns() []string {
 	r.mu.RLock()
@@ -121,3 +204,19 @@ func (r *EnhancedRegistry) ListPatterns() []string {
 
 	return patterns
 }
+
+// Lookup resolves a model by name. The basic registry is checked first, then
+// the pattern-based enhanced registry; models created from a pattern are cached
+// into the basic registry for quicker future lookup.
+func Lookup(name string) (Model, error) {
+	registry := GetRegistry()
+	if model, ok := registry.Get(name); ok {
+		return model, nil
+	}
+	model, err := GetEnhancedRegistry().GetModel(name)
+	if err != nil {
+		return nil, err
+	}
+	registry.Register(model)
+	return model, nil
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/config"
+)
+
+func TestReloadModelPoolsEvictsCachedModels(t *testing.T) {
+	pool := func(url string) *config.Config {
+		return &config.Config{ModelAPIPools: map[string]config.ModelPoolConfig{
+			"reload_test": {Base: "test-model", Endpoints: []config.EndpointConfig{{URL: url, APIKey: "k"}}},
+		}}
+	}
+
+	v1 := pool("http://v1.example.com")
+	if res, err := ReloadModelPools(nil, v1); err != nil || len(res.Added) != 1 {
+		t.Fatalf("注册失败: %+v %v", res, err)
+	}
+	first, err := Lookup("pool:reload_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 端点变化后缓存被清除，重新查找得到新实例
+	v2 := pool("http://v2.example.com")
+	if res, err := ReloadModelPools(v1, v2); err != nil || len(res.Updated) != 1 {
+		t.Fatalf("更新失败: %+v %v", res, err)
+	}
+	second, err := Lookup("pool:reload_test")
+	if err != nil || second == first {
+		t.Fatalf("缓存未清除: %v", err)
+	}
+
+	// 未变化时不重新注册
+	if res, _ := ReloadModelPools(v2, pool("http://v2.example.com")); res.Changed() {
+		t.Fatalf("不应有变更: %+v", res)
+	}
+
+	// 删除后无法再查找
+	if res, _ := ReloadModelPools(v2, &config.Config{}); len(res.Removed) != 1 {
+		t.Fatalf("删除失败: %+v", res)
+	}
+	if _, err := Lookup("pool:reload_test"); err == nil {
+		t.Fatal("删除的模型池不应再可用")
+	}
+}
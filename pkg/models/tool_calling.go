@@ -0,0 +1,130 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// 原生函数调用支持。兼容 OpenAI ChatCompletion API 的模型通过请求中的 tools
+// 字段接收工具定义，并在响应的 tool_calls 字段中返回（可能并行的）工具调用。
+
+package models
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrToolCallingUnsupported 表示模型不支持原生函数调用，调用方应退回文本协议
+var ErrToolCallingUnsupported = errors.New("模型不支持原生函数调用")
+
+// ToolResponse 是一次带工具的生成结果
+type ToolResponse struct {
+	// Content 为模型返回的文本，请求工具时可能为空
+	Content string
+
+	// ToolCalls 为模型请求的工具调用，按响应中的顺序排列；
+	// Arguments 为 JSON 编码的参数
+	ToolCalls []*FunctionCall
+}
+
+// ToolCallingModel 由支持原生函数调用的模型实现。
+//
+// 发送给模型的历史中，请求工具的 assistant 消息通过 Message.ToolCalls 携带调用，
+// 工具结果以 role 为 "tool" 的消息发送，并通过 Message.ToolCallID 关联对应调用。
+type ToolCallingModel interface {
+	Model
+
+	// GenerateWithTools 将 tools 作为可调用的函数发送给模型
+	GenerateWithTools(ctx context.Context, messages []Message, tools []Tool) (*ToolResponse, error)
+}
+
+// SupportsToolCalling 判断模型是否可以使用原生函数调用。
+// 包装其他模型的实现（如 PoolModel）可通过 SupportsToolCalling() bool 方法声明实际能力。
+func SupportsToolCalling(m Model) bool {
+	if _, ok := m.(ToolCallingModel); !ok {
+		return false
+	}
+	if s, ok := m.(interface{ SupportsToolCalling() bool }); ok {
+		return s.SupportsToolCalling()
+	}
+	return true
+}
+
+// chatToolCall 是 OpenAI 格式的工具调用
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatTool 是 OpenAI 格式的工具定义
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
+}
+
+// toChatTools 将工具定义转换为 OpenAI 格式，未声明参数的工具使用空对象 schema
+func toChatTools(tools []Tool) []chatTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	out := make([]chatTool, len(tools))
+	for i, t := range tools {
+		out[i].Type = "function"
+		out[i].Function.Name = t.Name
+		out[i].Function.Description = t.Description
+		out[i].Function.Parameters = t.InputSchema
+		if out[i].Function.Parameters == nil {
+			out[i].Function.Parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+	}
+	return out
+}
+
+// toChatToolCalls 将消息中的工具调用转换为 OpenAI 格式
+func toChatToolCalls(calls []*FunctionCall) []chatToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]chatToolCall, len(calls))
+	for i, c := range calls {
+		out[i].ID = c.ID
+		out[i].Type = "function"
+		out[i].Function.Name = c.Name
+		out[i].Function.Arguments = c.Arguments
+		if out[i].Function.Arguments == "" {
+			out[i].Function.Arguments = "{}"
+		}
+	}
+	return out
+}
+
+// fromChatToolCalls 解析响应中的工具调用
+func fromChatToolCalls(calls []chatToolCall) []*FunctionCall {
+	if len(calls) == 0 {
+		return nil
+	}
+	out := make([]*FunctionCall, 0, len(calls))
+	for _, c := range calls {
+		if c.Function.Name == "" {
+			continue
+		}
+		out = append(out, &FunctionCall{Name: c.Function.Name, Arguments: c.Function.Arguments, ID: c.ID})
+	}
+	return out
+}
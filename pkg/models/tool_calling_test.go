@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nvcnvn/adk-golang/pkg/config"
+)
+
+func TestGenerateWithToolsParsesParallelCalls(t *testing.T) {
+	var req customChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[
+			{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"北京\"}"}},
+			{"id":"call_2","type":"function","function":{"name":"weather","arguments":"{\"city\":\"上海\"}"}}]}}]}`))
+	}))
+	defer srv.Close()
+
+	pool, err := NewPoolModel("pool:tools", "test-model", []config.EndpointConfig{{URL: srv.URL, APIKey: "k"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !SupportsToolCalling(pool) {
+		t.Fatal("custom model pool should support tool calling")
+	}
+
+	resp, err := pool.GenerateWithTools(context.Background(), []Message{
+		{Role: "user", Content: "天气如何"},
+		{Role: "assistant", ToolCalls: []*FunctionCall{{Name: "weather", ID: "call_0"}}},
+		{Role: "tool", Content: "晴", ToolCallID: "call_0"},
+	}, []Tool{{Name: "weather", Description: "查询天气"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ToolCalls) != 2 || resp.ToolCalls[1].ID != "call_2" || resp.ToolCalls[1].Arguments != `{"city":"上海"}` {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+
+	// 工具定义与历史中的调用按 OpenAI 格式发送
+	if len(req.Tools) != 1 || req.Tools[0].Type != "function" || req.Tools[0].Function.Parameters["type"] != "object" {
+		t.Errorf("tools not sent: %+v", req.Tools)
+	}
+	if req.Messages[1].ToolCalls[0].Function.Arguments != "{}" || req.Messages[2].ToolCallID != "call_0" {
+		t.Errorf("tool history not sent: %+v", req.Messages)
+	}
+}
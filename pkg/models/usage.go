@@ -0,0 +1,31 @@
+// 模型调用的 token 用量上报。Model 接口只返回文本，用量通过 ctx 上注册的回调
+// 在每次调用完成后上报，调用方（如智能体的事件流）无需关心具体模型类型。
+
+package models
+
+import "context"
+
+// Usage 为一次模型调用的 token 用量，字段与 OpenAI ChatCompletion 响应的 usage 一致
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// usageReporterKey 为用量回调的 context key
+type usageReporterKey struct{}
+
+// WithUsageReporter 在 ctx 上注册用量回调，支持上报的模型在调用完成后调用 fn
+func WithUsageReporter(ctx context.Context, fn func(Usage)) context.Context {
+	return context.WithValue(ctx, usageReporterKey{}, fn)
+}
+
+// ReportUsage 将用量交给 ctx 上注册的回调，未注册或 usage 为 nil 时忽略
+func ReportUsage(ctx context.Context, usage *Usage) {
+	if usage == nil {
+		return
+	}
+	if fn, ok := ctx.Value(usageReporterKey{}).(func(Usage)); ok && fn != nil {
+		fn(*usage)
+	}
+}
@@ -10,11 +10,18 @@ import (
 // ResultChan 必须非 nil，调度器完成后会写入结果。
 // Cancel 方法由调用方传入 context 控制。
 type Task struct {
-    Ctx       context.Context // 上下文，用于取消
-    Workflow  string          // 工作流名称
-    Input     string          // 原始输入
-    UserID    string          // 用户标识
-    ArchiveID string          // 归档标识符
+    Ctx       context.Context        // 上下文，用于取消
+    Workflow  string                 // 工作流名称
+    Version   string                 // 工作流版本，由服务层在提交前确定
+    Agent     string                 // 目标子 Agent 名称，为空时由根 Agent 处理
+    Input     string                 // 原始输入
+    UserID    string                 // 用户标识
+    ArchiveID string                 // 归档标识符
+    Params    map[string]interface{} // 请求参数，用于解析 instruction 中的 {key} 占位符
+
+    // Run 非空时代替 Processor 执行任务，用于需要自行驱动执行过程的调用（如逐个转发运行事件），
+    // 任务仍受队列长度与 worker 数量约束
+    Run func(ctx context.Context) (string, error)
 
     ResultChan chan Result // 返回结果
 }
@@ -103,7 +110,15 @@ func (s *workerPoolScheduler) worker() {
             if task == nil {
                 continue
             }
-            output, err := s.processor(task.Ctx, task)
+            var (
+                output string
+                err    error
+            )
+            if task.Run != nil {
+                output, err = task.Run(task.Ctx)
+            } else {
+                output, err = s.processor(task.Ctx, task)
+            }
             select {
             case task.ResultChan <- Result{Output: output, Err: err}:
             default:
@@ -0,0 +1,213 @@
+// Package schema 提供 JSON Schema 子集的定义与校验，
+// 用于校验 HTTP 路由请求体、Agent 结构化输出等 JSON 数据。
+//
+// 支持的关键字：type、properties、required、items、enum、
+// minLength/maxLength、minimum/maximum、minItems/maxItems、additionalProperties。
+// 未支持的关键字在解析时被忽略。
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Schema 为 JSON Schema 子集。
+type Schema struct {
+	Type                 string             `json:"type,omitempty"` // object/array/string/number/integer/boolean/null
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+}
+
+// FieldError 描述单个字段的校验失败。Path 形如 "$.chapters[0].title"。
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationError 汇总一次校验中的全部失败。
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		parts = append(parts, fe.String())
+	}
+	return "schema validation failed: " + strings.Join(parts, "; ")
+}
+
+// Parse 从 JSON 文本解析 Schema。
+func Parse(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("解析 schema 失败: %w", err)
+	}
+	return &s, nil
+}
+
+// Validate 校验已解码的 JSON 值（encoding/json 解码到 interface{} 的结果）。
+// 通过时返回 nil，否则返回 *ValidationError。
+func (s *Schema) Validate(v interface{}) error {
+	if s == nil {
+		return nil
+	}
+	var errs []FieldError
+	s.validate("$", v, &errs)
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// ValidateJSON 解析 JSON 文本并校验。
+func (s *Schema) ValidateJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return &ValidationError{Errors: []FieldError{{Path: "$", Message: "不是合法的 JSON: " + err.Error()}}}
+	}
+	return s.Validate(v)
+}
+
+// String 返回 Schema 的 JSON 表示，便于拼入提示词。
+func (s *Schema) String() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]FieldError) {
+	add := func(format string, args ...interface{}) {
+		*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !matchesType(s.Type, v) {
+		add("类型应为 %s，实际为 %s", s.Type, typeName(v))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		add("取值必须为 %v 之一", s.Enum)
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				*errs = append(*errs, FieldError{Path: path + "." + name, Message: "缺少必填字段"})
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if prop, ok := s.Properties[k]; ok {
+				prop.validate(path+"."+k, val[k], errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*errs = append(*errs, FieldError{Path: path + "." + k, Message: "不允许的字段"})
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			add("元素数量不能少于 %d", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			add("元素数量不能多于 %d", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		n := len([]rune(val))
+		if s.MinLength != nil && n < *s.MinLength {
+			add("长度不能小于 %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			add("长度不能大于 %d", *s.MaxLength)
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			add("不能小于 %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			add("不能大于 %v", *s.Maximum)
+		}
+	}
+}
+
+func matchesType(t string, v interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	// 未知类型不做限制
+	return true
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) && typeName(e) == typeName(v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := Parse([]byte(`{
+		"type": "object",
+		"required": ["title", "chapters"],
+		"properties": {
+			"title": {"type": "string", "minLength": 1},
+			"genre": {"type": "string", "enum": ["fantasy", "scifi"]},
+			"chapters": {"type": "integer", "minimum": 1, "maximum": 50}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ValidateJSON([]byte(`{"title":"星海","chapters":12,"genre":"scifi"}`)); err != nil {
+		t.Fatalf("合法输入校验失败: %v", err)
+	}
+
+	err = s.ValidateJSON([]byte(`{"title":"","chapters":1.5,"genre":"romance"}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 ValidationError，实际 %v", err)
+	}
+	paths := map[string]bool{}
+	for _, fe := range verr.Errors {
+		paths[fe.Path] = true
+	}
+	for _, p := range []string{"$.title", "$.chapters", "$.genre"} {
+		if !paths[p] {
+			t.Errorf("缺少 %s 的错误，实际: %v", p, verr.Errors)
+		}
+	}
+
+	if err := s.ValidateJSON([]byte(`{"title":"x"}`)); err == nil {
+		t.Fatal("缺少必填字段应校验失败")
+	}
+	if err := s.ValidateJSON([]byte(`not json`)); err == nil {
+		t.Fatal("非法 JSON 应校验失败")
+	}
+}
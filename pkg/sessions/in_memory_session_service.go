@@ -190,11 +190,14 @@ func (s *InMemorySessionService) AppendEvent(
 	// Add the event
 	storedSession.AddEvent(event)
 
-	// Update the original session to match the stored one
-	session.Events = make([]*events.Event, len(storedSession.Events))
-	copy(session.Events, storedSession.Events)
-	session.StateMap = storedSession.StateMap
-	session.UpdateTime = storedSession.UpdateTime
+	// Update the original session to match the stored one, unless the caller
+	// passed the stored session itself as returned by GetSession
+	if session != storedSession {
+		session.Events = make([]*events.Event, len(storedSession.Events))
+		copy(session.Events, storedSession.Events)
+		session.StateMap = storedSession.StateMap
+		session.UpdateTime = storedSession.UpdateTime
+	}
 
 	return event, nil
 }
@@ -18,6 +18,16 @@ import (
 	"context"
 )
 
+// exitLoopHandlerKey is the context key for the exit loop handler.
+type exitLoopHandlerKey struct{}
+
+// WithExitLoopHandler returns a context in which ExitLoopTool calls fn.
+// agents.LoopAgent installs a handler that stops the loop when it has the
+// StopOnEscalate condition.
+func WithExitLoopHandler(ctx context.Context, fn func()) context.Context {
+	return context.WithValue(ctx, exitLoopHandlerKey{}, fn)
+}
+
 // ExitLoopTool is a tool that allows an agent to exit a loop.
 var ExitLoopTool = NewTool(
 	"exit_loop",
@@ -35,9 +45,14 @@ var ExitLoopTool = NewTool(
 		},
 	},
 	func(ctx context.Context, input map[string]interface{}) (map[string]interface{}, error) {
-		// In a real implementation, this would set a flag in the execution context
-		// to indicate that the loop should exit
-		// For now we just return success
+		handler, ok := ctx.Value(exitLoopHandlerKey{}).(func())
+		if !ok {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "not running inside a loop",
+			}, nil
+		}
+		handler()
 		return map[string]interface{}{
 			"success": true,
 			"message": "Loop exit signal sent",
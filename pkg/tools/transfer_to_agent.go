@@ -18,7 +18,23 @@ import (
 	"context"
 )
 
+// TransferHandler performs a transfer requested through TransferToAgentTool.
+// It returns an error when the target agent cannot be transferred to.
+type TransferHandler func(agentName string) error
+
+// transferHandlerKey is the context key for the TransferHandler.
+type transferHandlerKey struct{}
+
+// WithTransferHandler returns a context in which TransferToAgentTool hands
+// transfers to fn. Agents that dispatch between sub-agents, such as the router
+// agent, install a handler for each agent they run.
+func WithTransferHandler(ctx context.Context, fn TransferHandler) context.Context {
+	return context.WithValue(ctx, transferHandlerKey{}, fn)
+}
+
 // TransferToAgentTool is a tool that allows an agent to transfer control to another agent.
+// The transfer is performed by the TransferHandler of the context; without one
+// the tool reports failure.
 var TransferToAgentTool = NewTool(
 	"transfer_to_agent",
 	"Transfer the conversation to another agent",
@@ -49,10 +65,19 @@ var TransferToAgentTool = NewTool(
 			}, nil
 		}
 
-		// Set transfer info to context
-		// In a real implementation, this would use proper context values
-		// that are read by the agent system later
-		// For now we just return the agent name so it can be handled elsewhere
+		handler, ok := ctx.Value(transferHandlerKey{}).(TransferHandler)
+		if !ok {
+			return map[string]interface{}{
+				"success": false,
+				"error":   "transfer is not supported here",
+			}, nil
+		}
+		if err := handler(agentName); err != nil {
+			return map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			}, nil
+		}
 		return map[string]interface{}{
 			"success":      true,
 			"target_agent": agentName,
@@ -13,9 +13,10 @@
 // limitations under the License.
 
 // Package vector_rag_tool implements standardized tools for writing to and
-// reading from a vector RAG backend. The implementation re-uses
-// CustomRagMemoryService so that agents can directly interact with the
-// FastAPI + Milvus service via structured tool calls.
+// reading from a vector RAG backend. The backend is any memory.MemoryService
+// resolved by name from the memory registry (by default the FastAPI + Milvus
+// CustomRagMemoryService), so that agents can interact with it via structured
+// tool calls.
 //
 // Two tools are exported:
 //   - RAGWrite  – ingests text content as a session in the RAG service
@@ -29,6 +30,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/nvcnvn/adk-golang/pkg/events"
 	"github.com/nvcnvn/adk-golang/pkg/logger"
@@ -42,16 +44,46 @@ import (
 // Shared memory service instance
 //-------------------------------------------------------------------------
 
-// ragMemory is a package-level CustomRagMemoryService. The default constructor
-// uses environment variables RAG_BASE_URL and RAG_TOP_K if present (handled in
-// NewCustomRagMemoryServiceWithDefaults).
-var ragMemory = memory.NewCustomRagMemoryServiceWithDefaults()
+// The tools resolve their backend by name from memory.DefaultRegistry, which
+// the host builds from the `memory:` section of config.yaml. An empty backend
+// name selects memory.default. WithMemory pins a concrete service instead and
+// takes precedence over the registry.
+var (
+	memMu       sync.RWMutex
+	ragBackend  string
+	memOverride memory.MemoryService
+)
 
-// WithMemory allows overriding the default memory service (e.g. in tests).
+// WithMemory allows overriding the memory service (e.g. in tests or when a
+// plugin receives one from flow.Host).
 func WithMemory(mem memory.MemoryService) {
-	if c, ok := mem.(*memory.CustomRagMemoryService); ok && c != nil {
-		ragMemory = c
+	memMu.Lock()
+	defer memMu.Unlock()
+	memOverride = mem
+}
+
+// UseBackend selects the named memory backend from the registry and clears
+// any WithMemory override. It fails if the backend is not registered.
+func UseBackend(name string) error {
+	if _, err := memory.Lookup(name); err != nil {
+		return err
+	}
+	memMu.Lock()
+	defer memMu.Unlock()
+	ragBackend = name
+	memOverride = nil
+	return nil
+}
+
+// ragMemory returns the memory service used by the tools.
+func ragMemory() (memory.MemoryService, error) {
+	memMu.RLock()
+	override, name := memOverride, ragBackend
+	memMu.RUnlock()
+	if override != nil {
+		return override, nil
 	}
+	return memory.Lookup(name)
 }
 
 //-------------------------------------------------------------------------
@@ -108,7 +140,11 @@ func NewRAGWriteTool(userID, archiveID string) tools.Tool {
 			}
 
 			logger.S().Infow("RAG写入操作", "user_id", userID, "archive_id", archiveID, "content_length", len(content))
-			if err := ragMemory.AddSessionToMemory(ctx, session); err != nil {
+			mem, err := ragMemory()
+			if err != nil {
+				return nil, fmt.Errorf("resolve memory backend: %w", err)
+			}
+			if err := mem.AddSessionToMemory(ctx, session); err != nil {
 				return nil, fmt.Errorf("failed to add session to memory: %w", err)
 			}
 
@@ -156,7 +192,11 @@ func NewRAGSearchTool(userID, archiveID string) tools.Tool {
 			}
 
 			logger.S().Infow("RAG搜索操作", "user_id", userID, "archive_id", archiveID, "query", query)
-			results, err := ragMemory.SearchMemory(ctx, "app_name_placeholder", userID, archiveID, query)
+			mem, err := ragMemory()
+			if err != nil {
+				return nil, fmt.Errorf("resolve memory backend: %w", err)
+			}
+			results, err := mem.SearchMemory(ctx, "app_name_placeholder", userID, archiveID, query)
 			if err != nil {
 				return nil, fmt.Errorf("failed to search memory: %w", err)
 			}